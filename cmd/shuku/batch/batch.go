@@ -1,12 +1,20 @@
 package batch
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 
+	"github.com/takumines/shuku/cmd/shuku/version"
+	"github.com/takumines/shuku/internal/backup"
 	"github.com/takumines/shuku/internal/batch"
+	"github.com/takumines/shuku/internal/glob"
 	"github.com/takumines/shuku/pkg/shuku"
 	"github.com/urfave/cli/v2"
 )
@@ -21,13 +29,13 @@ func Cmd() *cli.Command {
 			&cli.StringFlag{
 				Name:     "input",
 				Aliases:  []string{"i"},
-				Usage:    "Input directory path",
+				Usage:    "Input directory path, zip/tar/tar.gz archive, or s3://bucket/prefix",
 				Required: true,
 			},
 			&cli.StringFlag{
 				Name:    "output",
 				Aliases: []string{"o"},
-				Usage:   "Output directory path (optional, defaults to same as input with '_compressed' suffix)",
+				Usage:   "Output directory path, archive path, or s3://bucket/prefix (optional, defaults to same as input with '_compressed' suffix)",
 			},
 			&cli.IntFlag{
 				Name:    "quality",
@@ -53,12 +61,86 @@ func Cmd() *cli.Command {
 			},
 			&cli.StringFlag{
 				Name:  "include",
-				Usage: "File patterns to include (comma-separated, e.g., '*.jpg,*.png')",
-				Value: "*.jpg,*.jpeg,*.png,*.webp",
+				Usage: "File patterns to include (comma-separated, e.g., '*.jpg,static/img/*.png'); patterns with '/' match the path relative to the input, '**' matches any depth",
+				Value: strings.Join(batch.DefaultIncludePatterns(), ","),
 			},
 			&cli.StringFlag{
 				Name:  "exclude",
-				Usage: "File patterns to exclude (comma-separated, e.g., '*_thumb*,*_backup*')",
+				Usage: "File patterns to exclude (comma-separated, e.g., '*_thumb*,vendor/**')",
+			},
+			&cli.BoolFlag{
+				Name:  "ignore-case",
+				Usage: "Match --include, --exclude and ignore files case-insensitively (e.g., '*.jpg' also matches 'DSC0001.JPG')",
+			},
+			&cli.StringFlag{
+				Name:  "ignore-file",
+				Usage: "Name of the gitignore-style file read from each directory to skip files (empty to disable)",
+				Value: batch.DefaultIgnoreFileName,
+			},
+			&cli.StringSliceFlag{
+				Name:  "rule",
+				Usage: "Override options for files matching a pattern, as PATTERN:OPTION[:OPTION...] with quality=N, max-size=SIZE (JPEG/WebP), copy (keep the original bytes) or name=NAME (repeatable; the first matching rule wins)",
+			},
+			&cli.StringFlag{
+				Name:  "rules-file",
+				Usage: "JSON file with per-pattern rules ({\"rules\": [{\"pattern\": \"photos/**\", \"quality\": 75}]}); --rule entries are matched first",
+			},
+			&cli.StringFlag{
+				Name:  "suffix",
+				Usage: "Suffix added to output file names when --output is omitted; files ending with it are skipped as previous outputs",
+				Value: batch.DefaultOutputSuffix,
+			},
+			&cli.BoolFlag{
+				Name:  "in-place",
+				Usage: "Overwrite the input files atomically with the compressed results",
+			},
+			&cli.StringFlag{
+				Name:  "backup-dir",
+				Usage: "Directory to keep copies of the original files before overwriting (with --in-place)",
+			},
+			&cli.StringFlag{
+				Name:  "backup-suffix",
+				Usage: "Suffix for copies of the original files kept next to them, e.g. '.orig' (with --in-place)",
+			},
+			&cli.BoolFlag{
+				Name:  "fix-ext",
+				Usage: "Rename outputs to match the detected image format when the extension is wrong",
+			},
+			&cli.BoolFlag{
+				Name:  "incremental",
+				Usage: "Skip files unchanged since the last run using a manifest in the output directory",
+			},
+			&cli.StringFlag{
+				Name:  "manifest",
+				Usage: "Path of the manifest for incremental runs (implies --incremental)",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "List the files that would be compressed, their output paths and conflicts without writing anything",
+			},
+			&cli.BoolFlag{
+				Name:  "estimate",
+				Usage: "Compress a sample of each format in memory and estimate the total savings (implies --dry-run)",
+			},
+			&cli.BoolFlag{
+				Name:  "resume",
+				Usage: "Continue an interrupted run, skipping files recorded as completed in its journal",
+			},
+			&cli.StringFlag{
+				Name:  "report-format",
+				Usage: "Format of the machine-readable report: json, csv or junit (default: inferred from --report-file)",
+			},
+			&cli.StringFlag{
+				Name:  "report-file",
+				Usage: "Write a report of every file and the statistics to this path (used with --report-format)",
+			},
+			&cli.StringFlag{
+				Name:  "s3-endpoint",
+				Usage: "Endpoint URL of an S3-compatible server for s3:// input/output (default: $AWS_ENDPOINT_URL_S3 or AWS)",
+			},
+			&cli.StringFlag{
+				Name:  "s3-region",
+				Usage: "Region for s3:// input/output (default: $AWS_REGION or us-east-1)",
 			},
 			&cli.BoolFlag{
 				Name:    "verbose",
@@ -69,6 +151,10 @@ func Cmd() *cli.Command {
 				Name:  "stats",
 				Usage: "Show compression statistics",
 			},
+			&cli.BoolFlag{
+				Name:  "no-progress",
+				Usage: "Disable the progress bar (periodic progress lines when stdout is not a terminal)",
+			},
 		},
 		Action: batchAction,
 	}
@@ -82,9 +168,11 @@ func batchAction(c *cli.Context) error {
 		return cli.Exit("入力ディレクトリが指定されていません。--input または -i オプションで指定してください。", 1)
 	}
 
-	// 入力ディレクトリの存在確認
-	if _, err := os.Stat(inputDir); os.IsNotExist(err) {
-		return cli.Exit(fmt.Sprintf("入力ディレクトリが存在しません: %s", inputDir), 1)
+	// 入力ディレクトリの存在確認（S3 の場合は一覧の取得時に確認する）
+	if !isS3(inputDir) {
+		if _, err := os.Stat(inputDir); os.IsNotExist(err) {
+			return cli.Exit(fmt.Sprintf("入力ディレクトリが存在しません: %s", inputDir), 1)
+		}
 	}
 
 	// オプションの設定
@@ -92,28 +180,99 @@ func batchAction(c *cli.Context) error {
 		Quality:     c.Int("quality"),
 		PaletteSize: c.Int("palette-size"),
 	}
+	if err := options.Validate(); err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	// 上書きモードの設定を検証
+	backupConfig := backup.Config{Dir: c.String("backup-dir"), Suffix: c.String("backup-suffix")}
+	if c.Bool("in-place") && c.String("output") != "" {
+		return cli.Exit("--in-place と --output は同時に指定できません。", 1)
+	}
+	if !c.Bool("in-place") && backupConfig.Enabled() {
+		return cli.Exit("--backup-dir と --backup-suffix は --in-place と組み合わせて指定してください。", 1)
+	}
 
 	// バッチプロセッサーの設定
 	processor := batch.NewProcessor(c.Int("workers"), c.String("output"))
 	processor.SetRecursive(c.Bool("recursive"))
+	processor.SetOutputSuffix(c.String("suffix"))
+	processor.SetInPlace(c.Bool("in-place"), backupConfig)
+	processor.SetFixExtension(c.Bool("fix-ext"))
+
+	// 増分処理の設定
+	manifest, err := manifestPath(c.String("manifest"), c.Bool("incremental"), inputDir, c.String("output"))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	if manifest != "" {
+		if isArchive(inputDir) {
+			return cli.Exit("--incremental と --manifest はアーカイブの処理では使用できません。", 1)
+		}
+		processor.SetManifest(manifest, version.Version+"+"+version.Commit)
+	}
+
+	// 中断に備えて完了したファイルをジャーナルに記録する（アーカイブは出力全体を一度に書き込むため不要）
+	if isArchive(inputDir) {
+		if c.Bool("resume") {
+			return cli.Exit("--resume はアーカイブの処理では使用できません。", 1)
+		}
+	} else {
+		journal, err := journalPath(inputDir, c.String("output"))
+		switch {
+		case err == nil:
+			processor.SetJournal(journal, c.Bool("resume"))
+		case c.Bool("resume"):
+			return cli.Exit(err.Error(), 1)
+		default:
+			// ジャーナルは再開のためだけに使用するため、保存できなくても処理は続ける
+			fmt.Printf("⚠️  %v。中断した場合は再開できません\n", err)
+		}
+	}
 
 	// 包含パターンの設定
 	if includePatterns := c.String("include"); includePatterns != "" {
-		patterns := strings.Split(includePatterns, ",")
-		for i, pattern := range patterns {
-			patterns[i] = strings.TrimSpace(pattern)
+		patterns, err := splitPatterns(includePatterns)
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("--include のパターンが不正です: %v", err), 1)
 		}
 		processor.SetIncludePatterns(patterns)
 	}
 
 	// 除外パターンの設定
 	if excludePatterns := c.String("exclude"); excludePatterns != "" {
-		patterns := strings.Split(excludePatterns, ",")
-		for i, pattern := range patterns {
-			patterns[i] = strings.TrimSpace(pattern)
+		patterns, err := splitPatterns(excludePatterns)
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("--exclude のパターンが不正です: %v", err), 1)
 		}
 		processor.SetExcludePatterns(patterns)
 	}
+	processor.SetIgnoreCase(c.Bool("ignore-case"))
+	processor.SetIgnoreFile(c.String("ignore-file"))
+
+	// パスごとのルールの設定
+	rules, err := loadRules(c.StringSlice("rule"), c.String("rules-file"))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	processor.SetRules(rules)
+
+	// レポートの設定を検証
+	reportFormat, err := parseReportFormat(c.String("report-format"), c.String("report-file"))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	// ドライラン（書き込みを行わずに処理内容を表示）
+	if c.Bool("dry-run") || c.Bool("estimate") {
+		if isArchive(inputDir) {
+			return cli.Exit("--dry-run と --estimate はアーカイブの処理では使用できません。", 1)
+		}
+		if err := dryRun(c, processor, inputDir, c.String("output"), options, c.Bool("estimate")); err != nil {
+			return cli.Exit(fmt.Sprintf("ドライランエラー: %v", err), 1)
+		}
+		return nil
+	}
 
 	verbose := c.Bool("verbose")
 	showStats := c.Bool("stats")
@@ -123,6 +282,8 @@ func batchAction(c *cli.Context) error {
 		fmt.Printf("入力ディレクトリ: %s\n", inputDir)
 		if outputDir := c.String("output"); outputDir != "" {
 			fmt.Printf("出力ディレクトリ: %s\n", outputDir)
+		} else if c.Bool("in-place") {
+			fmt.Println("出力ディレクトリ: 入力ファイルを上書き")
 		} else {
 			fmt.Println("出力ディレクトリ: 各ファイルと同じディレクトリ")
 		}
@@ -134,43 +295,80 @@ func batchAction(c *cli.Context) error {
 		if excludePatterns := c.String("exclude"); excludePatterns != "" {
 			fmt.Printf("除外パターン: %s\n", excludePatterns)
 		}
+		if manifest != "" {
+			fmt.Printf("マニフェスト: %s\n", manifest)
+		}
 		fmt.Println()
 	}
 
 	fmt.Println("バッチ圧縮を開始しています...")
+	if verbose {
+		fmt.Println("\n=== 処理結果詳細 ===")
+	}
+
+	// 進捗の表示
+	var progress *progressPrinter
+	if !c.Bool("no-progress") {
+		progress = newProgressPrinter(os.Stdout, isTerminal(os.Stdout))
+		processor.SetProgress(progress.Handle)
+	}
+
+	// 結果は統計情報へ集計し、実行ごとに同じ出力になるよう探索した順に並べ替えて表示する
+	// （レポートを出力する場合のみ結果を保持する）。進捗の表示は完了した順に更新する
+	var stats batch.Statistics
+	var results []batch.Result
+	printer := newOrderedPrinter(func(text string) {
+		if progress != nil {
+			progress.Print(func() { fmt.Print(text) })
+		} else {
+			fmt.Print(text)
+		}
+	})
+	handleResult := func(result batch.Result) error {
+		stats.Add(result)
+		if reportFormat != "" {
+			results = append(results, result)
+		}
+		printer.Add(result.Job.Seq(), formatResult(result, verbose))
+		return nil
+	}
 
 	// バッチ処理の実行
-	results, err := processor.ProcessDirectory(inputDir, options)
+	if isS3(inputDir) || isS3(c.String("output")) {
+		err = processStorage(c, processor, inputDir, c.String("output"), options, handleResult)
+	} else if isArchive(inputDir) {
+		var archiveResults []batch.Result
+		archiveResults, err = processor.ProcessArchiveContext(c.Context, inputDir, c.String("output"), options)
+		for _, result := range archiveResults {
+			_ = handleResult(result)
+		}
+	} else {
+		err = processor.StreamDirectoryContext(c.Context, inputDir, options, handleResult)
+	}
+	printer.Flush()
+	if progress != nil {
+		progress.Finish()
+	}
+	// 中断やエラーの場合も、それまでに処理したファイルのレポートを出力する
+	if reportFormat != "" {
+		if reportErr := writeReport(c.String("report-file"), reportFormat, results, stats); reportErr != nil {
+			return cli.Exit(fmt.Sprintf("レポート出力エラー: %v", reportErr), 1)
+		}
+	}
 	if err != nil {
+		if errors.Is(err, context.Canceled) && c.Context.Err() != nil {
+			fmt.Printf("\n処理を中断しました（完了: %d ファイル）。--resume を指定して実行すると続きから再開できます。\n", stats.SuccessFiles)
+			return cli.Exit("", 130)
+		}
 		return cli.Exit(fmt.Sprintf("バッチ処理エラー: %v", err), 1)
 	}
 
 	// 結果の表示
-	if len(results) == 0 {
+	if stats.TotalFiles == 0 {
 		fmt.Println("処理対象のファイルが見つかりませんでした。")
 		return nil
 	}
-
-	// 統計情報の計算
-	stats := batch.CalculateStatistics(results)
-
-	// 詳細表示の場合は各ファイルの結果を表示
 	if verbose {
-		fmt.Println("\n=== 処理結果詳細 ===")
-		for _, result := range results {
-			if result.Error != nil {
-				fmt.Printf("❌ %s: %v\n", result.Job.InputPath, result.Error)
-			} else {
-				compressionRatio := 0.0
-				if result.OriginalSize > 0 {
-					compressionRatio = 100.0 - (float64(result.CompressedSize) / float64(result.OriginalSize) * 100.0)
-				}
-				fmt.Printf("✅ %s → %s (%.2f%% 圧縮)\n",
-					result.Job.InputPath,
-					result.Job.OutputPath,
-					compressionRatio)
-			}
-		}
 		fmt.Println()
 	}
 
@@ -179,6 +377,12 @@ func batchAction(c *cli.Context) error {
 		fmt.Println("=== 圧縮統計 ===")
 		fmt.Printf("処理ファイル数: %d\n", stats.TotalFiles)
 		fmt.Printf("成功: %d, 失敗: %d\n", stats.SuccessFiles, stats.FailedFiles)
+		if stats.CachedFiles > 0 {
+			fmt.Printf("キャッシュ済み（圧縮を省略）: %d\n", stats.CachedFiles)
+		}
+		if stats.ResumedFiles > 0 {
+			fmt.Printf("前回の実行で処理済み（再開により省略）: %d\n", stats.ResumedFiles)
+		}
 
 		if stats.SuccessFiles > 0 {
 			fmt.Printf("元のサイズ合計: %s\n", formatFileSize(stats.TotalOriginalSize))
@@ -189,6 +393,12 @@ func batchAction(c *cli.Context) error {
 		// 簡潔な結果表示
 		fmt.Printf("バッチ圧縮が完了しました！\n")
 		fmt.Printf("処理ファイル数: %d (成功: %d, 失敗: %d)\n", stats.TotalFiles, stats.SuccessFiles, stats.FailedFiles)
+		if stats.CachedFiles > 0 {
+			fmt.Printf("キャッシュ済み（圧縮を省略）: %d\n", stats.CachedFiles)
+		}
+		if stats.ResumedFiles > 0 {
+			fmt.Printf("前回の実行で処理済み（再開により省略）: %d\n", stats.ResumedFiles)
+		}
 		if stats.SuccessFiles > 0 {
 			fmt.Printf("全体圧縮率: %.2f%%\n", stats.CompressionRatio)
 		}
@@ -203,6 +413,141 @@ func batchAction(c *cli.Context) error {
 	return nil
 }
 
+// formatResult は1ファイルの処理結果の表示内容を返します。表示する内容がない場合は空文字列を返します。
+// 拡張子と内容の不一致などの警告は常に表示し、verbose の場合は各ファイルの結果も表示します。
+func formatResult(result batch.Result, verbose bool) string {
+	var b strings.Builder
+	if result.Warning != "" {
+		fmt.Fprintf(&b, "⚠️  %s: %s\n", result.Job.InputPath, result.Warning)
+	}
+	if !verbose {
+		return b.String()
+	}
+
+	if result.Error != nil {
+		fmt.Fprintf(&b, "❌ %s: %v\n", result.Job.InputPath, result.Error)
+	} else if result.Cached {
+		fmt.Fprintf(&b, "⏭️  %s → %s (変更なし、キャッシュ済み)\n", result.Job.InputPath, result.Job.OutputPath)
+	} else if result.Resumed {
+		fmt.Fprintf(&b, "⏭️  %s → %s (前回の実行で処理済み)\n", result.Job.InputPath, result.Job.OutputPath)
+	} else if report := result.Report; report != nil {
+		var rule string
+		if result.Rule != "" {
+			rule = fmt.Sprintf(", ルール %s", result.Rule)
+		}
+		fmt.Fprintf(&b, "✅ %s → %s (%s %dx%d, %.2f%% 圧縮, デコード %v, エンコード %v%s)\n",
+			result.Job.InputPath,
+			result.Job.OutputPath,
+			report.Format,
+			report.Width,
+			report.Height,
+			report.CompressionRatio(),
+			report.DecodeDuration,
+			report.EncodeDuration,
+			rule)
+	}
+	return b.String()
+}
+
+// manifestPath は増分処理のマニフェストのパスを決定します。
+// --manifest が指定されていない場合は statePath で決定します。
+func manifestPath(manifest string, incremental bool, input, output string) (string, error) {
+	if manifest != "" || !incremental {
+		return manifest, nil
+	}
+	path, err := statePath(batch.ManifestFileName, "manifest", input, output)
+	if err != nil {
+		return "", fmt.Errorf("%w。--manifest で指定してください", err)
+	}
+	return path, nil
+}
+
+// statePath はマニフェストなど、実行をまたいで保持するファイルのパスを決定します。
+// 出力ディレクトリ（出力先がない場合は入力ディレクトリ）に fileName で保存します。
+// 出力先が S3 の場合は、ユーザーのキャッシュディレクトリに入力元・出力先・種類の組ごとのファイルとして保存します。
+// 出力先を省略した上書き処理では output が空になるため、入力元も含めて区別します。
+func statePath(fileName, kind, input, output string) (string, error) {
+	switch {
+	case output != "" && !isS3(output):
+		return filepath.Join(output, fileName), nil
+	case output == "" && !isS3(input):
+		return filepath.Join(input, fileName), nil
+	}
+
+	return cachePath(fileName, kind, input, output)
+}
+
+// journalPath はジャーナルのパスを決定します。
+// ジャーナルは中断した実行の再開にのみ使用するため、入力や出力のディレクトリには保存せず、
+// 入力元と出力先が読み取り専用でも記録できるよう、常にユーザーのキャッシュディレクトリに保存します。
+// 作業ディレクトリによらず同じ入力元・出力先の組を区別できるよう、ローカルのパスは絶対パスにします。
+func journalPath(input, output string) (string, error) {
+	for _, p := range []*string{&input, &output} {
+		if *p == "" || isS3(*p) {
+			continue
+		}
+		abs, err := filepath.Abs(*p)
+		if err != nil {
+			return "", fmt.Errorf("%s の保存先を決定できません: %w", batch.JournalFileName, err)
+		}
+		*p = abs
+	}
+	return cachePath(batch.JournalFileName, "journal", input, output)
+}
+
+// cachePath はユーザーのキャッシュディレクトリに、入力元・出力先・種類の組ごとのファイルのパスを返します。
+func cachePath(fileName, kind, input, output string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("%s の保存先を決定できません: %w", fileName, err)
+	}
+	sum := sha256.Sum256([]byte(kind + "\x00" + input + "\x00" + output))
+	ext := filepath.Ext(fileName)
+	return filepath.Join(cacheDir, "shuku", kind+"-"+hex.EncodeToString(sum[:8])+ext), nil
+}
+
+// loadRules は --rule と --rules-file からルールを読み込みます。
+// 最初に一致したルールが適用されるため、コマンドラインで指定したルールを設定ファイルのルールより先に並べます。
+func loadRules(specs []string, rulesFile string) ([]batch.Rule, error) {
+	var rules []batch.Rule
+	for _, spec := range specs {
+		rule, err := batch.ParseRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	if rulesFile != "" {
+		fileRules, err := batch.LoadRules(rulesFile)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fileRules...)
+	}
+	return rules, nil
+}
+
+// splitPatterns はカンマ区切りのパターンを分割し、各パターンの書式を検証します。
+func splitPatterns(value string) ([]string, error) {
+	patterns := strings.Split(value, ",")
+	for i, pattern := range patterns {
+		patterns[i] = strings.TrimSpace(pattern)
+		if err := glob.Validate(patterns[i]); err != nil {
+			return nil, fmt.Errorf("%s: %w", patterns[i], err)
+		}
+	}
+	return patterns, nil
+}
+
+// isArchive は入力がアーカイブファイル（zip/tar/tar.gz）かどうかを判定します。
+func isArchive(path string) bool {
+	if _, ok := batch.ArchiveFormat(path); !ok {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
 // boolToString converts bool to Japanese string
 func boolToString(b bool) string {
 	if b {
@@ -1,18 +1,34 @@
 package batch
 
 import (
+	"archive/zip"
 	"bytes"
 	"image"
 	"image/color"
 	"image/jpeg"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/takumines/shuku/internal/batch"
+	"github.com/takumines/shuku/pkg/shuku"
 	"github.com/urfave/cli/v2"
 )
 
+// TestMain keeps journals written by the tests out of the user's cache directory
+func TestMain(m *testing.M) {
+	cacheDir, err := os.MkdirTemp("", "shuku_cache")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CACHE_HOME", cacheDir)
+	code := m.Run()
+	os.RemoveAll(cacheDir)
+	os.Exit(code)
+}
+
 // createTestImageFile creates a test JPEG image file
 func createTestImageFile(t *testing.T, filePath string, width, height int) {
 	t.Helper()
@@ -154,7 +170,7 @@ func TestCmdStructure(t *testing.T) {
 	}
 
 	// Check flags count
-	expectedFlagCount := 10
+	expectedFlagCount := 29
 	if len(cmd.Flags) != expectedFlagCount {
 		t.Errorf("Command flags length = %v, want %v", len(cmd.Flags), expectedFlagCount)
 	}
@@ -178,8 +194,27 @@ func TestCmdFlags(t *testing.T) {
 		{"recursive", "bool", false, true},
 		{"include", "string", false, false},
 		{"exclude", "string", false, false},
+		{"ignore-case", "bool", false, false},
+		{"ignore-file", "string", false, false},
+		{"suffix", "string", false, false},
+		{"rule", "stringSlice", false, false},
+		{"rules-file", "string", false, false},
 		{"verbose", "bool", false, true},
 		{"stats", "bool", false, false},
+		{"no-progress", "bool", false, false},
+		{"fix-ext", "bool", false, false},
+		{"in-place", "bool", false, false},
+		{"backup-dir", "string", false, false},
+		{"backup-suffix", "string", false, false},
+		{"incremental", "bool", false, false},
+		{"manifest", "string", false, false},
+		{"dry-run", "bool", false, false},
+		{"estimate", "bool", false, false},
+		{"resume", "bool", false, false},
+		{"report-format", "string", false, false},
+		{"report-file", "string", false, false},
+		{"s3-endpoint", "string", false, false},
+		{"s3-region", "string", false, false},
 	}
 
 	for _, tt := range flagTests {
@@ -211,6 +246,13 @@ func TestCmdFlags(t *testing.T) {
 							t.Errorf("Flag %s should have alias", tt.name)
 						}
 					}
+				case *cli.StringSliceFlag:
+					if f.Name == tt.name && tt.flagType == "stringSlice" {
+						found = true
+						if tt.hasAlias && len(f.Aliases) == 0 {
+							t.Errorf("Flag %s should have alias", tt.name)
+						}
+					}
 				}
 			}
 			if !found {
@@ -239,7 +281,7 @@ func TestFlagDefaults(t *testing.T) {
 		}
 		if stringFlag, ok := flag.(*cli.StringFlag); ok {
 			if stringFlag.Name == "include" {
-				expected := "*.jpg,*.jpeg,*.png,*.webp"
+				expected := "*.jpeg,*.jpg,*.png,*.webp"
 				if stringFlag.Value != expected {
 					t.Errorf("Include pattern default = %v, want %v", stringFlag.Value, expected)
 				}
@@ -248,6 +290,42 @@ func TestFlagDefaults(t *testing.T) {
 	}
 }
 
+// TestIncludeDefaultRegisteredFormat tests that formats added with shuku.Register are included by default
+func TestIncludeDefaultRegisteredFormat(t *testing.T) {
+	shuku.Register("testfmt", testCompressor{})
+	t.Cleanup(func() { shuku.Unregister("testfmt") })
+
+	for _, flag := range Cmd().Flags {
+		if stringFlag, ok := flag.(*cli.StringFlag); ok && stringFlag.Name == "include" {
+			if !strings.Contains(stringFlag.Value, "*.testfmt") {
+				t.Errorf("Include pattern default = %v, want it to contain *.testfmt", stringFlag.Value)
+			}
+			return
+		}
+	}
+	t.Fatal("include flag not found")
+}
+
+// testCompressor is a no-op compressor registered for a custom format
+type testCompressor struct{}
+
+func (testCompressor) Compress(img image.Image, options shuku.Options) (image.Image, error) {
+	return img, nil
+}
+
+func (testCompressor) CompressBytes(data []byte, options shuku.Options) ([]byte, error) {
+	return data, nil
+}
+
+func (testCompressor) CompressReader(r io.Reader, w io.Writer, options shuku.Options) error {
+	_, err := io.Copy(w, r)
+	return err
+}
+
+func (testCompressor) SupportedFormat() string {
+	return "testfmt"
+}
+
 // TestBatchActionInputValidation tests input validation in batchAction
 func TestBatchActionInputValidation(t *testing.T) {
 	tests := []struct {
@@ -425,6 +503,19 @@ func TestBatchActionSuccess(t *testing.T) {
 				return strings.Contains(output, "バッチ圧縮が完了しました")
 			},
 		},
+		{
+			name: "resume without a previous journal",
+			args: []string{"batch", "--input", tempDir, "--output", outputDir, "--resume"},
+			checkOutput: func(output string) bool {
+				// The journal is removed once the run completes
+				journal, err := journalPath(tempDir, outputDir)
+				if err != nil {
+					return false
+				}
+				_, err = os.Stat(journal)
+				return strings.Contains(output, "バッチ圧縮が完了しました") && os.IsNotExist(err)
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -469,6 +560,172 @@ func TestBatchActionSuccess(t *testing.T) {
 	}
 }
 
+// TestBatchActionArchive tests batch processing of images inside an archive
+func TestBatchActionArchive(t *testing.T) {
+	tempDir := t.TempDir()
+	imagePath := filepath.Join(tempDir, "photo.jpg")
+	createTestImageFile(t, imagePath, 50, 50)
+	imageData, _ := os.ReadFile(imagePath)
+
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	for name, data := range map[string][]byte{"photo.jpg": imageData, "notes.txt": []byte("notes")} {
+		w, _ := zw.Create(name)
+		w.Write(data)
+	}
+	zw.Close()
+	inputPath := filepath.Join(tempDir, "assets.zip")
+	if err := os.WriteFile(inputPath, zipBuf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write test archive: %v", err)
+	}
+	outputPath := filepath.Join(tempDir, "assets-compressed.zip")
+
+	app := &cli.App{
+		Commands: []*cli.Command{Cmd()},
+	}
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	err := app.Run([]string{"test", "batch", "-i", inputPath, "-o", outputPath})
+	w.Close()
+	os.Stdout = oldStdout
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v, output: %s", err, buf.String())
+	}
+	if !strings.Contains(buf.String(), "処理ファイル数: 1 (成功: 1, 失敗: 0)") {
+		t.Errorf("Unexpected output: %s", buf.String())
+	}
+
+	zr, err := zip.OpenReader(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to open output archive: %v", err)
+	}
+	defer zr.Close()
+	if len(zr.File) != 2 {
+		t.Errorf("Output archive entries = %d, want 2", len(zr.File))
+	}
+}
+
+// TestManifestPath tests where the incremental manifest is stored
+func TestManifestPath(t *testing.T) {
+	tests := []struct {
+		name        string
+		manifest    string
+		incremental bool
+		input       string
+		output      string
+		expected    string
+	}{
+		{"disabled", "", false, "images", "out", ""},
+		{"explicit path", "cache/m.json", false, "images", "out", "cache/m.json"},
+		{"output directory", "", true, "images", "out", filepath.Join("out", ".shuku-manifest.json")},
+		{"input directory", "", true, "images", "", filepath.Join("images", ".shuku-manifest.json")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := manifestPath(tt.manifest, tt.incremental, tt.input, tt.output)
+			if err != nil {
+				t.Fatalf("manifestPath() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("manifestPath() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	t.Run("S3 output uses the cache directory", func(t *testing.T) {
+		t.Setenv("XDG_CACHE_HOME", t.TempDir())
+		first, err := manifestPath("", true, "images", "s3://assets/a")
+		if err != nil {
+			t.Skipf("cache directory unavailable: %v", err)
+		}
+		second, _ := manifestPath("", true, "images", "s3://assets/b")
+		if first == second || !strings.Contains(first, "shuku") {
+			t.Errorf("manifestPath() = %v, %v", first, second)
+		}
+	})
+}
+
+// TestStatePath tests where files kept across runs are stored
+func TestStatePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		output   string
+		expected string
+	}{
+		{"output directory", "images", "out", filepath.Join("out", ".shuku-manifest.json")},
+		{"input directory", "images", "", filepath.Join("images", ".shuku-manifest.json")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := statePath(batch.ManifestFileName, "manifest", tt.input, tt.output)
+			if err != nil {
+				t.Fatalf("statePath() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("statePath() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	t.Run("S3 output keeps each kind separately", func(t *testing.T) {
+		t.Setenv("XDG_CACHE_HOME", t.TempDir())
+		journal, err := statePath(batch.JournalFileName, "journal", "images", "s3://assets/a")
+		if err != nil {
+			t.Skipf("cache directory unavailable: %v", err)
+		}
+		manifest, _ := statePath(batch.ManifestFileName, "manifest", "images", "s3://assets/a")
+		if journal == manifest || filepath.Ext(journal) != ".jsonl" || filepath.Ext(manifest) != ".json" {
+			t.Errorf("statePath() = %v, %v", journal, manifest)
+		}
+	})
+
+	t.Run("S3 input without output keeps each input separately", func(t *testing.T) {
+		t.Setenv("XDG_CACHE_HOME", t.TempDir())
+		first, err := statePath(batch.ManifestFileName, "manifest", "s3://bucket-a/x", "")
+		if err != nil {
+			t.Skipf("cache directory unavailable: %v", err)
+		}
+		second, _ := statePath(batch.ManifestFileName, "manifest", "s3://bucket-b/y", "")
+		if first == second {
+			t.Errorf("statePath() = %v for both inputs, want different paths", first)
+		}
+		again, _ := statePath(batch.ManifestFileName, "manifest", "s3://bucket-a/x", "")
+		if again != first {
+			t.Errorf("statePath() = %v, want the same path %v for the same input", again, first)
+		}
+	})
+}
+
+// TestJournalPath tests that journals are kept in the cache directory rather than the input or output
+func TestJournalPath(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheDir)
+	journal, err := journalPath("images", "")
+	if err != nil {
+		t.Fatalf("journalPath() error = %v", err)
+	}
+	if !strings.HasPrefix(journal, cacheDir) || filepath.Ext(journal) != ".jsonl" {
+		t.Errorf("journalPath() = %v, want a .jsonl file under %v", journal, cacheDir)
+	}
+	dir, err := filepath.Abs("images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if abs, _ := journalPath(dir, ""); abs != journal {
+		t.Errorf("journalPath() = %v for the absolute path, want %v", abs, journal)
+	}
+	if other, _ := journalPath("images", "out"); other == journal {
+		t.Errorf("journalPath() = %v for a different output, want a different path", other)
+	}
+}
+
 // TestBatchActionNoFiles tests batch processing with no matching files
 func TestBatchActionNoFiles(t *testing.T) {
 	// Create empty directory
@@ -506,6 +763,50 @@ func TestBatchActionNoFiles(t *testing.T) {
 	}
 }
 
+// TestBatchActionVerboseOrder tests that verbose results are printed in walk order regardless of completion order
+func TestBatchActionVerboseOrder(t *testing.T) {
+	inputDir := t.TempDir()
+	// The first file is the largest so that later files finish first
+	names := []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg", "e.jpg", "f.jpg"}
+	for i, name := range names {
+		size := 40
+		if i == 0 {
+			size = 600
+		}
+		createTestImageFile(t, filepath.Join(inputDir, name), size, size)
+	}
+
+	app := &cli.App{
+		Commands: []*cli.Command{Cmd()},
+	}
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	err := app.Run([]string{"test", "batch", "--input", inputDir, "--output", t.TempDir(), "--workers", "4", "--verbose", "--no-progress"})
+
+	w.Close()
+	os.Stdout = oldStdout
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	if err != nil {
+		t.Fatalf("batch failed: %v\n%s", err, buf.String())
+	}
+
+	var printed []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "✅ ") {
+			input, _, _ := strings.Cut(strings.TrimPrefix(line, "✅ "), " → ")
+			printed = append(printed, filepath.Base(input))
+		}
+	}
+	if strings.Join(printed, ",") != strings.Join(names, ",") {
+		t.Errorf("printed results = %v, want %v", printed, names)
+	}
+}
+
 // TestBatchActionPatterns tests include/exclude patterns
 func TestBatchActionPatterns(t *testing.T) {
 	// Create temporary directory with test image
@@ -549,3 +850,67 @@ func TestBatchActionPatterns(t *testing.T) {
 		t.Errorf("Expected batch compression message, got: %s", output)
 	}
 }
+
+// TestSplitPatterns tests splitting and validating comma-separated patterns
+func TestSplitPatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected []string
+		wantErr  bool
+	}{
+		{name: "single pattern", value: "*.jpg", expected: []string{"*.jpg"}},
+		{name: "trims spaces", value: "*.jpg, static/img/*.png ,vendor/**", expected: []string{"*.jpg", "static/img/*.png", "vendor/**"}},
+		{name: "invalid pattern", value: "*.jpg,[", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitPatterns(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitPatterns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("splitPatterns() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestLoadRules tests combining --rule and --rules-file
+func TestLoadRules(t *testing.T) {
+	rulesFile := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(rulesFile, []byte(`{"rules": [{"pattern": "photos/**", "quality": 75}]}`), 0644); err != nil {
+		t.Fatalf("Failed to create rules file: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		specs     []string
+		rulesFile string
+		expected  []string
+		wantErr   bool
+	}{
+		{name: "no rules"},
+		{name: "flags only", specs: []string{"icons/**:copy", "hero/**:max-size=300KB"}, expected: []string{"icons/**", "hero/**"}},
+		{name: "flags before file", specs: []string{"icons/**:copy"}, rulesFile: rulesFile, expected: []string{"icons/**", "photos/**"}},
+		{name: "invalid flag", specs: []string{"icons/**:quality=abc"}, wantErr: true},
+		{name: "missing file", rulesFile: filepath.Join(t.TempDir(), "missing.json"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := loadRules(tt.specs, tt.rulesFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var patterns []string
+			for _, rule := range rules {
+				patterns = append(patterns, rule.Pattern)
+			}
+			if strings.Join(patterns, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("loadRules() = %v, want %v", patterns, tt.expected)
+			}
+		})
+	}
+}
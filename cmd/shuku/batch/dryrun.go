@@ -0,0 +1,92 @@
+package batch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/takumines/shuku/internal/batch"
+	"github.com/takumines/shuku/pkg/shuku"
+	"github.com/urfave/cli/v2"
+)
+
+// estimateSamples は --estimate で形式ごとに圧縮するファイル数の上限です。
+const estimateSamples = 20
+
+// dryRun はファイルを書き込まずに処理対象のファイルと出力先、問題点を表示します。
+// estimate が true の場合は、形式ごとにサンプルを圧縮して削減量の見積もりも表示します。
+func dryRun(c *cli.Context, processor *batch.Processor, input, output string, options shuku.Options, estimate bool) error {
+	var plan *batch.Plan
+	var err error
+	if isS3(input) || isS3(output) {
+		src, dst, openErr := openStorage(c, input, output)
+		if openErr != nil {
+			return openErr
+		}
+		plan, err = processor.PlanFSContext(c.Context, src, dst, options)
+	} else {
+		plan, err = processor.PlanDirectoryContext(c.Context, input, options)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("ドライラン: ファイルは書き込まれません")
+	if len(plan.Jobs) == 0 {
+		fmt.Println("処理対象のファイルが見つかりませんでした。")
+		return nil
+	}
+
+	printPlan(plan)
+
+	if estimate {
+		estimates, err := plan.EstimateContext(c.Context, estimateSamples)
+		if err != nil {
+			return err
+		}
+		printEstimates(estimates)
+	}
+	return nil
+}
+
+// printPlan は各ジョブの入力と出力先、問題点、全体の件数を表示します。
+func printPlan(plan *batch.Plan) {
+	var totalSize int64
+	conflicts := 0
+	for _, job := range plan.Jobs {
+		totalSize += job.Size
+		format := job.Format
+		if format == "" {
+			format = "不明"
+		}
+		line := fmt.Sprintf("%s → %s (%s, %s)", job.InputPath, job.OutputPath, format, formatFileSize(job.Size))
+		if rule := job.RuleName(); rule != "" {
+			line = fmt.Sprintf("%s → %s (%s, %s, ルール %s)", job.InputPath, job.OutputPath, format, formatFileSize(job.Size), rule)
+		}
+		if len(job.Conflicts) == 0 {
+			fmt.Printf("   %s\n", line)
+			continue
+		}
+		conflicts++
+		fmt.Printf("⚠️  %s: %s\n", line, strings.Join(job.Conflicts, "、"))
+	}
+
+	fmt.Printf("\n対象ファイル数: %d (合計 %s)\n", len(plan.Jobs), formatFileSize(totalSize))
+	if conflicts > 0 {
+		fmt.Printf("確認が必要なファイル: %d\n", conflicts)
+	}
+}
+
+// printEstimates は形式ごとの削減量の見積もりと合計を表示します。
+func printEstimates(estimates []batch.FormatEstimate) {
+	fmt.Printf("\n=== 削減量の見積もり（形式ごとに最大 %d ファイルを圧縮） ===\n", estimateSamples)
+	var total batch.FormatEstimate
+	for _, e := range estimates {
+		fmt.Printf("%s: %d ファイル, %s → 約 %s (約 %.2f%% 削減, サンプル %d)\n",
+			e.Format, e.Files, formatFileSize(e.OriginalSize), formatFileSize(e.EstimatedSize), e.CompressionRatio(), e.SampledFiles)
+		total.Files += e.Files
+		total.OriginalSize += e.OriginalSize
+		total.EstimatedSize += e.EstimatedSize
+	}
+	fmt.Printf("合計: %d ファイル, %s → 約 %s (約 %.2f%% 削減)\n",
+		total.Files, formatFileSize(total.OriginalSize), formatFileSize(total.EstimatedSize), total.CompressionRatio())
+}
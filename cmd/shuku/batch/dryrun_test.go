@@ -0,0 +1,73 @@
+package batch
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+// TestBatchActionDryRun tests that --dry-run and --estimate report jobs without writing anything
+func TestBatchActionDryRun(t *testing.T) {
+	inputDir := t.TempDir()
+	createTestImageFile(t, filepath.Join(inputDir, "photo.jpg"), 50, 50)
+	createTestImageFile(t, filepath.Join(inputDir, "other.jpg"), 40, 40)
+	outputDir := filepath.Join(t.TempDir(), "out")
+
+	tests := []struct {
+		name     string
+		args     []string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "dry run",
+			args:     []string{"batch", "-i", inputDir, "-o", outputDir, "--dry-run"},
+			contains: []string{"ドライラン", filepath.Join(outputDir, "photo.jpg"), "対象ファイル数: 2"},
+			excludes: []string{"削減量の見積もり", "バッチ圧縮を開始しています"},
+		},
+		{
+			name:     "estimate",
+			args:     []string{"batch", "-i", inputDir, "-o", outputDir, "--estimate"},
+			contains: []string{"ドライラン", "=== 削減量の見積もり", "jpeg: 2 ファイル", "合計: 2 ファイル"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &cli.App{
+				Commands: []*cli.Command{Cmd()},
+			}
+			oldStdout := os.Stdout
+			r, w, _ := os.Pipe()
+			os.Stdout = w
+			err := app.Run(append([]string{"test"}, tt.args...))
+			w.Close()
+			os.Stdout = oldStdout
+			var buf bytes.Buffer
+			io.Copy(&buf, r)
+			output := buf.String()
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v, output: %s", err, output)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(output, want) {
+					t.Errorf("Output should contain %q. Output: %s", want, output)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(output, unwanted) {
+					t.Errorf("Output should not contain %q. Output: %s", unwanted, output)
+				}
+			}
+			if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+				t.Errorf("Dry run created the output directory: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package batch
+
+import "sort"
+
+// orderedPrinter は完了した順に届く結果の表示を、探索した順（Job.Seq の順）に並べ替えて出力します。
+// 先行する結果がすべて届くまで、後の結果の表示は保留されます。
+//
+// 保留するのは整形済みの表示内容のみで、表示する内容のない結果は空文字列として順番だけを記録します。
+// 保留される件数は、処理に時間のかかるファイルを待つ間に完了したファイルの数に比例します。
+// 表示の順序を保つために、処理全体の結果を保持しない場合も、この分のメモリを使用します。
+type orderedPrinter struct {
+	print   func(string)
+	next    int            // 次に表示する結果の Job.Seq
+	pending map[int]string // 先行する結果を待っている表示内容
+}
+
+// newOrderedPrinter は print で表示内容を出力する orderedPrinter を作成します。
+func newOrderedPrinter(print func(string)) *orderedPrinter {
+	return &orderedPrinter{print: print, pending: map[int]string{}}
+}
+
+// Add は seq 番目の結果の表示内容を受け取り、表示できるようになった内容を順に出力します。
+// 表示する内容がない結果も、順番を進めるため空文字列で渡します。
+func (o *orderedPrinter) Add(seq int, text string) {
+	if seq != o.next {
+		o.pending[seq] = text
+		return
+	}
+	for {
+		if text != "" {
+			o.print(text)
+		}
+		o.next++
+		var ok bool
+		if text, ok = o.pending[o.next]; !ok {
+			return
+		}
+		delete(o.pending, o.next)
+	}
+}
+
+// Flush は保留中の表示内容を探索した順にすべて出力します。
+// 中断などで先行する結果が届かなかった場合に、残りの結果を失わないよう処理の終了時に呼び出します。
+func (o *orderedPrinter) Flush() {
+	seqs := make([]int, 0, len(o.pending))
+	for seq := range o.pending {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	for _, seq := range seqs {
+		if text := o.pending[seq]; text != "" {
+			o.print(text)
+		}
+		delete(o.pending, seq)
+	}
+}
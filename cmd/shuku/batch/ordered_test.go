@@ -0,0 +1,40 @@
+package batch
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestOrderedPrinter tests that output is printed in sequence order and only printable text is kept
+func TestOrderedPrinter(t *testing.T) {
+	var printed []string
+	printer := newOrderedPrinter(func(text string) {
+		printed = append(printed, text)
+	})
+
+	printer.Add(2, "c")
+	printer.Add(1, "")
+	printer.Add(3, "d")
+	if len(printed) != 0 {
+		t.Fatalf("printed %v before the first result arrived", printed)
+	}
+	if len(printer.pending) != 3 {
+		t.Errorf("pending = %v, want 3 entries", printer.pending)
+	}
+
+	printer.Add(0, "a")
+	if got := strings.Join(printed, ","); got != "a,c,d" {
+		t.Errorf("printed = %v, want a,c,d", got)
+	}
+	if len(printer.pending) != 0 {
+		t.Errorf("pending = %v, want empty", printer.pending)
+	}
+
+	// Results after a missing one are printed by Flush
+	printer.Add(6, "g")
+	printer.Add(5, "f")
+	printer.Flush()
+	if got := strings.Join(printed, ","); got != "a,c,d,f,g" {
+		t.Errorf("printed = %v, want a,c,d,f,g", got)
+	}
+}
@@ -0,0 +1,131 @@
+package batch
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/takumines/shuku/internal/batch"
+)
+
+const (
+	progressBarWidth    = 30
+	terminalRedrawDelay = 100 * time.Millisecond // 端末でプログレスバーを再描画する最小間隔
+	logLineInterval     = 5 * time.Second        // 端末以外に進捗を出力する間隔
+)
+
+// isTerminal は f が端末かどうかを判定します。
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// progressPrinter は進捗イベントを表示します。
+// 端末ではプログレスバーを同じ行に再描画し、端末以外では一定間隔で進捗を1行ずつ出力します。
+type progressPrinter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	tty      bool
+	interval time.Duration
+	now      func() time.Time
+
+	last     time.Time // 最後に表示した時刻
+	drawn    bool      // プログレスバーを表示中かどうか（端末のみ）
+	progress batch.Progress
+}
+
+// newProgressPrinter は w に進捗を表示する progressPrinter を作成します。
+func newProgressPrinter(w io.Writer, tty bool) *progressPrinter {
+	interval := logLineInterval
+	if tty {
+		interval = terminalRedrawDelay
+	}
+	p := &progressPrinter{w: w, tty: tty, interval: interval, now: time.Now}
+	p.last = p.now()
+	return p
+}
+
+// Handle は進捗イベントを受け取り、前回の表示から一定時間が経過していれば進捗を表示します。
+func (p *progressPrinter) Handle(event batch.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.progress = event.Progress
+	now := p.now()
+	if now.Sub(p.last) < p.interval {
+		return
+	}
+	p.last = now
+
+	if p.tty {
+		p.draw()
+	} else {
+		fmt.Fprintf(p.w, "進捗: %s\n", formatProgress(p.progress, false))
+	}
+}
+
+// Print はプログレスバーを一時的に消去して print を実行し、出力が崩れないようにします。
+func (p *progressPrinter) Print(print func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.drawn {
+		fmt.Fprint(p.w, "\r\033[K")
+	}
+	print()
+	if p.drawn {
+		p.draw()
+	}
+}
+
+// Finish は端末に最終的な進捗を表示して改行します。
+func (p *progressPrinter) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.drawn {
+		p.draw()
+		fmt.Fprintln(p.w)
+		p.drawn = false
+	}
+}
+
+// draw はプログレスバーを現在の行に描画します。p.mu を保持した状態で呼び出します。
+func (p *progressPrinter) draw() {
+	fmt.Fprintf(p.w, "\r\033[K%s", formatProgress(p.progress, true))
+	p.drawn = true
+}
+
+// formatProgress は進捗を1行の文字列にします。bar が true で総数が確定している場合はバーを含めます。
+func formatProgress(progress batch.Progress, bar bool) string {
+	var b strings.Builder
+	if progress.ScanCompleted {
+		percent := 100
+		if progress.Found > 0 {
+			percent = progress.Finished * 100 / progress.Found
+		}
+		if bar {
+			filled := percent * progressBarWidth / 100
+			fmt.Fprintf(&b, "[%s%s] ", strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled))
+		}
+		fmt.Fprintf(&b, "%d/%d (%d%%)", progress.Finished, progress.Found, percent)
+	} else {
+		fmt.Fprintf(&b, "%d/%d+ (探索中)", progress.Finished, progress.Found)
+	}
+
+	if progress.Failed > 0 {
+		fmt.Fprintf(&b, " 失敗: %d", progress.Failed)
+	}
+	if saved := progress.SavedBytes(); saved >= 0 {
+		fmt.Fprintf(&b, " 削減: %s", formatFileSize(saved))
+	} else {
+		fmt.Fprintf(&b, " 削減: -%s", formatFileSize(-saved))
+	}
+	if eta, ok := progress.ETA(); ok {
+		fmt.Fprintf(&b, " 残り: %v", eta.Round(time.Second))
+	}
+	return b.String()
+}
@@ -0,0 +1,108 @@
+package batch
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/takumines/shuku/internal/batch"
+)
+
+// newTestProgressPrinter は時刻を clock で制御する progressPrinter を作成します。
+func newTestProgressPrinter(tty bool, clock *time.Time) (*progressPrinter, *bytes.Buffer) {
+	var buf bytes.Buffer
+	p := newProgressPrinter(&buf, tty)
+	p.now = func() time.Time { return *clock }
+	p.last = *clock
+	return p, &buf
+}
+
+func TestProgressPrinter_Log(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p, buf := newTestProgressPrinter(false, &clock)
+
+	event := batch.Event{Kind: batch.EventFileFinished, Progress: batch.Progress{Found: 3, Finished: 1}}
+	p.Handle(event)
+	if buf.Len() != 0 {
+		t.Errorf("間隔の経過前に出力されました: %q", buf.String())
+	}
+
+	clock = clock.Add(logLineInterval)
+	p.Handle(event)
+	p.Handle(event)
+	if got := strings.Count(buf.String(), "進捗: "); got != 1 {
+		t.Errorf("進捗の出力行数 = %d, want 1: %q", got, buf.String())
+	}
+	if strings.Contains(buf.String(), "\r") {
+		t.Errorf("端末以外にプログレスバーの制御文字が出力されました: %q", buf.String())
+	}
+
+	p.Finish()
+	if got := strings.Count(buf.String(), "\n"); got != 1 {
+		t.Errorf("Finish() で出力されました: %q", buf.String())
+	}
+}
+
+func TestProgressPrinter_Terminal(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p, buf := newTestProgressPrinter(true, &clock)
+
+	clock = clock.Add(terminalRedrawDelay)
+	p.Handle(batch.Event{Progress: batch.Progress{Found: 4, ScanCompleted: true, Finished: 2}})
+	if !strings.HasPrefix(buf.String(), "\r\033[K[") || !strings.Contains(buf.String(), "2/4 (50%)") {
+		t.Errorf("プログレスバー = %q", buf.String())
+	}
+
+	// 結果の表示中はバーを消去し、表示後に再描画する
+	buf.Reset()
+	p.Print(func() { fmt.Fprintln(buf, "結果") })
+	if want := "\r\033[K結果\n\r\033[K["; !strings.HasPrefix(buf.String(), want) {
+		t.Errorf("Print() の出力 = %q, want prefix %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	p.Finish()
+	if !strings.HasSuffix(buf.String(), "\n") {
+		t.Errorf("Finish() で改行されませんでした: %q", buf.String())
+	}
+}
+
+func TestFormatProgress(t *testing.T) {
+	tests := []struct {
+		name     string
+		progress batch.Progress
+		bar      bool
+		want     []string
+	}{
+		{
+			name:     "探索中",
+			progress: batch.Progress{Found: 10, Finished: 3, OriginalBytes: 2048, CompressedBytes: 1024},
+			bar:      true,
+			want:     []string{"3/10+ (探索中)", "削減: 1.0 KB"},
+		},
+		{
+			name:     "探索完了",
+			progress: batch.Progress{Found: 10, ScanCompleted: true, Finished: 5, Failed: 1, Elapsed: 10 * time.Second},
+			bar:      true,
+			want:     []string{"[" + strings.Repeat("=", progressBarWidth/2), "5/10 (50%)", "失敗: 1", "残り: 10s"},
+		},
+		{
+			name:     "サイズの増加",
+			progress: batch.Progress{Found: 1, ScanCompleted: true, Finished: 1, OriginalBytes: 100, CompressedBytes: 150},
+			want:     []string{"1/1 (100%)", "削減: -50 B"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatProgress(tt.progress, tt.bar)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("formatProgress() = %q, want %q を含む", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package batch
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/takumines/shuku/internal/batch"
+	"github.com/takumines/shuku/internal/fileutil"
+)
+
+// reportExtensions はレポートファイルの拡張子と形式の対応です。
+var reportExtensions = map[string]string{
+	".json": batch.ReportJSON,
+	".csv":  batch.ReportCSV,
+	".xml":  batch.ReportJUnit,
+}
+
+// parseReportFormat は --report-format と --report-file からレポートの形式を決定します。
+// 形式が省略された場合はファイルの拡張子から判定し、レポートを出力しない場合は空文字列を返します。
+func parseReportFormat(format, file string) (string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if file == "" {
+		if format != "" {
+			return "", errors.New("--report-format を指定する場合は --report-file で出力先を指定してください。")
+		}
+		return "", nil
+	}
+
+	if format == "" {
+		inferred, ok := reportExtensions[strings.ToLower(filepath.Ext(file))]
+		if !ok {
+			return "", fmt.Errorf("レポートの形式を判定できません: %s（--report-format で %s のいずれかを指定してください）",
+				file, strings.Join(batch.ReportFormats(), ", "))
+		}
+		return inferred, nil
+	}
+	if !slices.Contains(batch.ReportFormats(), format) {
+		return "", fmt.Errorf("サポートされていないレポート形式です: %s（%s のいずれかを指定してください）",
+			format, strings.Join(batch.ReportFormats(), ", "))
+	}
+	return format, nil
+}
+
+// writeReport はレポートを path に書き込みます。
+// 途中まで書き込まれたレポートが残らないよう、一時ファイルに書き込んでから置き換えます。
+func writeReport(path, format string, results []batch.Result, stats batch.Statistics) error {
+	return fileutil.WriteFileAtomic(path, 0644, func(w io.Writer) error {
+		return batch.WriteReport(w, format, results, stats)
+	})
+}
@@ -0,0 +1,139 @@
+package batch
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+// TestParseReportFormat tests how the report format is resolved from the flags
+func TestParseReportFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		file    string
+		want    string
+		wantErr bool
+	}{
+		{name: "no report", want: ""},
+		{name: "explicit format", format: "CSV", file: "report.txt", want: "csv"},
+		{name: "inferred json", file: "report.json", want: "json"},
+		{name: "inferred junit", file: "out/junit.XML", want: "junit"},
+		{name: "unknown extension", file: "report.txt", wantErr: true},
+		{name: "unsupported format", format: "yaml", file: "report.yaml", wantErr: true},
+		{name: "format without file", format: "json", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseReportFormat(tt.format, tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseReportFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseReportFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBatchActionReport tests that the report is written for every processed file
+func TestBatchActionReport(t *testing.T) {
+	inputDir := t.TempDir()
+	createTestImageFile(t, filepath.Join(inputDir, "b.jpg"), 50, 50)
+	createTestImageFile(t, filepath.Join(inputDir, "a.jpg"), 40, 40)
+	if err := os.WriteFile(filepath.Join(inputDir, "broken.jpg"), []byte("not an image"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	outputDir := filepath.Join(t.TempDir(), "out")
+	reportDir := t.TempDir()
+
+	run := func(t *testing.T, args ...string) error {
+		t.Helper()
+		app := &cli.App{
+			Commands: []*cli.Command{Cmd()},
+			ExitErrHandler: func(c *cli.Context, err error) {
+				// Skip os.Exit during tests
+			},
+		}
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		err := app.Run(append([]string{"test", "batch", "-i", inputDir, "-o", outputDir, "--no-progress"}, args...))
+		w.Close()
+		os.Stdout = oldStdout
+		io.Copy(io.Discard, r)
+		return err
+	}
+
+	t.Run("json", func(t *testing.T) {
+		path := filepath.Join(reportDir, "report.json")
+		// broken.jpg fails, so the command exits with an error but still writes the report
+		if err := run(t, "--report-file", path); err == nil {
+			t.Fatal("Expected an error for the broken file")
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Report was not written: %v", err)
+		}
+		var report struct {
+			Statistics struct {
+				TotalFiles  int `json:"total_files"`
+				FailedFiles int `json:"failed_files"`
+			} `json:"statistics"`
+			Results []struct {
+				InputPath string `json:"input_path"`
+				Status    string `json:"status"`
+				Error     string `json:"error"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal(data, &report); err != nil {
+			t.Fatalf("Invalid JSON report: %v\n%s", err, data)
+		}
+		if report.Statistics.TotalFiles != 3 || report.Statistics.FailedFiles != 1 {
+			t.Errorf("Statistics = %+v", report.Statistics)
+		}
+		wantOrder := []string{"a.jpg", "b.jpg", "broken.jpg"}
+		if len(report.Results) != len(wantOrder) {
+			t.Fatalf("Results = %+v", report.Results)
+		}
+		for i, name := range wantOrder {
+			if got := report.Results[i]; got.InputPath != filepath.Join(inputDir, name) {
+				t.Errorf("Results[%d] = %s, want %s", i, got.InputPath, name)
+			}
+		}
+		if report.Results[2].Status != "failed" || report.Results[2].Error == "" {
+			t.Errorf("Failed file in report = %+v", report.Results[2])
+		}
+	})
+
+	t.Run("junit", func(t *testing.T) {
+		path := filepath.Join(reportDir, "report.out")
+		_ = run(t, "--report-format", "junit", "--report-file", path)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Report was not written: %v", err)
+		}
+		var suites struct {
+			Tests    int `xml:"tests,attr"`
+			Failures int `xml:"failures,attr"`
+		}
+		if err := xml.Unmarshal(data, &suites); err != nil {
+			t.Fatalf("Invalid JUnit report: %v\n%s", err, data)
+		}
+		if suites.Tests != 3 || suites.Failures != 1 {
+			t.Errorf("testsuites = %+v", suites)
+		}
+		if !strings.Contains(string(data), "<failure") {
+			t.Errorf("Report should contain a failure element: %s", data)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package batch
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/takumines/shuku/internal/batch"
+	"github.com/takumines/shuku/internal/storage"
+	"github.com/takumines/shuku/pkg/shuku"
+	"github.com/urfave/cli/v2"
+)
+
+// isS3 は入力・出力の指定が S3 のURL（s3://bucket/prefix）かどうかを判定します。
+func isS3(location string) bool {
+	_, _, ok := storage.ParseS3URL(location)
+	return ok
+}
+
+// s3Config は S3 のURLと --s3-endpoint・--s3-region の指定から接続設定を作成します。
+// 認証情報は AWS CLI と同じ環境変数から読み込み、フラグの指定は環境変数より優先します。
+func s3Config(location, endpoint, region string) (storage.S3Config, error) {
+	bucket, prefix, ok := storage.ParseS3URL(location)
+	if !ok {
+		return storage.S3Config{}, fmt.Errorf("S3 のURLが不正です: %s", location)
+	}
+
+	config := storage.S3ConfigFromEnv()
+	config.Bucket = bucket
+	config.Prefix = prefix
+	if endpoint != "" {
+		config.Endpoint = endpoint
+		config.PathStyle = true
+	}
+	if region != "" {
+		config.Region = region
+	}
+	return config, nil
+}
+
+// processStorage は入力・出力のどちらかに S3 が指定された場合のバッチ処理を実行します。
+// 各ファイルの結果は完了した順に fn に渡します。
+func processStorage(c *cli.Context, processor *batch.Processor, input, output string, options shuku.Options, fn func(batch.Result) error) error {
+	src, dst, err := openStorage(c, input, output)
+	if err != nil {
+		return err
+	}
+	return processor.StreamFSContext(c.Context, src, dst, options, fn)
+}
+
+// openStorage は入力・出力のどちらかに S3 が指定された場合の入力元と出力先を作成します。
+// S3 の入力では出力先の指定が必要で、上書きモードは使用できません。
+func openStorage(c *cli.Context, input, output string) (fs.FS, storage.Storage, error) {
+	if c.Bool("in-place") {
+		return nil, nil, fmt.Errorf("--in-place は S3 と組み合わせて指定できません")
+	}
+	if output == "" {
+		return nil, nil, fmt.Errorf("S3 の入力には --output で出力先を指定してください")
+	}
+
+	var src fs.FS
+	if isS3(input) {
+		config, err := s3Config(input, c.String("s3-endpoint"), c.String("s3-region"))
+		if err != nil {
+			return nil, nil, err
+		}
+		s3, err := storage.NewS3Storage(config)
+		if err != nil {
+			return nil, nil, err
+		}
+		src = s3
+	} else {
+		src = os.DirFS(input)
+	}
+
+	var dst storage.Storage
+	if isS3(output) {
+		config, err := s3Config(output, c.String("s3-endpoint"), c.String("s3-region"))
+		if err != nil {
+			return nil, nil, err
+		}
+		s3, err := storage.NewS3Storage(config)
+		if err != nil {
+			return nil, nil, err
+		}
+		dst = s3
+	} else {
+		dst = storage.NewDirStorage(output)
+	}
+	return src, dst, nil
+}
@@ -0,0 +1,68 @@
+package batch
+
+import "testing"
+
+func TestS3Config(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_REGION", "ap-northeast-1")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_ENDPOINT_URL_S3", "")
+	t.Setenv("AWS_ENDPOINT_URL", "")
+
+	tests := []struct {
+		name          string
+		location      string
+		endpoint      string
+		region        string
+		wantBucket    string
+		wantPrefix    string
+		wantRegion    string
+		wantPathStyle bool
+		wantErr       bool
+	}{
+		{
+			name:       "環境変数の設定を使用",
+			location:   "s3://assets/images",
+			wantBucket: "assets",
+			wantPrefix: "images",
+			wantRegion: "ap-northeast-1",
+		},
+		{
+			name:          "フラグの指定を優先",
+			location:      "s3://assets",
+			endpoint:      "http://localhost:9000",
+			region:        "us-west-2",
+			wantBucket:    "assets",
+			wantRegion:    "us-west-2",
+			wantPathStyle: true,
+		},
+		{
+			name:     "S3 のURLではない",
+			location: "./images",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := s3Config(tt.location, tt.endpoint, tt.region)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("s3Config() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if config.Bucket != tt.wantBucket || config.Prefix != tt.wantPrefix {
+				t.Errorf("Bucket = %q, Prefix = %q, want %q, %q", config.Bucket, config.Prefix, tt.wantBucket, tt.wantPrefix)
+			}
+			if config.Region != tt.wantRegion || config.PathStyle != tt.wantPathStyle {
+				t.Errorf("Region = %q, PathStyle = %v, want %q, %v", config.Region, config.PathStyle, tt.wantRegion, tt.wantPathStyle)
+			}
+			if config.AccessKeyID != "env-key" || config.SecretAccessKey != "env-secret" {
+				t.Errorf("認証情報が環境変数から読み込まれていません: %+v", config)
+			}
+		})
+	}
+}
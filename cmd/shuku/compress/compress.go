@@ -6,49 +6,62 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/takumines/shuku/internal/backup"
 	"github.com/takumines/shuku/pkg/shuku"
 
 	"github.com/urfave/cli/v2"
 )
 
-// サポートされている画像形式のリスト
-var supportedFormats = []string{
-	".jpg",
-	".jpeg",
-	".png",
-	".webp",
-}
-
-// サポートされている形式名（エラーメッセージ用）
-var supportedFormatNames = []string{
-	"JPEG",
-	"PNG",
-	"WebP",
+// 組み込み形式の表示名（エラーメッセージ用）
+var formatDisplayNames = map[string]string{
+	"jpeg": "JPEG",
+	"png":  "PNG",
+	"webp": "WebP",
 }
 
 // isFormatSupported は指定された拡張子がサポートされているかどうかを判定します
 func isFormatSupported(ext string) bool {
-	ext = strings.ToLower(ext)
-	for _, format := range supportedFormats {
-		if ext == format {
-			return true
-		}
+	if strings.TrimPrefix(ext, ".") == "" {
+		return false
 	}
-	return false
+	_, ok := shuku.Lookup(ext)
+	return ok
 }
 
-// validateImageFormat は画像形式を検証し、サポートされていない場合はエラーを返します
-func validateImageFormat(inputPath string) error {
+// validateImageFormat は画像形式を検証し、判定した形式を返します
+// 形式はファイルの内容から判定し、判定できない場合は拡張子を使用します
+func validateImageFormat(inputPath string) (string, error) {
+	if format, err := shuku.DetectFileFormat(inputPath); err == nil {
+		return format, nil
+	}
+
 	ext := filepath.Ext(inputPath)
 	if !isFormatSupported(ext) {
-		return fmt.Errorf("サポートされていない画像形式です: %s。現在は%sに対応しています。", ext, getSupportedFormatsMessage())
+		return "", fmt.Errorf("サポートされていない画像形式です: %s。現在は%sに対応しています。", ext, getSupportedFormatsMessage())
 	}
-	return nil
+	return strings.ToLower(strings.TrimPrefix(ext, ".")), nil
 }
 
 // getSupportedFormatsMessage はサポートされている形式のメッセージを生成します
+// 登録済みのコンプレッサーから、別名（jpgなど）を除いた形式名を列挙します。
 func getSupportedFormatsMessage() string {
-	return strings.Join(supportedFormatNames, "、") + "形式"
+	var names []string
+	seen := map[string]bool{}
+	for _, format := range shuku.Formats() {
+		comp, _ := shuku.Lookup(format)
+		canonical := strings.ToLower(comp.SupportedFormat())
+		if seen[canonical] {
+			continue
+		}
+		seen[canonical] = true
+
+		name, ok := formatDisplayNames[canonical]
+		if !ok {
+			name = strings.ToUpper(canonical)
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, "、") + "形式"
 }
 
 // Cmd returns the compress command.
@@ -72,9 +85,30 @@ func Cmd() *cli.Command {
 			&cli.IntFlag{
 				Name:    "quality",
 				Aliases: []string{"q"},
-				Usage:   "JPEG quality (0-100)",
+				Usage:   "JPEG/WebP quality (0-100)",
 				Value:   80,
 			},
+			&cli.BoolFlag{
+				Name:  "in-place",
+				Usage: "Overwrite the input file atomically with the compressed result",
+			},
+			&cli.StringFlag{
+				Name:  "backup-dir",
+				Usage: "Directory to keep a copy of the original file before overwriting (with --in-place)",
+			},
+			&cli.StringFlag{
+				Name:  "backup-suffix",
+				Usage: "Suffix for a copy of the original file kept next to it, e.g. '.orig' (with --in-place)",
+			},
+			&cli.BoolFlag{
+				Name:  "fix-ext",
+				Usage: "Rename the output to match the detected image format when the extension is wrong",
+			},
+			&cli.GenericFlag{
+				Name:  "variant",
+				Usage: "Output spec decoded once and encoded per spec, repeatable (e.g. 'path=thumb.webp,width=200,height=200,quality=70,format=webp')",
+				Value: &variantList{},
+			},
 			&cli.BoolFlag{
 				Name:    "verbose",
 				Aliases: []string{"v"},
@@ -99,8 +133,22 @@ func compressAction(c *cli.Context) error {
 		return cli.Exit(fmt.Sprintf("入力ファイル '%s' が見つかりません。", inputPath), 1)
 	}
 
+	// 出力指定がある場合は、一度のデコードで複数の出力を生成
+	if variants := c.Generic("variant").(*variantList); len(variants.values) > 0 {
+		return compressVariants(c, inputPath, variants.values)
+	}
+
 	// 出力ファイルパスを取得または生成
 	outputPath := c.String("output")
+	backupConfig := backup.Config{Dir: c.String("backup-dir"), Suffix: c.String("backup-suffix")}
+	if c.Bool("in-place") {
+		if outputPath != "" {
+			return cli.Exit("--in-place と --output は同時に指定できません。", 1)
+		}
+		outputPath = inputPath
+	} else if backupConfig.Enabled() {
+		return cli.Exit("--backup-dir と --backup-suffix は --in-place と組み合わせて指定してください。", 1)
+	}
 	if outputPath == "" {
 		// デフォルトの出力ファイル名を生成
 		ext := filepath.Ext(inputPath)
@@ -113,6 +161,24 @@ func compressAction(c *cli.Context) error {
 		Quality:     c.Int("quality"),
 		PaletteSize: 256, // PNGの場合に使用
 	}
+	if err := options.Validate(); err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	// ファイルの内容から形式を判断
+	format, err := validateImageFormat(inputPath)
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	// 拡張子と内容が一致しない場合は警告
+	if !shuku.ExtensionMatchesFormat(inputPath, format) {
+		fmt.Printf("警告: 入力ファイルの拡張子(%s)と内容(%s)が一致しません。\n", filepath.Ext(inputPath), format)
+		if c.Bool("fix-ext") {
+			outputPath = shuku.CorrectExtension(outputPath, format)
+			fmt.Printf("出力ファイル名を形式に合わせて変更します: %s\n", outputPath)
+		}
+	}
 
 	// 詳細表示モードが有効な場合
 	verbose := c.Bool("verbose")
@@ -122,34 +188,60 @@ func compressAction(c *cli.Context) error {
 		fmt.Printf("圧縮品質: %d\n", options.Quality)
 	}
 
-	// ファイル拡張子から形式を判断
-	if err := validateImageFormat(inputPath); err != nil {
-		return cli.Exit(err.Error(), 1)
+	fmt.Println("画像を圧縮しています...")
+
+	// 上書きモードで拡張子を修正する場合は、別名で書き込んだ後に元ファイルを削除する
+	renamed := c.Bool("in-place") && outputPath != inputPath
+	if renamed {
+		if _, err := os.Stat(outputPath); err == nil {
+			return cli.Exit(fmt.Sprintf("拡張子を修正した出力先のファイルが既に存在します: %s", outputPath), 1)
+		}
 	}
 
-	fmt.Println("画像を圧縮しています...")
+	// 上書き前に元ファイルを退避
+	if c.Bool("in-place") && backupConfig.Enabled() {
+		backupPath, err := backupConfig.Path(filepath.Dir(inputPath), inputPath)
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("バックアップエラー: %v", err), 1)
+		}
+		if err := backup.Save(inputPath, backupPath); err != nil {
+			return cli.Exit(fmt.Sprintf("バックアップエラー: %v", err), 1)
+		}
+		// restore で別名のファイルを削除できるよう、変更後の名前を記録
+		if renamed {
+			if err := backup.SaveRename(backupPath, outputPath); err != nil {
+				return cli.Exit(fmt.Sprintf("バックアップエラー: %v", err), 1)
+			}
+		}
+		if verbose {
+			fmt.Printf("バックアップ: %s\n", backupPath)
+		}
+	}
 
 	// 圧縮処理を実行
-	err := shuku.CompressFile(inputPath, outputPath, options)
+	report, err := shuku.CompressFileWithReport(inputPath, outputPath, options)
 	if err != nil {
 		return cli.Exit(fmt.Sprintf("圧縮エラー: %v", err), 1)
 	}
+	if renamed {
+		if err := os.Remove(inputPath); err != nil {
+			return cli.Exit(fmt.Sprintf("元のファイルを削除できません: %v", err), 1)
+		}
+		if verbose {
+			fmt.Printf("元のファイルを削除しました: %s\n", inputPath)
+		}
+	}
 
-	// 圧縮前後のファイルサイズを取得して表示
+	// 圧縮結果の詳細を表示
 	if verbose {
-		inputInfo, err := os.Stat(inputPath)
-		if err == nil {
-			outputInfo, err := os.Stat(outputPath)
-			if err == nil {
-				inputSize := inputInfo.Size()
-				outputSize := outputInfo.Size()
-				reduction := 100.0 - (float64(outputSize) / float64(inputSize) * 100.0)
-
-				fmt.Printf("元のサイズ: %d バイト\n", inputSize)
-				fmt.Printf("圧縮後のサイズ: %d バイト\n", outputSize)
-				fmt.Printf("圧縮率: %.2f%%\n", reduction)
-			}
+		fmt.Printf("画像形式: %s (%dx%d)\n", report.Format, report.Width, report.Height)
+		fmt.Printf("元のサイズ: %d バイト\n", report.InputSize)
+		fmt.Printf("圧縮後のサイズ: %d バイト\n", report.OutputSize)
+		fmt.Printf("圧縮率: %.2f%%\n", report.CompressionRatio())
+		if report.MetadataRemoved {
+			fmt.Println("メタデータ: 削除されました")
 		}
+		fmt.Printf("処理時間: デコード %v, エンコード %v\n", report.DecodeDuration, report.EncodeDuration)
 	}
 
 	fmt.Println("圧縮が完了しました！")
@@ -157,3 +249,52 @@ func compressAction(c *cli.Context) error {
 
 	return nil
 }
+
+// compressVariants は入力ファイルを一度だけデコードし、--variant の指定ごとに出力を生成します。
+func compressVariants(c *cli.Context, inputPath string, values []string) error {
+	if c.String("output") != "" || c.Bool("in-place") || c.String("backup-dir") != "" || c.String("backup-suffix") != "" {
+		return cli.Exit("--variant は --output・--in-place・バックアップの指定と同時に指定できません。", 1)
+	}
+
+	// 出力指定を解析（品質を省略した出力には --quality を使用）
+	defaults := shuku.Options{
+		Quality:     c.Int("quality"),
+		PaletteSize: 256, // PNGの場合に使用
+	}
+	specs := make([]shuku.OutputSpec, 0, len(values))
+	for _, value := range values {
+		spec, err := parseVariant(value, defaults)
+		if err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+		specs = append(specs, spec)
+	}
+
+	if _, err := validateImageFormat(inputPath); err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	verbose := c.Bool("verbose")
+	fmt.Printf("画像を圧縮しています... (%d件の出力)\n", len(specs))
+
+	reports, err := shuku.CompressFileVariants(inputPath, specs)
+	for i, report := range reports {
+		if report == nil {
+			continue
+		}
+		fmt.Printf("圧縮ファイルが保存されました: %s (%s, %dx%d, %d バイト)\n",
+			specs[i].Path, report.Format, report.Width, report.Height, report.OutputSize)
+		if verbose {
+			fmt.Printf("  圧縮率: %.2f%%, 処理時間: エンコード %v\n", report.CompressionRatio(), report.EncodeDuration)
+		}
+	}
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("圧縮エラー: %v", err), 1)
+	}
+	if verbose && len(reports) > 0 {
+		fmt.Printf("デコード時間: %v（全出力で共有）\n", reports[0].DecodeDuration)
+	}
+
+	fmt.Println("圧縮が完了しました！")
+	return nil
+}
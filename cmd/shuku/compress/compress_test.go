@@ -12,6 +12,7 @@ import (
 	"testing"
 
 	"github.com/takumines/shuku/cmd/shuku/compress"
+	"github.com/takumines/shuku/cmd/shuku/restore"
 
 	"github.com/urfave/cli/v2"
 )
@@ -343,3 +344,233 @@ func TestCompressAction_CustomQuality(t *testing.T) {
 		t.Errorf("Output file with custom quality was not created: %s", outputFile)
 	}
 }
+
+// TestCompressAction_MismatchedExtension tests content sniffing and --fix-ext
+func TestCompressAction_MismatchedExtension(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Copy a PNG image to a file with a .jpg extension
+	data, err := os.ReadFile("../../../testdata/test_image.png")
+	if err != nil {
+		t.Fatalf("Failed to read test image: %v", err)
+	}
+	inputFile := filepath.Join(tempDir, "mislabeled.jpg")
+	if err := os.WriteFile(inputFile, data, 0644); err != nil {
+		t.Fatalf("Failed to write test image: %v", err)
+	}
+
+	app := &cli.App{
+		Commands: []*cli.Command{
+			compress.Cmd(),
+		},
+	}
+
+	// Capture output
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	args := []string{"app", "compress", "--input", inputFile, "--output", filepath.Join(tempDir, "output.jpg"), "--fix-ext"}
+	err = app.Run(args)
+
+	// Restore stdout
+	w.Close()
+	os.Stdout = oldStdout
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	output := buf.String()
+
+	if err != nil {
+		t.Fatalf("Compression of mislabeled file failed: %v", err)
+	}
+	if !strings.Contains(output, "警告:") {
+		t.Errorf("Expected mismatch warning, got: %s", output)
+	}
+
+	// Output should be renamed to .png
+	if _, err := os.Stat(filepath.Join(tempDir, "output.png")); os.IsNotExist(err) {
+		t.Errorf("Expected renamed output file was not created")
+	}
+}
+
+// TestCompressAction_InPlace tests in-place compression with a backup
+func TestCompressAction_InPlace(t *testing.T) {
+	tempDir := t.TempDir()
+	inputFile := filepath.Join(tempDir, "photo.jpg")
+	createTestImage(t, inputFile)
+	original, _ := os.ReadFile(inputFile)
+
+	app := &cli.App{
+		Commands: []*cli.Command{
+			compress.Cmd(),
+		},
+	}
+
+	args := []string{"app", "compress", "--input", inputFile, "--in-place", "--backup-suffix", ".orig", "--quality", "30"}
+	if err := app.Run(args); err != nil {
+		t.Fatalf("In-place compression failed: %v", err)
+	}
+
+	backup, err := os.ReadFile(inputFile + ".orig")
+	if err != nil {
+		t.Fatalf("Backup file was not created: %v", err)
+	}
+	if !bytes.Equal(backup, original) {
+		t.Error("Backup content differs from the original file")
+	}
+
+	compressed, _ := os.ReadFile(inputFile)
+	if bytes.Equal(compressed, original) {
+		t.Error("Input file was not overwritten")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "photo_compressed.jpg")); !os.IsNotExist(err) {
+		t.Error("Default output file should not be created in in-place mode")
+	}
+}
+
+// TestCompressAction_InPlaceFixExt tests that --in-place with --fix-ext replaces the mislabeled file and restore undoes it
+func TestCompressAction_InPlaceFixExt(t *testing.T) {
+	tempDir := t.TempDir()
+	data, err := os.ReadFile("../../../testdata/test_image.png")
+	if err != nil {
+		t.Fatalf("Failed to read test image: %v", err)
+	}
+	inputFile := filepath.Join(tempDir, "mislabeled.jpg")
+	if err := os.WriteFile(inputFile, data, 0644); err != nil {
+		t.Fatalf("Failed to write test image: %v", err)
+	}
+	renamedFile := filepath.Join(tempDir, "mislabeled.png")
+
+	app := &cli.App{
+		Commands: []*cli.Command{
+			compress.Cmd(),
+			restore.Cmd(),
+		},
+	}
+
+	args := []string{"app", "compress", "--input", inputFile, "--in-place", "--fix-ext", "--backup-suffix", ".orig"}
+	if err := app.Run(args); err != nil {
+		t.Fatalf("In-place compression failed: %v", err)
+	}
+	if _, err := os.Stat(renamedFile); err != nil {
+		t.Fatalf("Renamed output file was not created: %v", err)
+	}
+	if _, err := os.Stat(inputFile); !os.IsNotExist(err) {
+		t.Error("Original file with the wrong extension was left behind")
+	}
+
+	if err := app.Run([]string{"app", "restore", "--input", tempDir, "--backup-suffix", ".orig"}); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	restored, err := os.ReadFile(inputFile)
+	if err != nil {
+		t.Fatalf("Original file was not restored: %v", err)
+	}
+	if !bytes.Equal(restored, data) {
+		t.Error("Restored content differs from the original file")
+	}
+	if _, err := os.Stat(renamedFile); !os.IsNotExist(err) {
+		t.Error("Renamed output file was not removed by restore")
+	}
+}
+
+// TestCompressAction_InPlaceWithOutput tests conflicting --in-place and --output
+func TestCompressAction_InPlaceWithOutput(t *testing.T) {
+	tempDir := t.TempDir()
+	inputFile := filepath.Join(tempDir, "photo.jpg")
+	createTestImage(t, inputFile)
+
+	app := &cli.App{
+		Commands: []*cli.Command{
+			compress.Cmd(),
+		},
+		ExitErrHandler: func(c *cli.Context, err error) {
+			// テスト中はexit処理をスキップ
+		},
+	}
+
+	args := []string{"app", "compress", "--input", inputFile, "--in-place", "--output", filepath.Join(tempDir, "out.jpg")}
+	if err := app.Run(args); err == nil {
+		t.Fatal("Expected --in-place with --output to fail, but it succeeded")
+	}
+}
+
+// TestCompressAction_InvalidQuality tests that out-of-range quality is rejected
+func TestCompressAction_InvalidQuality(t *testing.T) {
+	tempDir := t.TempDir()
+	inputFile := filepath.Join(tempDir, "photo.jpg")
+	outputFile := filepath.Join(tempDir, "out.jpg")
+	createTestImage(t, inputFile)
+
+	app := &cli.App{
+		Commands: []*cli.Command{
+			compress.Cmd(),
+		},
+		ExitErrHandler: func(c *cli.Context, err error) {
+			// テスト中はexit処理をスキップ
+		},
+	}
+
+	args := []string{"app", "compress", "--input", inputFile, "--output", outputFile, "--quality", "150"}
+	if err := app.Run(args); err == nil {
+		t.Fatal("Expected out-of-range quality to fail, but it succeeded")
+	}
+	if _, err := os.Stat(outputFile); !os.IsNotExist(err) {
+		t.Errorf("Output file should not be created for invalid quality: %s", outputFile)
+	}
+}
+
+// TestCompressAction_Variants tests producing several outputs from one decode
+func TestCompressAction_Variants(t *testing.T) {
+	tempDir := t.TempDir()
+	inputFile := filepath.Join(tempDir, "photo.jpg")
+	createTestImage(t, inputFile)
+
+	app := &cli.App{
+		Commands: []*cli.Command{
+			compress.Cmd(),
+		},
+	}
+
+	webpFile := filepath.Join(tempDir, "photo.webp")
+	thumbFile := filepath.Join(tempDir, "thumb.jpg")
+	args := []string{"app", "compress", "--input", inputFile,
+		"--variant", "path=" + webpFile + ",quality=90",
+		"--variant", thumbFile + ",width=50,quality=60",
+	}
+	if err := app.Run(args); err != nil {
+		t.Fatalf("Variant compression failed: %v", err)
+	}
+
+	for _, path := range []string{webpFile, thumbFile} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Variant output was not created: %s", path)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "photo_compressed.jpg")); !os.IsNotExist(err) {
+		t.Error("Default output should not be created when --variant is given")
+	}
+}
+
+// TestCompressAction_VariantsWithOutput tests that --variant cannot be combined with --output
+func TestCompressAction_VariantsWithOutput(t *testing.T) {
+	tempDir := t.TempDir()
+	inputFile := filepath.Join(tempDir, "photo.jpg")
+	createTestImage(t, inputFile)
+
+	app := &cli.App{
+		Commands: []*cli.Command{
+			compress.Cmd(),
+		},
+		ExitErrHandler: func(c *cli.Context, err error) {
+			// テスト中はexit処理をスキップ
+		},
+	}
+
+	args := []string{"app", "compress", "--input", inputFile, "--output", filepath.Join(tempDir, "out.jpg"),
+		"--variant", filepath.Join(tempDir, "thumb.jpg")}
+	if err := app.Run(args); err == nil {
+		t.Fatal("Expected --variant with --output to fail, but it succeeded")
+	}
+}
@@ -0,0 +1,69 @@
+package compress
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/takumines/shuku/pkg/shuku"
+)
+
+// variantList は --variant フラグの値です。
+// フラグを指定するたびに1件の出力指定が追加されます。
+type variantList struct {
+	values []string
+}
+
+// Set は出力指定を追加します。
+// 値の区切り文字（,）を含むため、文字列スライスのフラグのように分割しません。
+func (v *variantList) Set(value string) error {
+	v.values = append(v.values, value)
+	return nil
+}
+
+// String は指定された出力指定を返します。
+func (v *variantList) String() string {
+	return strings.Join(v.values, " ")
+}
+
+// parseVariant は "path=out.webp,width=200,quality=70" 形式の出力指定を解析します。
+// 先頭の要素が "key=value" 形式でない場合は出力パスとして扱います。
+// 品質が指定されていない場合は defaults の値を使用します。
+func parseVariant(value string, defaults shuku.Options) (shuku.OutputSpec, error) {
+	spec := shuku.OutputSpec{Options: defaults}
+	for i, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		key, val, ok := strings.Cut(field, "=")
+		if !ok {
+			if i != 0 {
+				return spec, fmt.Errorf("出力指定の形式が不正です: %q（key=value で指定してください）", field)
+			}
+			spec.Path = field
+			continue
+		}
+
+		var err error
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "path":
+			spec.Path = val
+		case "format":
+			spec.Format = val
+		case "width":
+			spec.MaxWidth, err = strconv.Atoi(val)
+		case "height":
+			spec.MaxHeight, err = strconv.Atoi(val)
+		case "quality":
+			spec.Options.Quality, err = strconv.Atoi(val)
+		default:
+			return spec, fmt.Errorf("出力指定のキーが不正です: %q（path, format, width, height, quality を指定できます）", key)
+		}
+		if err != nil {
+			return spec, fmt.Errorf("出力指定の %s の値が不正です: %q", key, val)
+		}
+	}
+
+	if spec.Path == "" {
+		return spec, fmt.Errorf("出力指定にパスがありません: %q", value)
+	}
+	return spec, nil
+}
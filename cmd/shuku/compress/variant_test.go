@@ -0,0 +1,59 @@
+package compress
+
+import (
+	"testing"
+
+	"github.com/takumines/shuku/pkg/shuku"
+)
+
+func TestParseVariant(t *testing.T) {
+	defaults := shuku.Options{Quality: 80, PaletteSize: 256}
+
+	tests := []struct {
+		name    string
+		value   string
+		want    shuku.OutputSpec
+		wantErr bool
+	}{
+		{
+			name:  "パスのみ",
+			value: "out.webp",
+			want:  shuku.OutputSpec{Path: "out.webp", Options: defaults},
+		},
+		{
+			name:  "すべての指定",
+			value: "path=thumb,format=jpeg,width=200,height=100,quality=60",
+			want: shuku.OutputSpec{
+				Path:      "thumb",
+				Format:    "jpeg",
+				MaxWidth:  200,
+				MaxHeight: 100,
+				Options:   shuku.Options{Quality: 60, PaletteSize: 256},
+			},
+		},
+		{
+			name:  "先頭のパスと指定の組み合わせ",
+			value: "small.png, width=64",
+			want:  shuku.OutputSpec{Path: "small.png", MaxWidth: 64, Options: defaults},
+		},
+		{"パスなし", "width=200", shuku.OutputSpec{}, true},
+		{"不明なキー", "out.jpg,size=200", shuku.OutputSpec{}, true},
+		{"数値でない幅", "out.jpg,width=abc", shuku.OutputSpec{}, true},
+		{"2番目以降のkey=valueでない要素", "out.jpg,200", shuku.OutputSpec{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseVariant(tt.value, defaults)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseVariant() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseVariant() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,11 +1,23 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	if err := rootCmd().Run(os.Args); err != nil {
+	// Ctrl-C・SIGTERM で処理を中断し、書き込み中の出力を破棄してから終了する
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// 2回目のシグナルでは待たずに終了できるよう、最初のシグナルの後は通常の動作に戻す
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := rootCmd().RunContext(ctx, os.Args); err != nil {
 		os.Exit(1)
 	}
 }
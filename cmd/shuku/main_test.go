@@ -106,7 +106,7 @@ func TestRootCmd(t *testing.T) {
 	}
 
 	// Test commands are registered
-	expectedCommands := []string{"compress", "batch", "version", "help"}
+	expectedCommands := []string{"compress", "batch", "restore", "version", "help"}
 	if len(app.Commands) != len(expectedCommands) {
 		t.Errorf("App commands length = %v, want %v", len(app.Commands), len(expectedCommands))
 	}
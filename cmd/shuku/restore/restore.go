@@ -0,0 +1,72 @@
+package restore
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/takumines/shuku/internal/backup"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Cmd returns the restore command.
+func Cmd() *cli.Command {
+	return &cli.Command{
+		Name:  "restore",
+		Usage: "Restore original files from backups made with --in-place.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "input",
+				Aliases:  []string{"i"},
+				Usage:    "Directory that was compressed in place",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "backup-dir",
+				Usage: "Directory the backups were written to",
+			},
+			&cli.StringFlag{
+				Name:  "backup-suffix",
+				Usage: "Suffix the backups were written with, e.g. '.orig'",
+			},
+			&cli.BoolFlag{
+				Name:    "verbose",
+				Aliases: []string{"v"},
+				Usage:   "Show detailed information",
+			},
+		},
+		Action: restoreAction,
+	}
+}
+
+// restoreAction is the action for the restore command.
+func restoreAction(c *cli.Context) error {
+	// 入力ディレクトリの存在確認
+	inputDir := c.String("input")
+	if _, err := os.Stat(inputDir); os.IsNotExist(err) {
+		return cli.Exit(fmt.Sprintf("入力ディレクトリが存在しません: %s", inputDir), 1)
+	}
+
+	config := backup.Config{Dir: c.String("backup-dir"), Suffix: c.String("backup-suffix")}
+	if !config.Enabled() {
+		return cli.Exit("--backup-dir または --backup-suffix を指定してください。", 1)
+	}
+
+	fmt.Println("バックアップから復元しています...")
+
+	restored, err := config.Restore(inputDir)
+	if c.Bool("verbose") {
+		for _, r := range restored {
+			fmt.Printf("✅ %s → %s\n", r.BackupPath, r.OriginalPath)
+			if r.RemovedPath != "" {
+				fmt.Printf("   削除: %s\n", r.RemovedPath)
+			}
+		}
+	}
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("復元エラー: %v", err), 1)
+	}
+
+	fmt.Printf("復元が完了しました！（%d ファイル）\n", len(restored))
+	return nil
+}
@@ -0,0 +1,78 @@
+package restore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/takumines/shuku/internal/backup"
+	"github.com/urfave/cli/v2"
+)
+
+// TestCmdStructure tests the basic structure of the restore command
+func TestCmdStructure(t *testing.T) {
+	cmd := Cmd()
+
+	if cmd.Name != "restore" {
+		t.Errorf("Command name = %v, want %v", cmd.Name, "restore")
+	}
+	if cmd.Action == nil {
+		t.Error("Command action should not be nil")
+	}
+
+	expectedFlagCount := 4
+	if len(cmd.Flags) != expectedFlagCount {
+		t.Errorf("Command flags length = %v, want %v", len(cmd.Flags), expectedFlagCount)
+	}
+}
+
+// TestRestoreAction tests restoring backups by suffix
+func TestRestoreAction(t *testing.T) {
+	tempDir := t.TempDir()
+	imagePath := filepath.Join(tempDir, "photo.jpg")
+	if err := os.WriteFile(imagePath, []byte("original"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if err := backup.Save(imagePath, imagePath+".orig"); err != nil {
+		t.Fatalf("Failed to save backup: %v", err)
+	}
+	if err := os.WriteFile(imagePath, []byte("compressed"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	app := &cli.App{
+		Commands: []*cli.Command{Cmd()},
+	}
+
+	err := app.Run([]string{"test", "restore", "--input", tempDir, "--backup-suffix", ".orig"})
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		t.Fatalf("Failed to read restored file: %v", err)
+	}
+	if string(data) != "original" {
+		t.Errorf("Restored content = %q, want %q", data, "original")
+	}
+}
+
+// TestRestoreActionWithoutBackupConfig tests missing backup options
+func TestRestoreActionWithoutBackupConfig(t *testing.T) {
+	app := &cli.App{
+		Commands: []*cli.Command{Cmd()},
+		ExitErrHandler: func(c *cli.Context, err error) {
+			// テスト中はexit処理をスキップ
+		},
+	}
+
+	err := app.Run([]string{"test", "restore", "--input", t.TempDir()})
+	if err == nil {
+		t.Fatal("Expected missing backup options to fail, but it succeeded")
+	}
+	if !strings.Contains(err.Error(), "--backup-dir") {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+}
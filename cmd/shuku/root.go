@@ -5,6 +5,7 @@ import (
 
 	"github.com/takumines/shuku/cmd/shuku/batch"
 	"github.com/takumines/shuku/cmd/shuku/compress"
+	"github.com/takumines/shuku/cmd/shuku/restore"
 	"github.com/takumines/shuku/cmd/shuku/version"
 
 	"github.com/urfave/cli/v2"
@@ -33,6 +34,7 @@ func rootCmd() *cli.App {
 		Commands: []*cli.Command{
 			compress.Cmd(),
 			batch.Cmd(),
+			restore.Cmd(),
 			version.Cmd(),
 			helpCommand,
 		},
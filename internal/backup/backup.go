@@ -0,0 +1,202 @@
+// Package backup は上書き圧縮の前に元ファイルを退避し、後から復元する機能を提供します。
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/takumines/shuku/internal/fileutil"
+)
+
+// RecordSuffix は Save で作成したバックアップであることを記録するファイルのサフィックスです。
+// バックアップのパスにこのサフィックスを付けたファイルに、元のファイル名を保存します。
+// Restore は記録のあるバックアップのみを復元するため、サフィックスが一致するだけのファイルは変更しません。
+const RecordSuffix = ".shuku-backup"
+
+// RenameSuffix は、拡張子を修正して別名で上書きしたファイルの名前を記録するファイルのサフィックスです。
+// バックアップのパスにこのサフィックスを付けたファイルに、変更後のファイル名を保存します。
+const RenameSuffix = ".shuku-rename"
+
+// Config はバックアップの保存方法を表します。
+// Dir と Suffix の両方を指定した場合は、Dir 内にサフィックス付きの名前で保存します。
+type Config struct {
+	Dir    string // バックアップを保存するディレクトリ（基準ディレクトリからの相対パスを維持）
+	Suffix string // 元ファイル名に付加するサフィックス（例: ".orig"）
+}
+
+// Restored は復元された1件のファイルを表します。
+type Restored struct {
+	BackupPath   string // 復元元のバックアップファイル
+	OriginalPath string // 復元先のファイル
+	RemovedPath  string // 復元時に削除した、拡張子を修正した上書き先のファイル（別名で上書きしていない場合は空）
+}
+
+// Enabled はバックアップが有効かどうかを返します。
+func (c Config) Enabled() bool {
+	return c.Dir != "" || c.Suffix != ""
+}
+
+// Path は root を基準とした path のバックアップ先のパスを返します。
+func (c Config) Path(root, path string) (string, error) {
+	if !c.Enabled() {
+		return "", errors.New("バックアップの保存先が指定されていません")
+	}
+
+	if c.Dir == "" {
+		return path + c.Suffix, nil
+	}
+
+	relPath, err := filepath.Rel(root, path)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return "", fmt.Errorf("基準ディレクトリ外のファイルはバックアップできません: %s", path)
+	}
+	return filepath.Join(c.Dir, relPath) + c.Suffix, nil
+}
+
+// Save は path を backupPath にコピーし、Restore で復元できるよう記録します。
+// 記録のあるバックアップが既に存在する場合は、最初に退避した元ファイルを保持するため何もしません。
+// 記録のないファイルが backupPath にある場合は、別のファイルを上書きしないようエラーを返します。
+func Save(path, backupPath string) error {
+	if _, err := os.Stat(backupPath); err == nil {
+		if _, err := os.Stat(backupPath + RecordSuffix); err == nil {
+			return nil
+		}
+		return fmt.Errorf("バックアップの保存先に別のファイルが存在します: %s", backupPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(backupPath), 0755); err != nil {
+		return fmt.Errorf("バックアップディレクトリの作成に失敗しました: %w", err)
+	}
+	if err := copyFile(path, backupPath); err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(backupPath+RecordSuffix, 0644, func(w io.Writer) error {
+		_, err := io.WriteString(w, filepath.Base(path))
+		return err
+	})
+}
+
+// SaveRename は元ファイルを拡張子を修正した renamedPath に置き換える際に、変更後のファイル名を記録します。
+// Restore は backupPath から元ファイルを復元した後、記録したファイルを削除します。
+// renamedPath は元ファイルと同じディレクトリにある必要があります。
+func SaveRename(backupPath, renamedPath string) error {
+	return fileutil.WriteFileAtomic(backupPath+RenameSuffix, 0644, func(w io.Writer) error {
+		_, err := io.WriteString(w, filepath.Base(renamedPath))
+		return err
+	})
+}
+
+// Restore は root 以下のファイルについて、Save で作成したバックアップを元の場所に戻します。
+// サフィックスが一致していても、Save の記録がないファイルは復元しません。
+// 復元に成功したバックアップファイルと記録は削除されます。
+// SaveRename で別名の記録がある場合は、別名で上書きしたファイルと記録も削除します。
+func (c Config) Restore(root string) ([]Restored, error) {
+	if !c.Enabled() {
+		return nil, errors.New("バックアップの保存先が指定されていません")
+	}
+
+	walkRoot := root
+	if c.Dir != "" {
+		walkRoot = c.Dir
+	}
+
+	// 復元中のファイルの追加・削除が走査に影響しないよう、先に記録のあるバックアップを列挙する
+	var backups []string
+	err := filepath.Walk(walkRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, RecordSuffix) {
+			return nil
+		}
+		backupPath := strings.TrimSuffix(path, RecordSuffix)
+		if !strings.HasSuffix(backupPath, c.Suffix) {
+			return nil
+		}
+		if _, err := os.Stat(backupPath); err != nil {
+			return nil
+		}
+		backups = append(backups, backupPath)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var restored []Restored
+	for _, path := range backups {
+		r, err := c.restore(root, path)
+		if err != nil {
+			return restored, err
+		}
+		restored = append(restored, r)
+	}
+	return restored, nil
+}
+
+// restore は1件のバックアップを元の場所に戻します。
+func (c Config) restore(root, path string) (Restored, error) {
+	// バックアップのパスから元ファイルのパスを求める
+	originalPath := strings.TrimSuffix(path, c.Suffix)
+	if c.Dir != "" {
+		relPath, err := filepath.Rel(c.Dir, originalPath)
+		if err != nil {
+			return Restored{}, err
+		}
+		originalPath = filepath.Join(root, relPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(originalPath), 0755); err != nil {
+		return Restored{}, err
+	}
+	if err := copyFile(path, originalPath); err != nil {
+		return Restored{}, fmt.Errorf("復元に失敗しました: %s: %w", originalPath, err)
+	}
+	if err := os.Remove(path); err != nil {
+		return Restored{}, err
+	}
+	if err := os.Remove(path + RecordSuffix); err != nil {
+		return Restored{}, err
+	}
+	r := Restored{BackupPath: path, OriginalPath: originalPath}
+
+	// 拡張子を修正して別名で上書きしていた場合は、そのファイルを削除する
+	name, err := os.ReadFile(path + RenameSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return r, err
+	}
+	if renamedPath := filepath.Join(filepath.Dir(originalPath), filepath.Base(string(name))); renamedPath != originalPath {
+		if err := os.Remove(renamedPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return r, fmt.Errorf("別名で上書きしたファイルを削除できません: %s: %w", renamedPath, err)
+		}
+		r.RemovedPath = renamedPath
+	}
+	return r, os.Remove(path + RenameSuffix)
+}
+
+// copyFile は src の内容とパーミッションを dst にアトミックにコピーします。
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return fileutil.WriteFileAtomic(dst, info.Mode().Perm(), func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+}
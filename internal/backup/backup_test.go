@@ -0,0 +1,218 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// テスト用ファイルを作成
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("ディレクトリの作成に失敗しました: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+	}
+}
+
+// ファイル内容を読み込む
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ファイルの読み込みに失敗しました: %v", err)
+	}
+	return string(data)
+}
+
+func TestConfig_Path(t *testing.T) {
+	root := filepath.Join("assets")
+	path := filepath.Join("assets", "img", "photo.jpg")
+
+	tests := []struct {
+		name     string
+		config   Config
+		expected string
+		wantErr  bool
+	}{
+		{"サフィックスのみ", Config{Suffix: ".orig"}, filepath.Join("assets", "img", "photo.jpg.orig"), false},
+		{"ディレクトリのみ", Config{Dir: "backup"}, filepath.Join("backup", "img", "photo.jpg"), false},
+		{"ディレクトリとサフィックス", Config{Dir: "backup", Suffix: ".bak"}, filepath.Join("backup", "img", "photo.jpg.bak"), false},
+		{"未設定", Config{}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.config.Path(root, path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Path() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("Path() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	t.Run("基準ディレクトリ外", func(t *testing.T) {
+		if _, err := (Config{Dir: "backup"}).Path(root, filepath.Join("other", "photo.jpg")); err == nil {
+			t.Error("基準ディレクトリ外のファイルに対してエラーが発生しませんでした")
+		}
+	})
+}
+
+func TestSave(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "photo.jpg")
+	backupPath := filepath.Join(dir, "backup", "photo.jpg")
+	writeTestFile(t, path, "original")
+
+	if err := Save(path, backupPath); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if got := readTestFile(t, backupPath); got != "original" {
+		t.Errorf("バックアップ内容 = %q, want %q", got, "original")
+	}
+
+	// 2回目の保存では最初のバックアップを保持する
+	writeTestFile(t, path, "compressed")
+	if err := Save(path, backupPath); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if got := readTestFile(t, backupPath); got != "original" {
+		t.Errorf("既存のバックアップが上書きされました: %q", got)
+	}
+
+	// 記録のないファイルは Save で作成したバックアップとみなさない
+	otherPath := filepath.Join(dir, "other.jpg")
+	writeTestFile(t, otherPath, "original")
+	writeTestFile(t, otherPath+".orig", "user file")
+	if err := Save(otherPath, otherPath+".orig"); err == nil {
+		t.Error("記録のないファイルが保存先にある場合にエラーが発生しませんでした")
+	}
+	if got := readTestFile(t, otherPath+".orig"); got != "user file" {
+		t.Errorf("記録のないファイルが上書きされました: %q", got)
+	}
+}
+
+func TestConfig_Restore(t *testing.T) {
+	t.Run("サフィックス", func(t *testing.T) {
+		root := t.TempDir()
+		config := Config{Suffix: ".orig"}
+		path := filepath.Join(root, "sub", "photo.jpg")
+		writeTestFile(t, path, "original")
+		if err := Save(path, path+".orig"); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		writeTestFile(t, path, "compressed")
+
+		restored, err := config.Restore(root)
+		if err != nil {
+			t.Fatalf("Restore() error = %v", err)
+		}
+		if len(restored) != 1 || restored[0].OriginalPath != path {
+			t.Fatalf("Restore() = %v, want 1 file restored to %s", restored, path)
+		}
+		if got := readTestFile(t, path); got != "original" {
+			t.Errorf("復元後の内容 = %q, want %q", got, "original")
+		}
+		if _, err := os.Stat(path + ".orig"); !os.IsNotExist(err) {
+			t.Error("復元後にバックアップが削除されていません")
+		}
+		if _, err := os.Stat(path + ".orig" + RecordSuffix); !os.IsNotExist(err) {
+			t.Error("復元後にバックアップの記録が削除されていません")
+		}
+	})
+
+	t.Run("記録のないファイル", func(t *testing.T) {
+		root := t.TempDir()
+		config := Config{Suffix: ".orig"}
+		path := filepath.Join(root, "notes")
+		writeTestFile(t, path, "current")
+		writeTestFile(t, path+".orig", "user file")
+
+		restored, err := config.Restore(root)
+		if err != nil {
+			t.Fatalf("Restore() error = %v", err)
+		}
+		if len(restored) != 0 {
+			t.Fatalf("Restore() = %v, want no files restored", restored)
+		}
+		if got := readTestFile(t, path); got != "current" {
+			t.Errorf("記録のないファイルで上書きされました: %q", got)
+		}
+		if got := readTestFile(t, path+".orig"); got != "user file" {
+			t.Errorf("記録のないファイルが変更されました: %q", got)
+		}
+	})
+
+	t.Run("ディレクトリ", func(t *testing.T) {
+		root := t.TempDir()
+		backupDir := filepath.Join(t.TempDir(), "backup")
+		config := Config{Dir: backupDir}
+		path := filepath.Join(root, "sub", "photo.png")
+		writeTestFile(t, path, "original")
+		if err := Save(path, filepath.Join(backupDir, "sub", "photo.png")); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		writeTestFile(t, path, "compressed")
+
+		restored, err := config.Restore(root)
+		if err != nil {
+			t.Fatalf("Restore() error = %v", err)
+		}
+		if len(restored) != 1 {
+			t.Fatalf("Restore() restored %d files, want 1", len(restored))
+		}
+		if got := readTestFile(t, path); got != "original" {
+			t.Errorf("復元後の内容 = %q, want %q", got, "original")
+		}
+	})
+
+	t.Run("拡張子を修正した上書き", func(t *testing.T) {
+		for _, config := range []Config{{Suffix: ".orig"}, {Dir: filepath.Join(t.TempDir(), "backup")}} {
+			root := t.TempDir()
+			path := filepath.Join(root, "sub", "photo.png")
+			renamedPath := filepath.Join(root, "sub", "photo.jpg")
+			backupPath, err := config.Path(root, path)
+			if err != nil {
+				t.Fatalf("Path() error = %v", err)
+			}
+			writeTestFile(t, path, "original")
+			if err := Save(path, backupPath); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			if err := SaveRename(backupPath, renamedPath); err != nil {
+				t.Fatalf("SaveRename() error = %v", err)
+			}
+			writeTestFile(t, renamedPath, "compressed")
+			if err := os.Remove(path); err != nil {
+				t.Fatal(err)
+			}
+
+			restored, err := config.Restore(root)
+			if err != nil {
+				t.Fatalf("Restore() error = %v", err)
+			}
+			if len(restored) != 1 || restored[0].OriginalPath != path || restored[0].RemovedPath != renamedPath {
+				t.Fatalf("Restore() = %+v, want %s restored and %s removed", restored, path, renamedPath)
+			}
+			if got := readTestFile(t, path); got != "original" {
+				t.Errorf("復元後の内容 = %q, want %q", got, "original")
+			}
+			if _, err := os.Stat(renamedPath); !os.IsNotExist(err) {
+				t.Error("別名で上書きしたファイルが削除されていません")
+			}
+			if _, err := os.Stat(backupPath + RenameSuffix); !os.IsNotExist(err) {
+				t.Error("別名の記録が削除されていません")
+			}
+		}
+	})
+
+	t.Run("未設定", func(t *testing.T) {
+		if _, err := (Config{}).Restore(t.TempDir()); err == nil {
+			t.Error("未設定の場合にエラーが発生しませんでした")
+		}
+	})
+}
@@ -0,0 +1,546 @@
+package batch
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/takumines/shuku/internal/fileutil"
+	"github.com/takumines/shuku/pkg/shuku"
+)
+
+// 対応するアーカイブ形式
+const (
+	ArchiveZip   = "zip"
+	ArchiveTar   = "tar"
+	ArchiveTarGz = "tar.gz"
+)
+
+// archiveExtensions はアーカイブ形式と拡張子の対応です。長い拡張子から順に判定します。
+var archiveExtensions = []struct {
+	ext    string
+	format string
+}{
+	{".tar.gz", ArchiveTarGz},
+	{".tgz", ArchiveTarGz},
+	{".tar", ArchiveTar},
+	{".zip", ArchiveZip},
+}
+
+// ArchiveFormat はファイル名の拡張子からアーカイブ形式を判定します。
+// アーカイブではない場合は ok が false になります。
+func ArchiveFormat(path string) (format string, ok bool) {
+	lower := strings.ToLower(path)
+	for _, a := range archiveExtensions {
+		if strings.HasSuffix(lower, a.ext) {
+			return a.format, true
+		}
+	}
+	return "", false
+}
+
+// archiveFamily はエントリのヘッダーを引き継げるアーカイブ形式のまとまりを返します。
+// tar と tar.gz は圧縮の有無のみが異なるため、相互に変換できます。
+func archiveFamily(format string) string {
+	if format == ArchiveTarGz {
+		return ArchiveTar
+	}
+	return format
+}
+
+// ProcessArchive はアーカイブ内の画像を圧縮し、新しいアーカイブとして outputPath に書き込みます。
+// 処理対象のパターンに一致する画像は圧縮し、それ以外のエントリは変更せずにコピーします。
+// エントリの順序・ディレクトリ構成・更新日時は維持されます。
+// 圧縮に失敗した画像は元の内容のままコピーされ、Result の Error に記録されます。
+// outputPath が空の場合は入力と同じ場所に "_compressed" を付けた名前で出力します。
+func (p *Processor) ProcessArchive(inputPath, outputPath string, options shuku.Options) ([]Result, error) {
+	return p.ProcessArchiveContext(context.Background(), inputPath, outputPath, options)
+}
+
+// ProcessArchiveContext は ctx を考慮して ProcessArchive を実行します。
+// ctx がキャンセルされた場合は出力ファイルを作成せず、ctx.Err() を返します。
+func (p *Processor) ProcessArchiveContext(ctx context.Context, inputPath, outputPath string, options shuku.Options) ([]Result, error) {
+	if p.InPlace {
+		return nil, fmt.Errorf("アーカイブの処理では上書きモードを使用できません")
+	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	if err := validateRules(p.Rules); err != nil {
+		return nil, err
+	}
+
+	inputFormat, ok := ArchiveFormat(inputPath)
+	if !ok {
+		return nil, fmt.Errorf("対応していないアーカイブ形式です: %s", inputPath)
+	}
+	if outputPath == "" {
+		outputPath = defaultArchiveOutputPath(inputPath)
+	}
+	outputFormat, ok := ArchiveFormat(outputPath)
+	if !ok {
+		return nil, fmt.Errorf("対応していないアーカイブ形式です: %s", outputPath)
+	}
+	if archiveFamily(inputFormat) != archiveFamily(outputFormat) {
+		return nil, fmt.Errorf("入力(%s)と出力(%s)のアーカイブ形式が異なります", inputFormat, outputFormat)
+	}
+
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("アーカイブを開けません: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("アーカイブを開けません: %w", err)
+	}
+	if outputInfo, err := os.Stat(outputPath); err == nil && os.SameFile(info, outputInfo) {
+		return nil, fmt.Errorf("入力と同じアーカイブには出力できません: %s", outputPath)
+	}
+
+	reader, err := newArchiveReader(file, info.Size(), inputFormat)
+	if err != nil {
+		return nil, fmt.Errorf("アーカイブを読み込めません: %w", err)
+	}
+	defer reader.Close()
+
+	// 一時ファイルに書き込み、すべてのエントリを処理できた場合のみ出力先に置き換える
+	var results []Result
+	err = fileutil.WriteFileAtomic(outputPath, fileutil.OutputMode(info, outputPath), func(w io.Writer) error {
+		writer := newArchiveWriter(w, outputFormat)
+		var err error
+		results, err = p.transcodeArchive(ctx, archivePaths{input: inputPath, output: outputPath}, reader, writer, options)
+		if err != nil {
+			return err
+		}
+		return writer.Close()
+	})
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return results, ctxErr
+		}
+		return results, fmt.Errorf("アーカイブの処理に失敗しました: %w", err)
+	}
+	return results, nil
+}
+
+// defaultArchiveOutputPath は "assets.tar.gz" を "assets_compressed.tar.gz" のように変換します。
+func defaultArchiveOutputPath(inputPath string) string {
+	lower := strings.ToLower(inputPath)
+	for _, a := range archiveExtensions {
+		if strings.HasSuffix(lower, a.ext) {
+			base := inputPath[:len(inputPath)-len(a.ext)]
+			return base + "_compressed" + inputPath[len(base):]
+		}
+	}
+	return inputPath + "_compressed"
+}
+
+// archivePaths は Result に記録する表示用のパスの生成に使用するアーカイブのパスです。
+type archivePaths struct {
+	input  string
+	output string
+}
+
+// entryPath はアーカイブ内のエントリを "assets.zip:images/logo.png" 形式で表します。
+func entryPath(archivePath, name string) string {
+	return archivePath + ":" + name
+}
+
+// archiveTask はアーカイブの1エントリの処理状況です。
+type archiveTask struct {
+	entry   *archiveEntry
+	result  *Result // 圧縮対象のエントリのみ
+	done    chan struct{}
+	written chan struct{} // 内容をストリームで書き込むエントリのみ。書き込みを終えると閉じられる
+}
+
+// transcodeArchive は reader のエントリを順に読み込み、圧縮対象の画像をワーカーで並行に圧縮して、
+// 元の順序のまま writer に書き込みます。
+// 同時にメモリに保持するエントリはワーカー数程度に制限されます。
+// tar の圧縮対象ではないエントリはメモリに読み込まず、先行するエントリの書き込みを待ってから出力にそのままコピーします。
+func (p *Processor) transcodeArchive(ctx context.Context, paths archivePaths, reader archiveReader, writer archiveWriter, options shuku.Options) ([]Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan *archiveTask)
+	queue := make(chan *archiveTask, p.WorkerCount)
+	progress := p.newProgressTracker()
+
+	// ワーカーを起動
+	var wg sync.WaitGroup
+	for i := 0; i < p.WorkerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range jobs {
+				progress.started(task.result.Job)
+				start := time.Now()
+				p.compressArchiveEntry(ctx, task, paths)
+				task.result.Duration = time.Since(start)
+				task.result.Rule = task.result.Job.rule.Label()
+				progress.finished(*task.result)
+				close(task.done)
+			}
+		}()
+	}
+
+	// 書き込みは読み込みと同じ順序で行う
+	var results []Result
+	var writeErr error
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for task := range queue {
+			<-task.done
+			if task.result != nil {
+				results = append(results, *task.result)
+			}
+			if writeErr == nil && ctx.Err() == nil {
+				if writeErr = writer.Write(task.entry); writeErr != nil {
+					cancel()
+				}
+			}
+			if task.written != nil {
+				close(task.written)
+			}
+		}
+	}()
+
+	var readErr error
+	found := 0
+	for ctx.Err() == nil {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+
+		task := &archiveTask{entry: entry, done: make(chan struct{})}
+		if !p.shouldCompressEntry(entry) {
+			close(task.done)
+			if entry.body != nil {
+				// 次のエントリを読み込むと内容を読めなくなるため、書き込みを終えるまで待つ
+				task.written = make(chan struct{})
+				queue <- task
+				<-task.written
+				continue
+			}
+			queue <- task
+			continue
+		}
+		if entry.body != nil {
+			// 圧縮対象のエントリはワーカーで処理するため、次のエントリを読み込む前にメモリに読み込む
+			if _, err := entry.load(); err != nil {
+				readErr = fmt.Errorf("%s: %w", entry.name, err)
+				break
+			}
+		}
+		rule := p.matchRule(entry.name)
+		task.result = &Result{Job: Job{
+			InputPath:  entryPath(paths.input, entry.name),
+			OutputPath: entryPath(paths.output, entry.name),
+			Options:    rule.apply(options),
+			seq:        found,
+			rule:       rule,
+		}}
+		progress.found()
+		found++
+		queue <- task
+		jobs <- task
+	}
+	// 中断した場合も、読み込んだエントリの数で総数を確定する
+	progress.scanCompleted(found)
+	close(jobs)
+	wg.Wait()
+	close(queue)
+	<-writerDone
+
+	switch {
+	case readErr != nil:
+		return results, fmt.Errorf("アーカイブの読み込みに失敗しました: %w", readErr)
+	case writeErr != nil:
+		return results, writeErr
+	}
+	return results, ctx.Err()
+}
+
+// shouldCompressEntry はエントリが圧縮対象かどうかを判定します。
+// ディレクトリと同様に、サブディレクトリ内のエントリは再帰処理が有効な場合のみ対象にします。
+func (p *Processor) shouldCompressEntry(entry *archiveEntry) bool {
+	if !entry.regular {
+		return false
+	}
+	if !p.Recursive && strings.Contains(entry.name, "/") {
+		return false
+	}
+	return p.shouldIncludeFile(entry.name)
+}
+
+// compressArchiveEntry はエントリの画像を圧縮し、成功した場合はエントリの内容を圧縮結果に置き換えます。
+func (p *Processor) compressArchiveEntry(ctx context.Context, task *archiveTask, paths archivePaths) {
+	result := task.result
+	entry := task.entry
+
+	if err := ctx.Err(); err != nil {
+		result.Error = err
+		return
+	}
+
+	data, err := entry.load()
+	if err != nil {
+		result.Error = fmt.Errorf("圧縮処理エラー: %w", &shuku.CompressError{Stage: shuku.StageRead, Path: result.Job.InputPath, Err: err})
+		return
+	}
+
+	// ファイルの内容から形式を判定し、拡張子との不一致を確認
+	name := entry.name
+	if format, err := shuku.DetectFormat(data); err == nil {
+		result.DetectedFormat = format
+		if !shuku.ExtensionMatchesFormat(name, format) {
+			result.Warning = fmt.Sprintf("拡張子(%s)と内容(%s)が一致しません", filepath.Ext(name), format)
+			if p.FixExtension {
+				name = shuku.CorrectExtension(name, format)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	report, warning, err := compressJob(ctx, &buf, result.Job.InputPath, data, result.Job.Options, result.Job.rule)
+	if err != nil {
+		// 圧縮できなかった画像は元の内容のままコピーする
+		result.Error = fmt.Errorf("圧縮処理エラー: %w", err)
+		return
+	}
+
+	entry.rename(name)
+	entry.data = buf.Bytes()
+	result.Job.OutputPath = entryPath(paths.output, name)
+	report.OutputPath = result.Job.OutputPath
+
+	result.Warning = joinWarnings(result.Warning, warning)
+	result.Report = report
+	result.OriginalSize = report.InputSize
+	result.CompressedSize = report.OutputSize
+	result.DetectedFormat = report.Format
+}
+
+// archiveEntry はアーカイブの1エントリです。
+// 元のヘッダーを保持し、書き込み時に内容以外の情報（更新日時・権限など）を引き継ぎます。
+type archiveEntry struct {
+	name    string // スラッシュ区切りのエントリ名（ディレクトリの末尾の "/" は含まない）
+	regular bool   // 通常ファイルかどうか
+	data    []byte // 通常ファイルの内容（load を呼び出すまで空）
+
+	zipFile   *zip.File   // zip の元のエントリ
+	tarHeader *tar.Header // tar の元のヘッダー
+	body      io.Reader   // tar のエントリの内容。次のエントリを読み込むまでのみ有効
+	modified  bool        // 内容を変更したかどうか
+}
+
+// load はエントリの内容を読み込みます。
+// tar のエントリは内容を data に保持し、以降は body から読み込みません。
+func (e *archiveEntry) load() ([]byte, error) {
+	switch {
+	case e.data != nil:
+		return e.data, nil
+	case e.body != nil:
+		data, err := io.ReadAll(e.body)
+		if err != nil {
+			return nil, err
+		}
+		e.data, e.body = data, nil
+		return data, nil
+	case e.zipFile != nil:
+		rc, err := e.zipFile.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	return nil, nil
+}
+
+// rename はエントリ名を変更し、内容を変更したエントリとして扱います。
+func (e *archiveEntry) rename(name string) {
+	e.name = name
+	e.modified = true
+}
+
+// archiveReader はアーカイブのエントリを先頭から順に読み込みます。
+type archiveReader interface {
+	// Next は次のエントリを返します。エントリがない場合は io.EOF を返します。
+	Next() (*archiveEntry, error)
+	Close() error
+}
+
+// archiveWriter はアーカイブにエントリを順に書き込みます。
+type archiveWriter interface {
+	Write(entry *archiveEntry) error
+	Close() error
+}
+
+func newArchiveReader(r io.ReaderAt, size int64, format string) (archiveReader, error) {
+	switch format {
+	case ArchiveZip:
+		zr, err := zip.NewReader(r, size)
+		if err != nil {
+			return nil, err
+		}
+		return &zipReader{files: zr.File}, nil
+	case ArchiveTarGz:
+		gz, err := gzip.NewReader(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return nil, err
+		}
+		return &tarReader{tr: tar.NewReader(gz), closer: gz}, nil
+	default:
+		return &tarReader{tr: tar.NewReader(io.NewSectionReader(r, 0, size))}, nil
+	}
+}
+
+func newArchiveWriter(w io.Writer, format string) archiveWriter {
+	switch format {
+	case ArchiveZip:
+		return &zipWriter{zw: zip.NewWriter(w)}
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		return &tarWriter{tw: tar.NewWriter(gz), closer: gz}
+	default:
+		return &tarWriter{tw: tar.NewWriter(w)}
+	}
+}
+
+// zipReader は zip のエントリを中央ディレクトリの順に読み込みます。
+type zipReader struct {
+	files []*zip.File
+	next  int
+}
+
+func (z *zipReader) Next() (*archiveEntry, error) {
+	if z.next >= len(z.files) {
+		return nil, io.EOF
+	}
+	f := z.files[z.next]
+	z.next++
+
+	// 内容は圧縮対象のエントリのみ、ワーカーで展開する
+	return &archiveEntry{
+		name:    strings.TrimSuffix(f.Name, "/"),
+		regular: f.Mode().IsRegular() && !strings.HasSuffix(f.Name, "/"),
+		zipFile: f,
+	}, nil
+}
+
+func (z *zipReader) Close() error { return nil }
+
+// zipWriter は zip のエントリを書き込みます。
+// 変更していないエントリは再圧縮せずに元のデータをそのままコピーします。
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (z *zipWriter) Write(entry *archiveEntry) error {
+	f := entry.zipFile
+	if !entry.modified {
+		return z.zw.Copy(f)
+	}
+
+	header := &zip.FileHeader{
+		Name:           entry.name,
+		Comment:        f.Comment,
+		Method:         f.Method,
+		Modified:       f.Modified,
+		CreatorVersion: f.CreatorVersion,
+		ExternalAttrs:  f.ExternalAttrs,
+	}
+	w, err := z.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(entry.data)
+	return err
+}
+
+func (z *zipWriter) Close() error { return z.zw.Close() }
+
+// tarReader は tar（gzip 圧縮を含む）のエントリを先頭から読み込みます。
+type tarReader struct {
+	tr     *tar.Reader
+	closer io.Closer
+}
+
+func (t *tarReader) Next() (*archiveEntry, error) {
+	header, err := t.tr.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	// 内容は読み込まず、圧縮対象のエントリのみ呼び出し側で load する
+	entry := &archiveEntry{
+		name:      strings.TrimSuffix(header.Name, "/"),
+		regular:   header.Typeflag == tar.TypeReg,
+		tarHeader: header,
+	}
+	if header.Size > 0 {
+		entry.body = t.tr
+	}
+	return entry, nil
+}
+
+func (t *tarReader) Close() error {
+	if t.closer != nil {
+		return t.closer.Close()
+	}
+	return nil
+}
+
+// tarWriter は tar（gzip 圧縮を含む）のエントリを書き込みます。
+type tarWriter struct {
+	tw     *tar.Writer
+	closer io.Closer
+}
+
+func (t *tarWriter) Write(entry *archiveEntry) error {
+	header := *entry.tarHeader
+	if entry.modified {
+		header.Name = entry.name
+		header.Size = int64(len(entry.data))
+	}
+	if err := t.tw.WriteHeader(&header); err != nil {
+		return err
+	}
+	if entry.body != nil {
+		// 読み込んでいないエントリは元のアーカイブから直接コピーする
+		_, err := io.Copy(t.tw, entry.body)
+		return err
+	}
+	if len(entry.data) == 0 {
+		return nil
+	}
+	_, err := t.tw.Write(entry.data)
+	return err
+}
+
+func (t *tarWriter) Close() error {
+	if err := t.tw.Close(); err != nil {
+		return err
+	}
+	if t.closer != nil {
+		return t.closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package batch
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/takumines/shuku/pkg/shuku"
+)
+
+// testArchiveEntry はテスト用アーカイブのエントリです。name が "/" で終わる場合はディレクトリです。
+type testArchiveEntry struct {
+	name string
+	data []byte
+}
+
+var testArchiveTime = time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)
+
+// createTestZip はエントリを順に格納した zip ファイルを作成します。
+func createTestZip(t *testing.T, path string, entries []testArchiveEntry) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: e.name, Method: zip.Deflate, Modified: testArchiveTime})
+		if err != nil {
+			t.Fatalf("zip エントリの作成に失敗しました: %v", err)
+		}
+		_, _ = w.Write(e.data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip の作成に失敗しました: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+	}
+}
+
+// createTestTarGz はエントリを順に格納した tar.gz ファイルを作成します。
+func createTestTarGz(t *testing.T, path string, entries []testArchiveEntry) {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.data)), ModTime: testArchiveTime, Typeflag: tar.TypeReg}
+		if strings.HasSuffix(e.name, "/") {
+			header.Typeflag = tar.TypeDir
+			header.Mode = 0755
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("tar エントリの作成に失敗しました: %v", err)
+		}
+		_, _ = tw.Write(e.data)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar の作成に失敗しました: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("gzip の作成に失敗しました: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+	}
+}
+
+// readArchiveEntry は出力アーカイブのエントリ名・内容・更新日時です。
+type readArchiveEntry struct {
+	name    string
+	data    []byte
+	modTime time.Time
+}
+
+func readTestArchive(t *testing.T, path string) []readArchiveEntry {
+	t.Helper()
+	var entries []readArchiveEntry
+	if strings.HasSuffix(path, ".zip") {
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			t.Fatalf("出力アーカイブを開けません: %v", err)
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			rc, _ := f.Open()
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			entries = append(entries, readArchiveEntry{f.Name, data, f.Modified})
+		}
+		return entries
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("出力アーカイブを開けません: %v", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("出力アーカイブを開けません: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("出力アーカイブの読み込みに失敗しました: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		entries = append(entries, readArchiveEntry{header.Name, data, header.ModTime})
+	}
+	return entries
+}
+
+func TestProcessor_ProcessArchive(t *testing.T) {
+	jpegData := encodeTestJPEG(t, 80, 80)
+	pngData := encodeTestPNG(t, 60, 60)
+	text := []byte("これはテキストファイルです")
+	entries := []testArchiveEntry{
+		{"top.jpg", jpegData},
+		{"readme.txt", text},
+		{"images/", nil},
+		{"images/logo.png", pngData},
+		{"images/broken.jpg", []byte{0xFF, 0xD8, 0xFF, 0x00}},
+	}
+
+	tests := []struct {
+		name          string
+		ext           string
+		create        func(t *testing.T, path string, entries []testArchiveEntry)
+		recursive     bool
+		expectedFiles []string // 圧縮対象として処理されるエントリ
+	}{
+		{"zip（非再帰）", ".zip", createTestZip, false, []string{"top.jpg"}},
+		{"zip（再帰）", ".zip", createTestZip, true, []string{"top.jpg", "images/logo.png", "images/broken.jpg"}},
+		{"tar.gz（非再帰）", ".tar.gz", createTestTarGz, false, []string{"top.jpg"}},
+		{"tar.gz（再帰）", ".tar.gz", createTestTarGz, true, []string{"top.jpg", "images/logo.png", "images/broken.jpg"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			inputPath := filepath.Join(dir, "assets"+tt.ext)
+			outputPath := filepath.Join(dir, "assets-compressed"+tt.ext)
+			tt.create(t, inputPath, entries)
+
+			processor := NewProcessor(2, "")
+			processor.SetRecursive(tt.recursive)
+			results, err := processor.ProcessArchive(inputPath, outputPath, shuku.Options{Quality: 50, PaletteSize: 16})
+			if err != nil {
+				t.Fatalf("ProcessArchive() error = %v", err)
+			}
+
+			// 結果はアーカイブ内の順序で返される
+			if len(results) != len(tt.expectedFiles) {
+				t.Fatalf("results count = %d, want %d", len(results), len(tt.expectedFiles))
+			}
+			for i, name := range tt.expectedFiles {
+				if want := inputPath + ":" + name; results[i].Job.InputPath != want {
+					t.Errorf("results[%d].Job.InputPath = %v, want %v", i, results[i].Job.InputPath, want)
+				}
+				if name == "images/broken.jpg" {
+					if !errors.Is(results[i].Error, shuku.ErrInvalidImage) {
+						t.Errorf("壊れた画像のエラー = %v, want ErrInvalidImage", results[i].Error)
+					}
+				} else if results[i].Error != nil {
+					t.Errorf("%s: error = %v", name, results[i].Error)
+				}
+			}
+			stats := CalculateStatistics(results)
+			if stats.TotalFiles != len(tt.expectedFiles) || stats.TotalOriginalSize == 0 {
+				t.Errorf("統計が一致しません: %+v", stats)
+			}
+
+			// エントリの順序・名前・更新日時を維持し、圧縮できなかったエントリは変更しない
+			compressed := map[string]Result{}
+			for _, result := range results {
+				if result.Error == nil {
+					compressed[strings.TrimPrefix(result.Job.InputPath, inputPath+":")] = result
+				}
+			}
+			output := readTestArchive(t, outputPath)
+			if len(output) != len(entries) {
+				t.Fatalf("出力エントリ数 = %d, want %d", len(output), len(entries))
+			}
+			for i, e := range entries {
+				got := output[i]
+				if got.name != e.name {
+					t.Errorf("エントリ[%d] = %v, want %v", i, got.name, e.name)
+				}
+				if !got.modTime.Equal(testArchiveTime) {
+					t.Errorf("%s の更新日時 = %v, want %v", e.name, got.modTime, testArchiveTime)
+				}
+
+				if result, ok := compressed[e.name]; ok {
+					if int64(len(got.data)) != result.CompressedSize {
+						t.Errorf("%s のサイズ = %d, want %d", e.name, len(got.data), result.CompressedSize)
+					}
+					if _, _, err := image.Decode(bytes.NewReader(got.data)); err != nil {
+						t.Errorf("%s をデコードできません: %v", e.name, err)
+					}
+				} else if !bytes.Equal(got.data, e.data) {
+					t.Errorf("%s の内容が変更されました", e.name)
+				}
+			}
+		})
+	}
+
+	t.Run("キャンセル時は出力しない", func(t *testing.T) {
+		dir := t.TempDir()
+		inputPath := filepath.Join(dir, "assets.zip")
+		outputPath := filepath.Join(dir, "out.zip")
+		createTestZip(t, inputPath, entries)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := NewProcessor(1, "").ProcessArchiveContext(ctx, inputPath, outputPath, shuku.Options{})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ProcessArchiveContext() error = %v, want %v", err, context.Canceled)
+		}
+		if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+			t.Error("キャンセル後に出力アーカイブが作成されました")
+		}
+	})
+
+	t.Run("入力と出力の形式が異なる", func(t *testing.T) {
+		dir := t.TempDir()
+		inputPath := filepath.Join(dir, "assets.zip")
+		createTestZip(t, inputPath, entries)
+		if _, err := NewProcessor(1, "").ProcessArchive(inputPath, filepath.Join(dir, "out.tar"), shuku.Options{}); err == nil {
+			t.Error("zip から tar への出力に対してエラーが発生しませんでした")
+		}
+	})
+}
+
+func TestArchiveFormat(t *testing.T) {
+	tests := []struct {
+		path   string
+		format string
+		ok     bool
+		output string
+	}{
+		{"assets.zip", ArchiveZip, true, "assets_compressed.zip"},
+		{"dir/Assets.TAR.GZ", ArchiveTarGz, true, "dir/Assets_compressed.TAR.GZ"},
+		{"assets.tgz", ArchiveTarGz, true, "assets_compressed.tgz"},
+		{"assets.tar", ArchiveTar, true, "assets_compressed.tar"},
+		{"photo.jpg", "", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			format, ok := ArchiveFormat(tt.path)
+			if format != tt.format || ok != tt.ok {
+				t.Errorf("ArchiveFormat() = (%v, %v), want (%v, %v)", format, ok, tt.format, tt.ok)
+			}
+			if tt.ok {
+				if got := defaultArchiveOutputPath(tt.path); got != tt.output {
+					t.Errorf("defaultArchiveOutputPath() = %v, want %v", got, tt.output)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/takumines/shuku/pkg/shuku"
+)
+
+// JournalFileName はジャーナルの既定のファイル名です。
+const JournalFileName = ".shuku-journal.jsonl"
+
+// journalHeader はジャーナルの1行目で、記録した実行の設定を表します。
+type journalHeader struct {
+	SettingsHash string `json:"settings_hash"`
+}
+
+// journalEntry は完了したジョブの記録です。ジャーナルの2行目以降に1件ずつ追記されます。
+type journalEntry struct {
+	Input          string `json:"input"`  // 入力元での名前
+	Output         string `json:"output"` // 出力先での名前
+	Format         string `json:"format"`
+	OriginalSize   int64  `json:"original_size"`
+	CompressedSize int64  `json:"compressed_size"`
+}
+
+// journal は実行中に完了したジョブを記録します。並行に使用しても安全です。
+// 中断した場合はファイルが残り、再開時に前回完了したジョブを省略するために使用します。
+type journal struct {
+	path string
+
+	mu        sync.Mutex
+	file      *os.File
+	completed map[string]journalEntry // 前回の実行で完了したジョブ（再開時のみ）
+	err       error                   // 最初の書き込みエラー
+}
+
+// runSettingsHash はジャーナルに記録する、実行全体の設定のハッシュを返します。
+// ルールを指定した場合は、ファイルごとの出力が変わるためルールも含めます。
+func (p *Processor) runSettingsHash(options shuku.Options) string {
+	hash := p.settingsHash(options, nil)
+	if len(p.Rules) == 0 {
+		return hash
+	}
+	data, _ := json.Marshal(struct {
+		Settings string
+		Rules    []Rule
+	}{hash, p.Rules})
+	return hashBytes(data)
+}
+
+// openJournal はジャーナルを開きます。
+// resume が true で前回のジャーナルが残っている場合は、完了したジョブを読み込んで追記します。
+// 前回と設定が異なる場合は、出力の内容が変わるため再開できません。
+// それ以外の場合は新しいジャーナルを作成します。
+func openJournal(path, settingsHash string, resume bool) (*journal, error) {
+	j := &journal{path: path, completed: map[string]journalEntry{}}
+
+	if resume {
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if len(data) > 0 {
+			if err := j.load(data, settingsHash); err != nil {
+				return nil, err
+			}
+			file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return nil, err
+			}
+			j.file = file
+			// 書き込み途中で中断した行の後ろに追記しないよう改行する
+			if data[len(data)-1] != '\n' {
+				if _, err := file.WriteString("\n"); err != nil {
+					_ = file.Close()
+					return nil, err
+				}
+			}
+			return j, nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	j.file = file
+	if err := j.writeLine(journalHeader{SettingsHash: settingsHash}); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// load は前回のジャーナルから完了したジョブを読み込みます。
+// 書き込み途中で中断した最後の行など、読み込めない行は無視します。
+func (j *journal) load(data []byte, settingsHash string) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var header journalHeader
+	if !scanner.Scan() || json.Unmarshal(scanner.Bytes(), &header) != nil {
+		return fmt.Errorf("ジャーナルの形式が不正です: %s", j.path)
+	}
+	if header.SettingsHash != settingsHash {
+		return fmt.Errorf("前回と設定が異なるため再開できません: %s", j.path)
+	}
+
+	for scanner.Scan() {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil && entry.Input != "" {
+			j.completed[entry.Input] = entry
+		}
+	}
+	return scanner.Err()
+}
+
+func (j *journal) lookup(name string) (journalEntry, bool) {
+	if j == nil {
+		return journalEntry{}, false
+	}
+	entry, ok := j.completed[name]
+	return entry, ok
+}
+
+// record は成功したジョブを追記します。前回の記録から省略したジョブは記録済みのため追記しません。
+// 書き込みエラーは close で返します。
+func (j *journal) record(result Result) {
+	if j == nil || result.Error != nil || result.Resumed {
+		return
+	}
+	entry := journalEntry{
+		Input:          result.Job.inputName,
+		Output:         result.Job.outputName,
+		Format:         result.DetectedFormat,
+		OriginalSize:   result.OriginalSize,
+		CompressedSize: result.CompressedSize,
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.writeLine(entry); err != nil && j.err == nil {
+		j.err = err
+	}
+}
+
+// writeLine は v を1行の JSON として書き込みます。
+// 中断時に記録が失われないよう、1回の書き込みで行全体を書き込みます。
+func (j *journal) writeLine(v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = j.file.Write(append(line, '\n'))
+	return err
+}
+
+// close はジャーナルを閉じます。completed が true の場合は、再開の必要がないためファイルを削除します。
+func (j *journal) close(completed bool) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	err := j.err
+	if closeErr := j.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("ジャーナルの書き込みに失敗しました: %w", err)
+	}
+	if completed {
+		return os.Remove(j.path)
+	}
+	return nil
+}
+
+// withOutput は出力先を前回の実行で記録した name に置き換えた Job を返します。
+func (j Job) withOutput(t target, name string) Job {
+	j.OutputPath = t.dst.Location(name)
+	j.outputName = name
+	return j
+}
+
+// resumedResult は前回の実行で完了したジョブの記録から Result を作成します。
+// 記録された出力が残っていることは呼び出し側で確認します。
+func (p *Processor) resumedResult(t target, job Job, entry journalEntry) Result {
+	// 増分処理の記録は前回の実行で保存済みのため引き継ぐ
+	t.manifest.keep(job.inputName)
+
+	return Result{
+		Job:            job.withOutput(t, entry.Output),
+		OriginalSize:   entry.OriginalSize,
+		CompressedSize: entry.CompressedSize,
+		DetectedFormat: entry.Format,
+		Resumed:        true,
+	}
+}
@@ -0,0 +1,173 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	"github.com/takumines/shuku/internal/storage"
+	"github.com/takumines/shuku/pkg/shuku"
+)
+
+// countingStorage は書き込んだ回数を数える Storage です。
+type countingStorage struct {
+	*storage.MemoryStorage
+	writes atomic.Int32
+}
+
+func (c *countingStorage) WriteFile(ctx context.Context, name string, perm fs.FileMode, write func(w io.Writer) error) error {
+	c.writes.Add(1)
+	return c.MemoryStorage.WriteFile(ctx, name, perm, write)
+}
+
+func TestProcessor_Journal(t *testing.T) {
+	const fileCount = 6
+	fsys := fstest.MapFS{}
+	for i := 0; i < fileCount; i++ {
+		fsys[fmt.Sprintf("image%d.jpg", i)] = &fstest.MapFile{Data: encodeTestJPEG(t, 40, 40), Mode: 0644}
+	}
+	options := shuku.Options{Quality: 70}
+
+	// interrupt は dst に書き込む実行を最初の結果を受け取った時点で中断し、完了したファイル数を返します。
+	interrupt := func(t *testing.T, journalPath string, dst storage.Storage) int {
+		t.Helper()
+		processor := NewProcessor(1, "")
+		processor.SetJournal(journalPath, false)
+		stopErr := errors.New("中断")
+		err := processor.StreamFS(fsys, dst, options, func(result Result) error {
+			return stopErr
+		})
+		if !errors.Is(err, stopErr) {
+			t.Fatalf("StreamFS() error = %v, want %v", err, stopErr)
+		}
+		if _, err := os.Stat(journalPath); err != nil {
+			t.Fatalf("中断後にジャーナルが残っていません: %v", err)
+		}
+		journal, err := openJournal(journalPath, NewProcessor(1, "").runSettingsHash(options), true)
+		if err != nil {
+			t.Fatalf("openJournal() error = %v", err)
+		}
+		_ = journal.close(false)
+		if len(journal.completed) == 0 || len(journal.completed) >= fileCount {
+			t.Fatalf("ジャーナルの記録数 = %d", len(journal.completed))
+		}
+		return len(journal.completed)
+	}
+
+	t.Run("中断した実行を再開する", func(t *testing.T) {
+		journalPath := filepath.Join(t.TempDir(), JournalFileName)
+		dst := &countingStorage{MemoryStorage: storage.NewMemoryStorage()}
+		completed := interrupt(t, journalPath, dst)
+		dst.writes.Store(0)
+
+		processor := NewProcessor(2, "")
+		processor.SetJournal(journalPath, true)
+		results, err := processor.ProcessFS(fsys, dst, options)
+		if err != nil {
+			t.Fatalf("ProcessFS() error = %v", err)
+		}
+
+		stats := CalculateStatistics(results)
+		if stats.TotalFiles != fileCount || stats.SuccessFiles != fileCount || stats.ResumedFiles != completed {
+			t.Errorf("統計 = %+v, want 再開による省略 %d", stats, completed)
+		}
+		for _, result := range results {
+			if result.Resumed && (result.Report != nil || result.CompressedSize == 0) {
+				t.Errorf("省略した結果が不正です: %+v", result)
+			}
+		}
+		// 省略したファイルは書き込まない
+		if written := int(dst.writes.Load()); written != fileCount-completed {
+			t.Errorf("出力ファイル数 = %d, want %d", written, fileCount-completed)
+		}
+		// すべて完了したらジャーナルを削除する
+		if _, err := os.Stat(journalPath); !os.IsNotExist(err) {
+			t.Errorf("完了後にジャーナルが残っています: %v", err)
+		}
+	})
+
+	t.Run("出力が削除されている場合は処理し直す", func(t *testing.T) {
+		journalPath := filepath.Join(t.TempDir(), JournalFileName)
+		interrupt(t, journalPath, storage.NewMemoryStorage())
+
+		// 前回の出力が残っていない出力先で再開する
+		processor := NewProcessor(2, "")
+		processor.SetJournal(journalPath, true)
+		dst := storage.NewMemoryStorage()
+		results, err := processor.ProcessFS(fsys, dst, options)
+		if err != nil {
+			t.Fatalf("ProcessFS() error = %v", err)
+		}
+		if stats := CalculateStatistics(results); stats.ResumedFiles != 0 || stats.SuccessFiles != fileCount {
+			t.Errorf("統計 = %+v", stats)
+		}
+		if written := len(dst.Names()); written != fileCount {
+			t.Errorf("出力ファイル数 = %d, want %d", written, fileCount)
+		}
+	})
+
+	t.Run("再開しない場合は最初から処理する", func(t *testing.T) {
+		journalPath := filepath.Join(t.TempDir(), JournalFileName)
+		interrupt(t, journalPath, storage.NewMemoryStorage())
+
+		processor := NewProcessor(2, "")
+		processor.SetJournal(journalPath, false)
+		results, err := processor.ProcessFS(fsys, storage.NewMemoryStorage(), options)
+		if err != nil {
+			t.Fatalf("ProcessFS() error = %v", err)
+		}
+		if stats := CalculateStatistics(results); stats.ResumedFiles != 0 || stats.SuccessFiles != fileCount {
+			t.Errorf("統計 = %+v", stats)
+		}
+	})
+
+	t.Run("設定が異なる場合は再開できない", func(t *testing.T) {
+		journalPath := filepath.Join(t.TempDir(), JournalFileName)
+		interrupt(t, journalPath, storage.NewMemoryStorage())
+
+		processor := NewProcessor(2, "")
+		processor.SetJournal(journalPath, true)
+		if _, err := processor.ProcessFS(fsys, storage.NewMemoryStorage(), shuku.Options{Quality: 30}); err == nil {
+			t.Error("設定の異なる再開に対してエラーが発生しませんでした")
+		}
+	})
+
+	t.Run("書き込み途中の行は無視する", func(t *testing.T) {
+		journalPath := filepath.Join(t.TempDir(), JournalFileName)
+		header := fmt.Sprintf(`{"settings_hash":%q}`, NewProcessor(1, "").runSettingsHash(options))
+		content := header + "\n" +
+			`{"input":"image0.jpg","output":"image0.jpg","format":"jpeg","original_size":100,"compressed_size":50}` + "\n" +
+			`{"input":"image1.jp`
+		if err := os.WriteFile(journalPath, []byte(content), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+		}
+
+		// 記録のある image0.jpg の出力のみ残っている
+		dst := storage.NewMemoryStorage()
+		if err := dst.WriteFile(context.Background(), "image0.jpg", 0644, func(w io.Writer) error {
+			_, err := w.Write(fsys["image0.jpg"].Data)
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		processor := NewProcessor(2, "")
+		processor.SetJournal(journalPath, true)
+		results, err := processor.ProcessFS(fsys, dst, options)
+		if err != nil {
+			t.Fatalf("ProcessFS() error = %v", err)
+		}
+		for _, result := range results {
+			if want := result.Job.InputPath == "image0.jpg"; result.Resumed != want {
+				t.Errorf("%s: Resumed = %v, want %v", result.Job.InputPath, result.Resumed, want)
+			}
+		}
+	})
+}
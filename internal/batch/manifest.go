@@ -0,0 +1,195 @@
+package batch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/takumines/shuku/internal/fileutil"
+	"github.com/takumines/shuku/pkg/shuku"
+)
+
+// ManifestFileName は出力ディレクトリに保存するマニフェストの既定のファイル名です。
+const ManifestFileName = ".shuku-manifest.json"
+
+// Manifest は増分処理のために、処理済みのファイルの入力・設定・出力のハッシュを記録します。
+type Manifest struct {
+	Version string                   `json:"version"` // 記録した shuku のバージョン
+	Entries map[string]ManifestEntry `json:"entries"` // 入力元のルートからの相対パス（スラッシュ区切り）ごとの記録
+}
+
+// ManifestEntry は1ファイルの処理結果の記録です。
+type ManifestEntry struct {
+	InputHash      string `json:"input_hash"`
+	SettingsHash   string `json:"settings_hash"` // 圧縮オプションと出力に影響する設定のハッシュ
+	Output         string `json:"output"`        // 出力先での名前
+	OutputHash     string `json:"output_hash"`
+	Format         string `json:"format"`
+	OriginalSize   int64  `json:"original_size"`
+	CompressedSize int64  `json:"compressed_size"`
+}
+
+// LoadManifest はマニフェストを読み込みます。ファイルが存在しない場合は空のマニフェストを返します。
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Manifest{Entries: map[string]ManifestEntry{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("マニフェストの形式が不正です: %s: %w", path, err)
+	}
+	if m.Entries == nil {
+		m.Entries = map[string]ManifestEntry{}
+	}
+	return &m, nil
+}
+
+// Save はマニフェストを path にアトミックに書き込みます。
+func (m *Manifest) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(path, 0644, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(m)
+	})
+}
+
+// manifestState は実行中のマニフェストを管理します。並行に使用しても安全です。
+// 前回の記録を参照しつつ、今回処理・確認したファイルを新しいマニフェストに記録します。
+type manifestState struct {
+	mu       sync.Mutex
+	previous map[string]ManifestEntry
+	current  map[string]ManifestEntry
+}
+
+// newManifestState は前回のマニフェストから実行中の状態を作成します。
+// 記録した shuku のバージョンが異なる場合は、前回の記録をすべて無効にします。
+func newManifestState(m *Manifest, version string) *manifestState {
+	previous := m.Entries
+	if m.Version != version {
+		previous = map[string]ManifestEntry{}
+	}
+	return &manifestState{previous: previous, current: map[string]ManifestEntry{}}
+}
+
+func (s *manifestState) lookup(name string) (ManifestEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.previous[name]
+	return entry, ok
+}
+
+func (s *manifestState) record(name string, entry ManifestEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current[name] = entry
+}
+
+// keep は前回の記録を今回の記録に引き継ぎます。nil の場合は何もしません。
+func (s *manifestState) keep(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.previous[name]; ok {
+		s.current[name] = entry
+	}
+}
+
+// manifest は今回の記録から保存用のマニフェストを作成します。
+// completed が false（中断または失敗した実行）の場合は、今回確認できなかったファイルの前回の記録も引き継ぎ、
+// 次回の実行で省略できるようにします。前回の記録は使用時に入力・出力のハッシュで検証されるため、古くなっていても安全です。
+func (s *manifestState) manifest(version string, completed bool) *Manifest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make(map[string]ManifestEntry, len(s.current))
+	if !completed {
+		for name, entry := range s.previous {
+			entries[name] = entry
+		}
+	}
+	for name, entry := range s.current {
+		entries[name] = entry
+	}
+	return &Manifest{Version: version, Entries: entries}
+}
+
+// hashBytes はデータの SHA-256 を "sha256:<16進数>" 形式で返します。
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return formatHash(sum[:])
+}
+
+// formatHash は SHA-256 のハッシュ値を "sha256:<16進数>" 形式で返します。
+func formatHash(sum []byte) string {
+	return "sha256:" + hex.EncodeToString(sum)
+}
+
+// settingsHash は出力の内容・名前に影響する設定のハッシュを返します。
+func (p *Processor) settingsHash(options shuku.Options, rule *Rule) string {
+	// ルールの品質などは options に反映済みのため、出力に影響するそれ以外の設定のみを含める。
+	// 値が既定の場合は省略し、ルールを使用しない場合のハッシュを変えない
+	var copyInput bool
+	var maxSize int64
+	if rule != nil {
+		copyInput, maxSize = rule.Copy, rule.MaxSize
+	}
+	data, _ := json.Marshal(struct {
+		Options      shuku.Options
+		FixExtension bool
+		InPlace      bool
+		OutputSuffix string
+		Copy         bool  `json:",omitempty"`
+		MaxSize      int64 `json:",omitempty"`
+	}{options, p.FixExtension, p.InPlace, p.OutputSuffix, copyInput, maxSize})
+	return hashBytes(data)
+}
+
+// readableStorage は書き込んだ内容を読み戻せる出力先です。
+// キャッシュの確認では、出力が残っていて内容が変更されていないことを確認します。
+type readableStorage interface {
+	ReadFile(name string) ([]byte, error)
+}
+
+// cachedResult は入力と設定が前回から変わっておらず、出力が残っている場合にキャッシュ済みの Result を返します。
+func (p *Processor) cachedResult(t target, job Job, inputHash string) (Result, bool) {
+	entry, ok := t.manifest.lookup(job.inputName)
+	if !ok || entry.SettingsHash != p.settingsHash(job.Options, job.rule) {
+		return Result{}, false
+	}
+	// 上書きモードでは前回の出力が今回の入力になる
+	if inputHash != entry.InputHash && !(entry.Output == job.inputName && inputHash == entry.OutputHash) {
+		return Result{}, false
+	}
+
+	dst, ok := t.dst.(readableStorage)
+	if !ok {
+		return Result{}, false
+	}
+	output, err := dst.ReadFile(entry.Output)
+	if err != nil || hashBytes(output) != entry.OutputHash {
+		return Result{}, false
+	}
+
+	t.manifest.record(job.inputName, entry)
+	return Result{
+		Job:            job.withOutput(t, entry.Output),
+		OriginalSize:   entry.OriginalSize,
+		CompressedSize: entry.CompressedSize,
+		DetectedFormat: entry.Format,
+		Cached:         true,
+	}, true
+}
@@ -0,0 +1,191 @@
+package batch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/takumines/shuku/internal/backup"
+	"github.com/takumines/shuku/internal/storage"
+	"github.com/takumines/shuku/pkg/shuku"
+)
+
+// countCached はキャッシュ済みとして省略された結果の数を返します。
+func countCached(t *testing.T, results []Result) int {
+	t.Helper()
+	cached := 0
+	for _, result := range results {
+		if result.Error != nil {
+			t.Errorf("%s: error = %v", result.Job.InputPath, result.Error)
+		}
+		if result.Cached {
+			cached++
+		}
+	}
+	return cached
+}
+
+func TestProcessor_Manifest(t *testing.T) {
+	fsys := fstest.MapFS{
+		"image1.jpg": {Data: encodeTestJPEG(t, 50, 50), Mode: 0644},
+		"image2.png": {Data: encodeTestPNG(t, 50, 50), Mode: 0644},
+	}
+	manifestPath := filepath.Join(t.TempDir(), "cache", ManifestFileName)
+	dst := storage.NewMemoryStorage()
+	options := shuku.Options{Quality: 70, PaletteSize: 256}
+
+	run := func(t *testing.T, fsys fstest.MapFS, dst storage.Storage, options shuku.Options, version string) []Result {
+		t.Helper()
+		processor := NewProcessor(2, "")
+		processor.SetManifest(manifestPath, version)
+		results, err := processor.ProcessFS(fsys, dst, options)
+		if err != nil {
+			t.Fatalf("ProcessFS() error = %v", err)
+		}
+		return results
+	}
+
+	// 初回はすべて処理し、マニフェストを保存する
+	if cached := countCached(t, run(t, fsys, dst, options, "v1")); cached != 0 {
+		t.Fatalf("初回の実行でキャッシュ済み = %d, want 0", cached)
+	}
+	m, err := LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+	if m.Version != "v1" || len(m.Entries) != 2 {
+		t.Fatalf("マニフェスト = %+v", m)
+	}
+
+	tests := []struct {
+		name       string
+		fsys       fstest.MapFS
+		dst        storage.Storage
+		options    shuku.Options
+		version    string
+		wantCached int
+	}{
+		{"変更なし", fsys, dst, options, "v1", 2},
+		{"入力の変更", fstest.MapFS{
+			"image1.jpg": {Data: encodeTestJPEG(t, 60, 60), Mode: 0644},
+			"image2.png": fsys["image2.png"],
+		}, dst, options, "v1", 1},
+		{"オプションの変更", fsys, dst, shuku.Options{Quality: 50, PaletteSize: 256}, "v1", 0},
+		{"バージョンの変更", fsys, dst, options, "v2", 0},
+		{"出力が存在しない", fsys, storage.NewMemoryStorage(), options, "v2", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 前のケースの影響を受けないよう、変更なしの状態で記録し直す
+			run(t, fsys, dst, options, "v1")
+
+			results := run(t, tt.fsys, tt.dst, tt.options, tt.version)
+			if cached := countCached(t, results); cached != tt.wantCached {
+				t.Errorf("キャッシュ済み = %d, want %d", cached, tt.wantCached)
+			}
+			for _, result := range results {
+				if result.Cached && (result.Report != nil || result.CompressedSize == 0) {
+					t.Errorf("キャッシュ済みの結果が不正です: %+v", result)
+				}
+			}
+			if stats := CalculateStatistics(results); stats.CachedFiles != tt.wantCached || stats.SuccessFiles != 2 {
+				t.Errorf("統計 = %+v", stats)
+			}
+		})
+	}
+}
+
+func TestProcessor_ManifestInPlace(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := createTestJPEGFile(t, dir, "photo.jpg", 80, 80)
+	manifestPath := filepath.Join(t.TempDir(), ManifestFileName)
+
+	processor := NewProcessor(1, "")
+	processor.SetInPlace(true, backup.Config{})
+	processor.SetManifest(manifestPath, "v1")
+
+	results, err := processor.ProcessDirectory(dir, shuku.Options{Quality: 30})
+	if err != nil || len(results) != 1 || results[0].Cached {
+		t.Fatalf("初回の実行結果 = %+v, %v", results, err)
+	}
+	compressed, _ := os.ReadFile(inputPath)
+
+	// 上書きした結果は再圧縮しない
+	results, err = processor.ProcessDirectory(dir, shuku.Options{Quality: 30})
+	if err != nil || len(results) != 1 || !results[0].Cached {
+		t.Fatalf("2回目の実行結果 = %+v, %v", results, err)
+	}
+	if after, _ := os.ReadFile(inputPath); string(after) != string(compressed) {
+		t.Error("キャッシュ済みのファイルが上書きされました")
+	}
+}
+
+func TestProcessor_ManifestInterrupted(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg"} {
+		fsys[name] = &fstest.MapFile{Data: encodeTestJPEG(t, 40, 40), Mode: 0644}
+	}
+	manifestPath := filepath.Join(t.TempDir(), ManifestFileName)
+	dst := storage.NewMemoryStorage()
+	options := shuku.Options{Quality: 70}
+
+	newProcessor := func() *Processor {
+		processor := NewProcessor(1, "")
+		processor.SetManifest(manifestPath, "v1")
+		return processor
+	}
+	if _, err := newProcessor().ProcessFS(fsys, dst, options); err != nil {
+		t.Fatalf("ProcessFS() error = %v", err)
+	}
+
+	// 最初の結果を受け取った時点で中断し、残りのファイルは確認しない
+	errStop := errors.New("中断")
+	err := newProcessor().StreamFS(fsys, dst, options, func(Result) error { return errStop })
+	if !errors.Is(err, errStop) {
+		t.Fatalf("StreamFS() error = %v, want %v", err, errStop)
+	}
+
+	m, err := LoadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("LoadManifest() error = %v", err)
+	}
+	if len(m.Entries) != len(fsys) {
+		t.Errorf("中断後のマニフェストの記録数 = %d, want %d", len(m.Entries), len(fsys))
+	}
+
+	// 中断した実行で確認しなかったファイルも省略される
+	results, err := newProcessor().ProcessFS(fsys, dst, options)
+	if err != nil {
+		t.Fatalf("ProcessFS() error = %v", err)
+	}
+	if cached := countCached(t, results); cached != len(fsys) {
+		t.Errorf("中断後の実行でキャッシュ済み = %d, want %d", cached, len(fsys))
+	}
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("存在しないファイル", func(t *testing.T) {
+		m, err := LoadManifest(filepath.Join(dir, "missing.json"))
+		if err != nil {
+			t.Fatalf("LoadManifest() error = %v", err)
+		}
+		if m.Entries == nil || len(m.Entries) != 0 {
+			t.Errorf("LoadManifest() = %+v, want 空のマニフェスト", m)
+		}
+	})
+
+	t.Run("不正な形式", func(t *testing.T) {
+		path := filepath.Join(dir, "broken.json")
+		if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+		}
+		if _, err := LoadManifest(path); err == nil {
+			t.Error("不正な形式に対してエラーが発生しませんでした")
+		}
+	})
+}
@@ -0,0 +1,281 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/takumines/shuku/internal/storage"
+	"github.com/takumines/shuku/pkg/shuku"
+)
+
+// PlannedJob は実行した場合に処理されるジョブと、その事前確認の結果です。
+type PlannedJob struct {
+	Job
+	Format    string   // ファイルの内容から判定した画像形式（判定できない場合は空）
+	Size      int64    // 入力ファイルのサイズ
+	Conflicts []string // 出力先の上書きなど、実行前に確認すべき問題
+}
+
+// Plan は実行せずに求めたバッチ処理の内容です。
+type Plan struct {
+	Jobs []PlannedJob
+
+	src     fs.FS
+	workers int
+}
+
+// PlanDirectory はディレクトリ内の画像ファイルを一括圧縮した場合の処理内容を、ファイルを書き込まずに求めます。
+// 各ファイルの先頭を読み込んで形式を判定し、出力先の重複や既存ファイルの上書きを Conflicts に記録します。
+func (p *Processor) PlanDirectory(inputDir string, options shuku.Options) (*Plan, error) {
+	return p.PlanDirectoryContext(context.Background(), inputDir, options)
+}
+
+// PlanDirectoryContext は ctx を考慮して PlanDirectory を実行します。
+func (p *Processor) PlanDirectoryContext(ctx context.Context, inputDir string, options shuku.Options) (*Plan, error) {
+	t, err := p.directoryTarget(inputDir, options)
+	if err != nil {
+		return nil, err
+	}
+	return p.plan(ctx, t, options)
+}
+
+// PlanFS は fsys 内の画像ファイルを一括圧縮して dst に書き込んだ場合の処理内容を、ファイルを書き込まずに求めます。
+// dst が fs.FS を実装している場合のみ、既存ファイルの上書きを確認します。
+func (p *Processor) PlanFS(fsys fs.FS, dst storage.Storage, options shuku.Options) (*Plan, error) {
+	return p.PlanFSContext(context.Background(), fsys, dst, options)
+}
+
+// PlanFSContext は ctx を考慮して PlanFS を実行します。
+func (p *Processor) PlanFSContext(ctx context.Context, fsys fs.FS, dst storage.Storage, options shuku.Options) (*Plan, error) {
+	t, err := p.fsTarget(fsys, dst, options)
+	if err != nil {
+		return nil, err
+	}
+	return p.plan(ctx, t, options)
+}
+
+// plan は処理対象ファイルを探索し、各ジョブの出力先と問題を求めます。
+func (p *Processor) plan(ctx context.Context, t target, options shuku.Options) (*Plan, error) {
+	plan := &Plan{Jobs: []PlannedJob{}, src: t.src, workers: p.WorkerCount}
+	err := p.collectJobs(ctx, t, options, func(job Job) error {
+		plan.Jobs = append(plan.Jobs, p.planJob(t, job))
+		return nil
+	})
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, fmt.Errorf("ファイル収集エラー: %w", err)
+	}
+
+	// 複数の入力が同じ出力先になる場合は、後から処理したファイルで上書きされる
+	byOutput := map[string][]int{}
+	for i, job := range plan.Jobs {
+		byOutput[job.outputName] = append(byOutput[job.outputName], i)
+	}
+	for i := range plan.Jobs {
+		for _, other := range byOutput[plan.Jobs[i].outputName] {
+			if other != i {
+				plan.Jobs[i].Conflicts = append(plan.Jobs[i].Conflicts,
+					fmt.Sprintf("出力先が %s と重複しています", plan.Jobs[other].InputPath))
+			}
+		}
+	}
+	return plan, nil
+}
+
+// planJob はファイルの先頭から形式を判定し、拡張子の修正を反映した出力先と問題を求めます。
+func (p *Processor) planJob(t target, job Job) PlannedJob {
+	planned := PlannedJob{Job: job}
+
+	header, size, err := readHeader(t.src, job.inputName)
+	if err != nil {
+		planned.Conflicts = append(planned.Conflicts, fmt.Sprintf("ファイルを読み込めません: %v", err))
+		return planned
+	}
+	planned.Size = size
+
+	if format, err := shuku.DetectFormat(header); err != nil {
+		planned.Conflicts = append(planned.Conflicts, "画像の形式を判定できません")
+	} else {
+		planned.Format = format
+		if !shuku.ExtensionMatchesFormat(job.InputPath, format) {
+			planned.Conflicts = append(planned.Conflicts, fmt.Sprintf("拡張子(%s)と内容(%s)が一致しません", filepath.Ext(job.InputPath), format))
+			if p.FixExtension {
+				planned.OutputPath = shuku.CorrectExtension(job.OutputPath, format)
+				planned.outputName = shuku.CorrectExtension(job.outputName, format)
+			}
+		}
+	}
+
+	if !p.InPlace && outputExists(t, planned.Job) {
+		planned.Conflicts = append(planned.Conflicts, "出力先のファイルが既に存在します（上書きされます）")
+	}
+	return planned
+}
+
+// readHeader は形式の判定に必要なファイルの先頭部分とファイルサイズを返します。
+func readHeader(fsys fs.FS, name string) ([]byte, int64, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, 0, err
+	}
+	return header[:n], info.Size(), nil
+}
+
+// outputExists は出力先にファイルが存在するかどうかを返します。確認できない場合は false を返します。
+func outputExists(t target, job Job) bool {
+	if t.dstRoot != "" {
+		_, err := os.Stat(job.OutputPath)
+		return err == nil
+	}
+	if fsys, ok := t.dst.(fs.FS); ok {
+		_, err := fs.Stat(fsys, job.outputName)
+		return err == nil
+	}
+	if dst, ok := t.dst.(readableStorage); ok {
+		_, err := dst.ReadFile(job.outputName)
+		return err == nil
+	}
+	return false
+}
+
+// FormatEstimate は形式ごとの削減量の見積もりです。
+type FormatEstimate struct {
+	Format        string
+	Files         int
+	OriginalSize  int64
+	EstimatedSize int64 // サンプルの圧縮率から推定した圧縮後のサイズ
+	SampledFiles  int   // 見積もりのために圧縮したファイル数
+}
+
+// SavedBytes は推定した削減量を返します。
+func (e FormatEstimate) SavedBytes() int64 {
+	return e.OriginalSize - e.EstimatedSize
+}
+
+// CompressionRatio は推定した削減率（%）を返します。
+func (e FormatEstimate) CompressionRatio() float64 {
+	if e.OriginalSize == 0 {
+		return 0
+	}
+	return 100.0 - float64(e.EstimatedSize)/float64(e.OriginalSize)*100.0
+}
+
+// Estimate は形式ごとに最大 samples 件のファイルをメモリ上で圧縮し、その圧縮率から全体の削減量を推定します。
+// サンプルは各形式のファイルから均等な間隔で選びます。形式を判定できないファイルは含みません。
+// 結果は形式名の順に並びます。
+func (plan *Plan) Estimate(samples int) ([]FormatEstimate, error) {
+	return plan.EstimateContext(context.Background(), samples)
+}
+
+// EstimateContext は ctx を考慮して Estimate を実行します。
+func (plan *Plan) EstimateContext(ctx context.Context, samples int) ([]FormatEstimate, error) {
+	if samples <= 0 {
+		samples = 1
+	}
+
+	byFormat := map[string][]PlannedJob{}
+	for _, job := range plan.Jobs {
+		if job.Format != "" {
+			byFormat[job.Format] = append(byFormat[job.Format], job)
+		}
+	}
+
+	type sample struct {
+		format string
+		job    PlannedJob
+	}
+	var sampleJobs []sample
+	estimates := make([]FormatEstimate, 0, len(byFormat))
+	for format, jobs := range byFormat {
+		estimate := FormatEstimate{Format: format, Files: len(jobs)}
+		for _, job := range jobs {
+			estimate.OriginalSize += job.Size
+		}
+		estimates = append(estimates, estimate)
+
+		n := min(samples, len(jobs))
+		for i := 0; i < n; i++ {
+			sampleJobs = append(sampleJobs, sample{format, jobs[i*len(jobs)/n]})
+		}
+	}
+	sort.Slice(estimates, func(i, j int) bool { return estimates[i].Format < estimates[j].Format })
+
+	// サンプルを並行に圧縮し、形式ごとに圧縮前後のサイズを集計する
+	type sampleSize struct{ original, compressed int64 }
+	var mu sync.Mutex
+	sizes := map[string]*sampleSize{}
+	counts := map[string]int{}
+
+	sampleChan := make(chan sample)
+	var wg sync.WaitGroup
+	for i := 0; i < max(plan.workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range sampleChan {
+				report, err := compressSample(ctx, plan.src, s.job)
+				if err != nil {
+					continue
+				}
+				mu.Lock()
+				if sizes[s.format] == nil {
+					sizes[s.format] = &sampleSize{}
+				}
+				sizes[s.format].original += report.InputSize
+				sizes[s.format].compressed += report.OutputSize
+				counts[s.format]++
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, s := range sampleJobs {
+		if ctx.Err() != nil {
+			break
+		}
+		sampleChan <- s
+	}
+	close(sampleChan)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range estimates {
+		e := &estimates[i]
+		e.EstimatedSize = e.OriginalSize
+		if size := sizes[e.Format]; size != nil && size.original > 0 {
+			e.SampledFiles = counts[e.Format]
+			e.EstimatedSize = int64(float64(e.OriginalSize) * float64(size.compressed) / float64(size.original))
+		}
+	}
+	return estimates, nil
+}
+
+// compressSample はサンプルのファイルをメモリ上で圧縮し、圧縮結果は破棄します。
+func compressSample(ctx context.Context, fsys fs.FS, job PlannedJob) (*shuku.Report, error) {
+	data, err := fs.ReadFile(fsys, job.inputName)
+	if err != nil {
+		return nil, err
+	}
+	report, _, err := compressJob(ctx, io.Discard, job.InputPath, data, job.Options, job.rule)
+	return report, err
+}
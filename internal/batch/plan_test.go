@@ -0,0 +1,128 @@
+package batch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/takumines/shuku/internal/storage"
+	"github.com/takumines/shuku/pkg/shuku"
+)
+
+func TestProcessor_PlanDirectory(t *testing.T) {
+	inputDir := t.TempDir()
+	createTestJPEGFile(t, inputDir, "photo.jpg", 60, 60)
+	createTestPNGFile(t, inputDir, "icon.png", 40, 40)
+	// PNG の内容を .jpg で保存し、拡張子を修正すると icon.png と出力先が重複する
+	if err := os.WriteFile(filepath.Join(inputDir, "icon.jpg"), encodeTestPNG(t, 40, 40), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "broken.jpg"), []byte("not an image"), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+	}
+
+	outputDir := filepath.Join(t.TempDir(), "out")
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		t.Fatalf("出力ディレクトリの作成に失敗しました: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "photo.jpg"), []byte("old"), 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+	}
+
+	processor := NewProcessor(2, outputDir)
+	processor.SetFixExtension(true)
+	plan, err := processor.PlanDirectory(inputDir, shuku.Options{Quality: 70})
+	if err != nil {
+		t.Fatalf("PlanDirectory() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		output    string
+		format    string
+		conflicts []string // Conflicts に含まれる文字列
+	}{
+		{"broken.jpg", "broken.jpg", "", []string{"形式を判定できません"}},
+		{"icon.jpg", "icon.png", "png", []string{"一致しません", "icon.png と重複"}},
+		{"icon.png", "icon.png", "png", []string{"icon.jpg と重複"}},
+		{"photo.jpg", "photo.jpg", "jpeg", []string{"既に存在します"}},
+	}
+	if len(plan.Jobs) != len(tests) {
+		t.Fatalf("ジョブ数 = %d, want %d", len(plan.Jobs), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := plan.Jobs[i]
+			if job.InputPath != filepath.Join(inputDir, tt.name) || job.OutputPath != filepath.Join(outputDir, tt.output) {
+				t.Errorf("ジョブ = %s → %s", job.InputPath, job.OutputPath)
+			}
+			if job.Format != tt.format {
+				t.Errorf("Format = %v, want %v", job.Format, tt.format)
+			}
+			if info, _ := os.Stat(job.InputPath); job.Size != info.Size() {
+				t.Errorf("Size = %d, want %d", job.Size, info.Size())
+			}
+			conflicts := strings.Join(job.Conflicts, "\n")
+			if len(job.Conflicts) != len(tt.conflicts) {
+				t.Errorf("Conflicts = %q", conflicts)
+			}
+			for _, want := range tt.conflicts {
+				if !strings.Contains(conflicts, want) {
+					t.Errorf("Conflicts = %q, want %q を含む", conflicts, want)
+				}
+			}
+		})
+	}
+
+	// 何も書き込まない
+	entries, _ := os.ReadDir(outputDir)
+	if data, _ := os.ReadFile(filepath.Join(outputDir, "photo.jpg")); len(entries) != 1 || string(data) != "old" {
+		t.Errorf("出力ディレクトリが変更されました: %v", entries)
+	}
+
+	t.Run("出力ディレクトリを作成しない", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "missing")
+		if _, err := NewProcessor(1, missing).PlanDirectory(inputDir, shuku.Options{}); err != nil {
+			t.Fatalf("PlanDirectory() error = %v", err)
+		}
+		if _, err := os.Stat(missing); !os.IsNotExist(err) {
+			t.Error("出力ディレクトリが作成されました")
+		}
+	})
+}
+
+func TestPlan_Estimate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.jpg":      {Data: encodeTestJPEG(t, 80, 80), Mode: 0644},
+		"b.jpg":      {Data: encodeTestJPEG(t, 60, 60), Mode: 0644},
+		"c.jpg":      {Data: encodeTestJPEG(t, 40, 40), Mode: 0644},
+		"d.png":      {Data: encodeTestPNG(t, 50, 50), Mode: 0644},
+		"broken.jpg": {Data: []byte("not an image"), Mode: 0644},
+	}
+	processor := NewProcessor(2, "")
+	plan, err := processor.PlanFS(fsys, storage.NewMemoryStorage(), shuku.Options{Quality: 30, PaletteSize: 16})
+	if err != nil {
+		t.Fatalf("PlanFS() error = %v", err)
+	}
+
+	estimates, err := plan.Estimate(2)
+	if err != nil {
+		t.Fatalf("Estimate() error = %v", err)
+	}
+	if len(estimates) != 2 || estimates[0].Format != "jpeg" || estimates[1].Format != "png" {
+		t.Fatalf("Estimate() = %+v, want jpeg, png の順", estimates)
+	}
+
+	jpeg := estimates[0]
+	wantSize := int64(len(fsys["a.jpg"].Data) + len(fsys["b.jpg"].Data) + len(fsys["c.jpg"].Data))
+	if jpeg.Files != 3 || jpeg.SampledFiles != 2 || jpeg.OriginalSize != wantSize {
+		t.Errorf("jpeg の見積もり = %+v", jpeg)
+	}
+	for _, e := range estimates {
+		if e.EstimatedSize <= 0 || e.SavedBytes() != e.OriginalSize-e.EstimatedSize {
+			t.Errorf("%s の見積もり = %+v", e.Format, e)
+		}
+	}
+}
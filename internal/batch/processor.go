@@ -1,13 +1,26 @@
 // Package batch はディレクトリ内の複数画像ファイルの一括圧縮機能を提供します。
+// 入力は fs.FS から読み込み、出力は storage.Storage を通じて書き込みます。
 package batch
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/takumines/shuku/internal/backup"
+	"github.com/takumines/shuku/internal/glob"
+	"github.com/takumines/shuku/internal/storage"
 	"github.com/takumines/shuku/pkg/shuku"
 )
 
@@ -15,7 +28,25 @@ import (
 type Job struct {
 	InputPath  string
 	OutputPath string
+	BackupPath string // 上書き前に元ファイルを退避するパス（空の場合は退避しない）
 	Options    shuku.Options
+
+	inputName  string      // 入力元の fs.FS 内のファイル名
+	inputInfo  fs.FileInfo // 探索で取得した入力ファイルの情報（取得できなかった場合は nil）
+	outputName string      // 出力先の Storage 内のファイル名
+	seq        int         // 探索で見つかった順番（結果を入力の順に並べ替えるために使用）
+	rule       *Rule       // 適用するルール（一致するルールがない場合は nil）
+}
+
+// Seq は Job が探索で見つかった順番（0 から始まる連番）を返します。
+// Stream 系のメソッドで完了順に受け取った結果を、入力の順に並べ替えて表示する場合に使用します。
+func (j Job) Seq() int {
+	return j.seq
+}
+
+// RuleName は Job に適用するルールの名前を返します（一致するルールがない場合は空）。
+func (j Job) RuleName() string {
+	return j.rule.Label()
 }
 
 // Result はジョブの実行結果を表します。
@@ -23,16 +54,41 @@ type Result struct {
 	Job            Job
 	OriginalSize   int64
 	CompressedSize int64
+	DetectedFormat string        // ファイルの内容から判定した画像形式
+	Warning        string        // 拡張子と内容の不一致など、処理は継続できた問題
+	Report         *shuku.Report // 圧縮結果の詳細（成功時のみ）
+	Cached         bool          // 前回から変更がないため圧縮を省略したかどうか（Report は nil）
+	Resumed        bool          // 中断した前回の実行で処理済みのため省略したかどうか（Report は nil）
+	Duration       time.Duration // 読み込みから書き込みまでの処理に要した時間
+	Rule           string        // 適用したルールの名前（一致するルールがない場合は空）
 	Error          error
 }
 
+// DefaultOutputSuffix は出力ディレクトリを指定しない場合に出力ファイル名に付けるサフィックスの既定値です。
+const DefaultOutputSuffix = "_compressed"
+
+// DefaultIgnoreFileName は探索中に読み込む除外ファイルの名前の既定値です。
+const DefaultIgnoreFileName = ".shukuignore"
+
 // Processor はバッチ処理を管理します。
 type Processor struct {
-	WorkerCount  int      // 並行処理数
-	OutputDir    string   // 出力ディレクトリ
-	Recursive    bool     // 再帰的処理フラグ
-	IncludeGlobs []string // 処理対象ファイルパターン
-	ExcludeGlobs []string // 除外ファイルパターン
+	WorkerCount  int           // 並行処理数
+	OutputDir    string        // 出力ディレクトリ
+	OutputSuffix string        // 出力ディレクトリを指定しない場合に出力ファイル名に付けるサフィックス
+	Recursive    bool          // 再帰的処理フラグ
+	IncludeGlobs []string      // 処理対象ファイルパターン
+	ExcludeGlobs []string      // 除外ファイルパターン
+	IgnoreCase   bool          // パターンの大文字と小文字を区別しないかどうか
+	IgnoreFile   string        // 探索中に各ディレクトリから読み込む除外ファイルの名前（空の場合は読み込まない）
+	Rules        []Rule        // パスごとの圧縮オプションの上書き（最初に一致したルールを適用）
+	FixExtension bool          // 内容と一致しない拡張子を出力時に修正するかどうか
+	InPlace      bool          // 入力ファイルを圧縮結果で上書きするかどうか
+	Backup       backup.Config // 上書き前のバックアップ設定
+	ManifestPath string        // 増分処理のマニフェストのパス（空の場合は増分処理を行わない）
+	Version      string        // マニフェストに記録する shuku のバージョン
+	JournalPath  string        // 完了したジョブを記録するジャーナルのパス（空の場合は記録しない）
+	Resume       bool          // ジャーナルに記録された前回の実行を再開するかどうか
+	Progress     func(Event)   // 進捗イベントの送信先（nil の場合は送信しない）
 }
 
 // NewProcessor は新しいProcessorインスタンスを作成します。
@@ -44,104 +100,466 @@ func NewProcessor(workerCount int, outputDir string) *Processor {
 	return &Processor{
 		WorkerCount:  workerCount,
 		OutputDir:    outputDir,
+		OutputSuffix: DefaultOutputSuffix,
 		Recursive:    false,
-		IncludeGlobs: []string{"*.jpg", "*.jpeg", "*.png", "*.webp"},
+		IncludeGlobs: DefaultIncludePatterns(),
 		ExcludeGlobs: []string{},
+		IgnoreFile:   DefaultIgnoreFileName,
 	}
 }
 
+// DefaultIncludePatterns は登録済みの画像形式から処理対象ファイルパターンを生成します。
+// shuku.Register で追加した形式も含まれます。
+func DefaultIncludePatterns() []string {
+	formats := shuku.Formats()
+	globs := make([]string, 0, len(formats))
+	for _, format := range formats {
+		globs = append(globs, "*."+format)
+	}
+	return globs
+}
+
 // SetRecursive は再帰的処理を有効または無効にします。
 func (p *Processor) SetRecursive(recursive bool) {
 	p.Recursive = recursive
 }
 
 // SetIncludePatterns は処理対象ファイルパターンを設定します。
+// パターンの書式は glob.Match と同じで、"/" を含まないパターンはファイル名と、
+// "/" を含むパターンは入力ディレクトリからの相対パスと比較します（"**" は任意の階層に一致します）。
 func (p *Processor) SetIncludePatterns(patterns []string) {
 	p.IncludeGlobs = patterns
 }
 
-// SetExcludePatterns は除外ファイルパターンを設定します。
+// SetExcludePatterns は除外ファイルパターンを設定します。パターンの書式は SetIncludePatterns と同じです。
 func (p *Processor) SetExcludePatterns(patterns []string) {
 	p.ExcludeGlobs = patterns
 }
 
+// SetIgnoreCase はパターンと除外ファイルの比較で大文字と小文字を区別しないかどうかを設定します。
+func (p *Processor) SetIgnoreCase(ignoreCase bool) {
+	p.IgnoreCase = ignoreCase
+}
+
+// SetIgnoreFile は探索中に各ディレクトリから読み込む除外ファイルの名前を設定します。
+// 除外ファイルは gitignore と同じ書式で、そのディレクトリ以下のファイルに適用されます。
+// 空の場合は除外ファイルを読み込みません。
+func (p *Processor) SetIgnoreFile(name string) {
+	p.IgnoreFile = name
+}
+
+// SetFixExtension は拡張子と内容が一致しない場合に、出力ファイルの拡張子を修正するかどうかを設定します。
+func (p *Processor) SetFixExtension(fix bool) {
+	p.FixExtension = fix
+}
+
+// SetOutputSuffix は出力ディレクトリを指定しない場合に出力ファイル名に付けるサフィックスを設定します。
+// このサフィックスで終わるファイルは以前の実行の出力とみなし、処理対象から除外します。
+func (p *Processor) SetOutputSuffix(suffix string) {
+	p.OutputSuffix = suffix
+}
+
+// SetInPlace は入力ファイルを圧縮結果で上書きするかどうかを設定します。
+// 上書きはアトミックに行われ、backupConfig が有効な場合は上書き前に元ファイルを退避します。
+func (p *Processor) SetInPlace(inPlace bool, backupConfig backup.Config) {
+	p.InPlace = inPlace
+	p.Backup = backupConfig
+}
+
+// SetManifest は増分処理を有効にし、マニフェストを path に保存します。
+// 入力の内容と設定が前回から変わっておらず、出力が残っているファイルは圧縮を省略して
+// Cached を true にした Result を返します。version が前回と異なる場合はすべてのファイルを処理し直します。
+func (p *Processor) SetManifest(path, version string) {
+	p.ManifestPath = path
+	p.Version = version
+}
+
+// SetJournal は完了したジョブを path のジャーナルに記録します。
+// ジャーナルは処理がすべて完了すると削除され、中断した場合のみ残ります。
+// resume が true の場合は残っているジャーナルを読み込み、前回完了したジョブを省略して
+// Resumed を true にした Result を返します。前回と設定が異なる場合はエラーになります。
+func (p *Processor) SetJournal(path string, resume bool) {
+	p.JournalPath = path
+	p.Resume = resume
+}
+
 // ProcessDirectory はディレクトリ内の画像ファイルを一括圧縮します。
+// 結果は並行処理の完了順によらず、探索した順（ディレクトリごとにファイル名の辞書順）に並びます。
+// 完了した順に結果を受け取る場合は StreamDirectory を使用してください。
 func (p *Processor) ProcessDirectory(inputDir string, options shuku.Options) ([]Result, error) {
-	// 入力ディレクトリの存在確認
-	if _, err := os.Stat(inputDir); os.IsNotExist(err) {
-		return nil, fmt.Errorf("入力ディレクトリが存在しません: %s", inputDir)
+	return p.ProcessDirectoryContext(context.Background(), inputDir, options)
+}
+
+// ProcessDirectoryContext は ctx を考慮してディレクトリ内の画像ファイルを一括圧縮します。
+// ctx がキャンセルされると全ワーカーの圧縮処理が中断され、処理待ちのジョブは ctx.Err() を
+// Error に持つ Result として返されます。その場合、戻り値のエラーも ctx.Err() になります。
+// 結果の順序は ProcessDirectory と同じです。
+// 結果をすべてメモリに保持するため、大量のファイルを処理する場合は StreamDirectoryContext を使用してください。
+func (p *Processor) ProcessDirectoryContext(ctx context.Context, inputDir string, options shuku.Options) ([]Result, error) {
+	results := []Result{}
+	err := p.StreamDirectoryContext(ctx, inputDir, options, func(result Result) error {
+		results = append(results, result)
+		return nil
+	})
+	sortResults(results)
+	return results, err
+}
+
+// StreamDirectory はディレクトリ内の画像ファイルを一括圧縮し、各ファイルの結果を完了した順に fn に渡します。
+// 完了順は並行処理のため実行ごとに異なります。入力の順に扱う場合は Job.Seq で並べ替えてください。
+func (p *Processor) StreamDirectory(inputDir string, options shuku.Options, fn func(Result) error) error {
+	return p.StreamDirectoryContext(context.Background(), inputDir, options, fn)
+}
+
+// StreamDirectoryContext は ctx を考慮して StreamDirectory を実行します。
+// ファイルの探索と圧縮は並行して行われ、探索中のファイル数によらず使用するメモリは一定に保たれます。
+// fn は呼び出し元のゴルーチンから1件ずつ呼び出され、エラーを返すと処理を中断してそのエラーを返します。
+// キャンセル時の動作は ProcessDirectoryContext と同じです。
+func (p *Processor) StreamDirectoryContext(ctx context.Context, inputDir string, options shuku.Options, fn func(Result) error) error {
+	t, err := p.directoryTarget(inputDir, options)
+	if err != nil {
+		return err
 	}
 
 	// 出力ディレクトリの作成
 	if p.OutputDir != "" {
 		if err := os.MkdirAll(p.OutputDir, 0755); err != nil {
-			return nil, fmt.Errorf("出力ディレクトリの作成に失敗しました: %v", err)
+			return fmt.Errorf("出力ディレクトリの作成に失敗しました: %w", err)
 		}
 	}
+	return p.process(ctx, t, options, fn)
+}
+
+// directoryTarget は設定を検証し、OS のディレクトリを処理する target を作成します。
+func (p *Processor) directoryTarget(inputDir string, options shuku.Options) (target, error) {
+	// 入力ディレクトリの存在確認
+	if _, err := os.Stat(inputDir); os.IsNotExist(err) {
+		return target{}, fmt.Errorf("入力ディレクトリが存在しません: %s", inputDir)
+	}
+
+	if p.InPlace && p.OutputDir != "" {
+		return target{}, fmt.Errorf("上書きモードでは出力ディレクトリを指定できません")
+	}
+	if !p.InPlace && p.OutputDir == "" && p.OutputSuffix == "" {
+		return target{}, fmt.Errorf("出力ディレクトリを指定しない場合は出力のサフィックスを空にできません（上書きする場合は上書きモードを使用してください）")
+	}
 
-	// 処理対象ファイルを収集
-	jobs, err := p.collectJobs(inputDir, options)
+	// 全ファイル共通のオプションとルールは処理の開始前に検証する
+	if err := options.Validate(); err != nil {
+		return target{}, err
+	}
+	if err := validateRules(p.Rules); err != nil {
+		return target{}, err
+	}
+
+	outputRoot := inputDir
+	if p.OutputDir != "" {
+		outputRoot = p.OutputDir
+	}
+	return target{
+		src:     os.DirFS(inputDir),
+		dst:     storage.NewDirStorage(outputRoot),
+		srcRoot: inputDir,
+		dstRoot: outputRoot,
+	}, nil
+}
+
+// ProcessFS は fsys 内の画像ファイルを一括圧縮し、圧縮結果を dst に書き込みます。
+// 出力は入力と同じ名前（fsys のルートからの相対パス）で dst に保存されます。
+// 入力と出力が別の場所になるため、上書きモードは使用できません。
+// 結果は ProcessDirectory と同様に探索した順に並びます。
+func (p *Processor) ProcessFS(fsys fs.FS, dst storage.Storage, options shuku.Options) ([]Result, error) {
+	return p.ProcessFSContext(context.Background(), fsys, dst, options)
+}
+
+// ProcessFSContext は ctx を考慮して ProcessFS を実行します。
+// キャンセル時の動作は ProcessDirectoryContext と同じです。
+func (p *Processor) ProcessFSContext(ctx context.Context, fsys fs.FS, dst storage.Storage, options shuku.Options) ([]Result, error) {
+	results := []Result{}
+	err := p.StreamFSContext(ctx, fsys, dst, options, func(result Result) error {
+		results = append(results, result)
+		return nil
+	})
+	sortResults(results)
+	return results, err
+}
+
+// sortResults は完了順に受け取った結果を、ジョブを探索した順に並べ替えます。
+func sortResults(results []Result) {
+	sort.Slice(results, func(i, j int) bool { return results[i].Job.seq < results[j].Job.seq })
+}
+
+// StreamFS は fsys 内の画像ファイルを一括圧縮して dst に書き込み、各ファイルの結果を完了した順に fn に渡します。
+func (p *Processor) StreamFS(fsys fs.FS, dst storage.Storage, options shuku.Options, fn func(Result) error) error {
+	return p.StreamFSContext(context.Background(), fsys, dst, options, fn)
+}
+
+// StreamFSContext は ctx を考慮して StreamFS を実行します。
+// fn の呼び出しとキャンセル時の動作は StreamDirectoryContext と同じです。
+func (p *Processor) StreamFSContext(ctx context.Context, fsys fs.FS, dst storage.Storage, options shuku.Options, fn func(Result) error) error {
+	t, err := p.fsTarget(fsys, dst, options)
 	if err != nil {
-		return nil, fmt.Errorf("ファイル収集エラー: %v", err)
+		return err
+	}
+	return p.process(ctx, t, options, fn)
+}
+
+// fsTarget は設定を検証し、fs.FS から Storage に出力する target を作成します。
+func (p *Processor) fsTarget(fsys fs.FS, dst storage.Storage, options shuku.Options) (target, error) {
+	if p.InPlace {
+		return target{}, fmt.Errorf("ProcessFS では上書きモードを使用できません")
+	}
+
+	// 全ファイル共通のオプションとルールは処理の開始前に検証する
+	if err := options.Validate(); err != nil {
+		return target{}, err
+	}
+	if err := validateRules(p.Rules); err != nil {
+		return target{}, err
 	}
+	return target{src: fsys, dst: dst}, nil
+}
 
-	if len(jobs) == 0 {
-		return []Result{}, nil
+// target はバッチ処理の入力元と出力先を表します。
+// srcRoot と dstRoot は OS のディレクトリを処理する場合のみ設定され、表示用のパスの生成に使用されます。
+type target struct {
+	src     fs.FS
+	dst     storage.Storage
+	srcRoot string
+	dstRoot string
+
+	manifest *manifestState   // 増分処理が有効な場合のみ設定
+	journal  *journal         // ジャーナルが有効な場合のみ設定
+	progress *progressTracker // 進捗イベントの送信先が設定されている場合のみ設定
+}
+
+// locator は表示用の場所を返せる入力元です（storage.S3Storage など）。
+type locator interface {
+	Location(name string) string
+}
+
+// inputPath は入力ファイル名を表示用のパスに変換します。
+func (t target) inputPath(name string) string {
+	if t.srcRoot == "" {
+		if l, ok := t.src.(locator); ok {
+			return l.Location(name)
+		}
+		return name
 	}
+	return filepath.Join(t.srcRoot, filepath.FromSlash(name))
+}
+
+// process は処理対象ファイルを探索しながら並行処理で圧縮し、結果を fn に渡します。
+// 探索・圧縮・結果の受け渡しはチャネルで接続され、各チャネルの容量はワーカー数に比例します。
+func (p *Processor) process(ctx context.Context, t target, options shuku.Options, fn func(Result) error) error {
+	// 前回のマニフェストを読み込む
+	if p.ManifestPath != "" {
+		m, err := LoadManifest(p.ManifestPath)
+		if err != nil {
+			return fmt.Errorf("マニフェストの読み込みに失敗しました: %w", err)
+		}
+		t.manifest = newManifestState(m, p.Version)
+	}
+
+	// 完了したジョブを記録するジャーナルを開く
+	if p.JournalPath != "" {
+		j, err := openJournal(p.JournalPath, p.runSettingsHash(options), p.Resume)
+		if err != nil {
+			return fmt.Errorf("ジャーナルを開けません: %w", err)
+		}
+		t.journal = j
+	}
+
+	t.progress = p.newProgressTracker()
+
+	// fn がエラーを返した場合に探索と圧縮を中断するため、内部用のコンテキストを使用する
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobChan := make(chan Job, p.WorkerCount)
+	resultChan := make(chan Result, p.WorkerCount)
+
+	// 処理対象ファイルを探索してジョブを送信
+	var walkErr error
+	jobCount := 0
+	go func() {
+		defer close(jobChan)
+		walkErr = p.collectJobs(runCtx, t, options, func(job Job) error {
+			// ワーカーが開始する前に数えるため、送信前に記録する
+			t.progress.found()
+			job.seq = jobCount
+			select {
+			case jobChan <- job:
+				jobCount++
+				return nil
+			case <-runCtx.Done():
+				return runCtx.Err()
+			}
+		})
+		// 中断した場合も、送信したジョブの数で総数を確定する
+		t.progress.scanCompleted(jobCount)
+	}()
 
 	// 並行処理でジョブを実行
-	return p.executeJobs(jobs), nil
+	go func() {
+		defer close(resultChan)
+		p.executeJobs(runCtx, t, jobChan, resultChan)
+	}()
+
+	// 結果を受け渡す。fn がエラーを返した後も、ワーカーが停止するまで結果を読み捨てる
+	var fnErr error
+	for result := range resultChan {
+		if fnErr != nil {
+			continue
+		}
+		if err := fn(result); err != nil {
+			fnErr = err
+			cancel()
+		}
+	}
+
+	var err error
+	switch {
+	case fnErr != nil:
+		err = fnErr
+	case ctx.Err() != nil:
+		err = ctx.Err()
+	case walkErr != nil:
+		err = fmt.Errorf("ファイル収集エラー: %w", walkErr)
+	}
+
+	// 中断した場合も、処理済みのファイルは次回に省略できるよう保存する。
+	// 未処理のファイルの前回の記録は、すべて完了した場合を除いて引き継ぐ
+	if t.manifest != nil && jobCount > 0 {
+		if saveErr := t.manifest.manifest(p.Version, err == nil).Save(p.ManifestPath); saveErr != nil && err == nil {
+			err = fmt.Errorf("マニフェストの保存に失敗しました: %w", saveErr)
+		}
+	}
+	// すべて完了した場合は再開の必要がないためジャーナルを削除する
+	if t.journal != nil {
+		if closeErr := t.journal.close(err == nil); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
 }
 
-// collectJobs は処理対象ファイルを収集してJobsを作成します。
-func (p *Processor) collectJobs(inputDir string, options shuku.Options) ([]Job, error) {
-	var jobs []Job
+// collectJobs は処理対象ファイルを探索し、見つけた順に Job を作成して emit に渡します。
+// 各ディレクトリの除外ファイルは、そのディレクトリ以下を探索する前に読み込みます。
+// emit がエラーを返すと探索を中断します。
+func (p *Processor) collectJobs(ctx context.Context, t target, options shuku.Options, emit func(Job) error) error {
+	var ignore *glob.Ignore
+	if p.IgnoreFile != "" {
+		ignore = glob.NewIgnore(p.IgnoreCase)
+	}
 
-	walkFunc := func(path string, info os.FileInfo, err error) error {
+	walkFunc := func(name string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		path := t.inputPath(name)
 
 		// ディレクトリの場合
-		if info.IsDir() {
+		if d.IsDir() {
+			// バックアップディレクトリは処理対象から除外
+			if p.Backup.Dir != "" && filepath.Clean(path) == filepath.Clean(p.Backup.Dir) {
+				return fs.SkipDir
+			}
+			// 入力ディレクトリ内の出力ディレクトリは、以前の出力を再圧縮しないよう除外
+			if name != "." && t.srcRoot != "" && p.OutputDir != "" && filepath.Clean(path) == filepath.Clean(p.OutputDir) {
+				return fs.SkipDir
+			}
 			// ルートディレクトリではない かつ 再帰処理が無効の場合はスキップ
-			if path != inputDir && !p.Recursive {
-				return filepath.SkipDir
+			if name != "." && !p.Recursive {
+				return fs.SkipDir
 			}
-			return nil
+			// 除外ファイルで除外されたディレクトリはスキップ
+			if name != "." && ignore.Match(name, true) {
+				return fs.SkipDir
+			}
+			return p.loadIgnoreFile(t, name, ignore)
 		}
 
 		// ファイルのフィルタリング
-		if p.shouldIncludeFile(path) {
-			outputPath := p.generateOutputPath(path, inputDir)
-			jobs = append(jobs, Job{
-				InputPath:  path,
-				OutputPath: outputPath,
-				Options:    options,
-			})
+		if !p.shouldIncludeFile(name) || ignore.Match(name, false) || p.isGeneratedOutput(t, path) {
+			return nil
 		}
 
-		return nil
+		rule := p.matchRule(name)
+		job := Job{
+			InputPath: path,
+			Options:   rule.apply(options),
+			inputName: name,
+			rule:      rule,
+		}
+		// 一覧で取得済みの情報を使用し、ファイルごとの問い合わせ（S3 の HeadObject など）を省略する
+		// （シンボリックリンクはリンク先の情報が必要なため、処理時に取得する）
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				job.inputInfo = info
+			}
+		}
+		if t.srcRoot == "" {
+			job.OutputPath = t.dst.Location(name)
+			job.outputName = name
+		} else {
+			job.OutputPath = p.generateOutputPath(path, t.srcRoot)
+			rel, err := filepath.Rel(t.dstRoot, job.OutputPath)
+			if err != nil {
+				return err
+			}
+			job.outputName = filepath.ToSlash(rel)
+		}
+		if p.InPlace && p.Backup.Enabled() {
+			backupPath, err := p.Backup.Path(t.srcRoot, path)
+			if err != nil {
+				return err
+			}
+			job.BackupPath = backupPath
+		}
+		return emit(job)
 	}
 
-	err := filepath.Walk(inputDir, walkFunc)
-	return jobs, err
+	return fs.WalkDir(t.src, ".", walkFunc)
 }
 
-// shouldIncludeFile はファイルが処理対象かどうかを判定します。
-func (p *Processor) shouldIncludeFile(filePath string) bool {
-	fileName := filepath.Base(filePath)
+// loadIgnoreFile はディレクトリ dir の除外ファイルを読み込み、ignore に追加します。
+// 除外ファイルが存在しない場合は何もしません。
+func (p *Processor) loadIgnoreFile(t target, dir string, ignore *glob.Ignore) error {
+	if ignore == nil {
+		return nil
+	}
+	name := path.Join(dir, p.IgnoreFile)
+	data, err := fs.ReadFile(t.src, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("除外ファイルを読み込めません: %w", err)
+	}
+	if err := ignore.Add(dir, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("除外ファイル %s: %w", t.inputPath(name), err)
+	}
+	return nil
+}
 
+// shouldIncludeFile はファイルが処理対象かどうかを判定します。
+// name は入力元のルートからの "/" 区切りの相対パスです。
+func (p *Processor) shouldIncludeFile(name string) bool {
 	// 除外パターンのチェック
 	for _, pattern := range p.ExcludeGlobs {
-		if matched, _ := filepath.Match(pattern, fileName); matched {
+		if matched, _ := glob.Match(pattern, name, p.IgnoreCase); matched {
 			return false
 		}
 	}
 
 	// 包含パターンのチェック
 	for _, pattern := range p.IncludeGlobs {
-		if matched, _ := filepath.Match(pattern, fileName); matched {
+		if matched, _ := glob.Match(pattern, name, p.IgnoreCase); matched {
 			return true
 		}
 	}
@@ -149,13 +567,27 @@ func (p *Processor) shouldIncludeFile(filePath string) bool {
 	return false
 }
 
+// isGeneratedOutput はファイルが以前の実行で入力の隣に生成した出力かどうかを判定します。
+// 出力ディレクトリを指定しない場合のみ、ファイル名（拡張子を除く）が OutputSuffix で終わるものを出力とみなします。
+func (p *Processor) isGeneratedOutput(t target, path string) bool {
+	if t.srcRoot == "" || p.InPlace || p.OutputDir != "" || p.OutputSuffix == "" {
+		return false
+	}
+	base := filepath.Base(path)
+	return strings.HasSuffix(strings.TrimSuffix(base, filepath.Ext(base)), p.OutputSuffix)
+}
+
 // generateOutputPath は出力ファイルパスを生成します。
 func (p *Processor) generateOutputPath(inputPath, inputDir string) string {
+	if p.InPlace {
+		return inputPath
+	}
+
 	if p.OutputDir == "" {
-		// 出力ディレクトリが指定されていない場合は、入力ファイルと同じディレクトリに "_compressed" を追加
+		// 出力ディレクトリが指定されていない場合は、入力ファイルと同じディレクトリにサフィックスを追加
 		ext := filepath.Ext(inputPath)
 		base := strings.TrimSuffix(inputPath, ext)
-		return base + "_compressed" + ext
+		return base + p.OutputSuffix + ext
 	}
 
 	// 入力ディレクトリからの相対パスを計算
@@ -168,75 +600,157 @@ func (p *Processor) generateOutputPath(inputPath, inputDir string) string {
 	return filepath.Join(p.OutputDir, relPath)
 }
 
-// executeJobs は並行処理でジョブを実行します。
-func (p *Processor) executeJobs(jobs []Job) []Result {
-	jobChan := make(chan Job, len(jobs))
-	resultChan := make(chan Result, len(jobs))
-
+// executeJobs は jobChan が閉じられるまで並行処理でジョブを実行し、結果を resultChan に送信します。
+// すべてのワーカーが終了してから戻ります。
+func (p *Processor) executeJobs(ctx context.Context, t target, jobChan <-chan Job, resultChan chan<- Result) {
 	// ワーカーを起動
 	var wg sync.WaitGroup
 	for i := 0; i < p.WorkerCount; i++ {
 		wg.Add(1)
-		go p.worker(&wg, jobChan, resultChan)
-	}
-
-	// ジョブをチャネルに送信
-	for _, job := range jobs {
-		jobChan <- job
+		go p.worker(ctx, t, &wg, jobChan, resultChan)
 	}
-	close(jobChan)
 
 	// ワーカーの完了を待機
 	wg.Wait()
-	close(resultChan)
-
-	// 結果を収集
-	var results []Result
-	for result := range resultChan {
-		results = append(results, result)
-	}
-
-	return results
 }
 
 // worker は単一のワーカーゴルーチンを実装します。
-func (p *Processor) worker(wg *sync.WaitGroup, jobChan <-chan Job, resultChan chan<- Result) {
+func (p *Processor) worker(ctx context.Context, t target, wg *sync.WaitGroup, jobChan <-chan Job, resultChan chan<- Result) {
 	defer wg.Done()
 
 	for job := range jobChan {
-		result := p.processJob(job)
+		t.progress.started(job)
+		start := time.Now()
+		result := p.processJob(ctx, t, job)
+		result.Duration = time.Since(start)
+		result.Rule = job.rule.Label()
+		t.journal.record(result)
+		t.progress.finished(result)
 		resultChan <- result
 	}
 }
 
 // processJob は単一のジョブを処理します。
-func (p *Processor) processJob(job Job) Result {
+func (p *Processor) processJob(ctx context.Context, t target, job Job) Result {
 	result := Result{Job: job}
 
-	// 入力ファイルのサイズを取得
-	if inputInfo, err := os.Stat(job.InputPath); err == nil {
-		result.OriginalSize = inputInfo.Size()
+	// キャンセル済みの場合は処理しない
+	if err := ctx.Err(); err != nil {
+		result.Error = err
+		return result
 	}
 
-	// 出力ディレクトリを作成
-	outputDir := filepath.Dir(job.OutputPath)
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		result.Error = fmt.Errorf("出力ディレクトリの作成に失敗しました: %v", err)
+	// 中断した前回の実行で完了し、出力が残っている場合は省略
+	if entry, ok := t.journal.lookup(job.inputName); ok && outputExists(t, job.withOutput(t, entry.Output)) {
+		return p.resumedResult(t, job, entry)
+	}
+
+	// 入力ファイルを読み込む
+	// 入力全体をメモリに読み込んでから出力を書き込むため、上書きモードでも安全に置き換えられる
+	data, err := fs.ReadFile(t.src, job.inputName)
+	if err != nil {
+		result.Error = fmt.Errorf("圧縮処理エラー: %w", &shuku.CompressError{Stage: shuku.StageRead, Path: job.InputPath, Err: err})
 		return result
 	}
+	perm := fs.FileMode(0644)
+	info := job.inputInfo
+	if info == nil {
+		info, _ = fs.Stat(t.src, job.inputName)
+	}
+	if info != nil {
+		perm = info.Mode().Perm()
+	}
+
+	// 前回から変更がない場合は圧縮を省略
+	var inputHash string
+	if t.manifest != nil {
+		inputHash = hashBytes(data)
+		if cached, ok := p.cachedResult(t, job, inputHash); ok {
+			return cached
+		}
+	}
+
+	// ファイルの内容から形式を判定し、拡張子との不一致を確認
+	if format, err := shuku.DetectFormat(data); err == nil {
+		result.DetectedFormat = format
+		if !shuku.ExtensionMatchesFormat(job.InputPath, format) {
+			result.Warning = fmt.Sprintf("拡張子(%s)と内容(%s)が一致しません", filepath.Ext(job.InputPath), format)
+			if p.FixExtension {
+				job.OutputPath = shuku.CorrectExtension(job.OutputPath, format)
+				job.outputName = shuku.CorrectExtension(job.outputName, format)
+				result.Job = job
+			}
+		}
+	}
+
+	// 上書きモードで拡張子を修正する場合は、別名で書き込んだ後に元ファイルを削除する
+	renamed := p.InPlace && job.outputName != job.inputName
+	if renamed {
+		if _, err := fs.Stat(t.src, job.outputName); err == nil {
+			result.Error = fmt.Errorf("拡張子を修正した出力先のファイルが既に存在します: %s", job.OutputPath)
+			return result
+		}
+	}
 
-	// 圧縮処理を実行
-	err := shuku.CompressFile(job.InputPath, job.OutputPath, job.Options)
+	// 上書き前に元ファイルを退避
+	if job.BackupPath != "" {
+		if err := backup.Save(job.InputPath, job.BackupPath); err != nil {
+			result.Error = fmt.Errorf("バックアップに失敗しました: %w", err)
+			return result
+		}
+		// restore で別名のファイルを削除できるよう、変更後の名前を記録
+		if renamed {
+			if err := backup.SaveRename(job.BackupPath, job.OutputPath); err != nil {
+				result.Error = fmt.Errorf("バックアップに失敗しました: %w", err)
+				return result
+			}
+		}
+	}
+
+	// 圧縮処理を実行し、成功した場合のみ出力先に保存する
+	var report *shuku.Report
+	var warning string
+	outputHash := sha256.New()
+	err = t.dst.WriteFile(ctx, job.outputName, perm, func(w io.Writer) error {
+		var err error
+		report, warning, err = compressJob(ctx, io.MultiWriter(w, outputHash), job.InputPath, data, job.Options, job.rule)
+		return err
+	})
 	if err != nil {
-		result.Error = fmt.Errorf("圧縮処理エラー: %v", err)
+		if report != nil {
+			// 圧縮は成功したが出力先への保存に失敗した
+			err = &shuku.CompressError{Stage: shuku.StageWrite, Format: report.Format, Path: job.OutputPath, Err: err}
+		}
+		result.Error = fmt.Errorf("圧縮処理エラー: %w", err)
 		return result
 	}
+	report.OutputPath = job.OutputPath
+	if renamed {
+		if err := os.Remove(job.InputPath); err != nil {
+			result.Error = fmt.Errorf("元のファイルを削除できません: %w", err)
+			return result
+		}
+	}
 
-	// 出力ファイルのサイズを取得
-	if outputInfo, err := os.Stat(job.OutputPath); err == nil {
-		result.CompressedSize = outputInfo.Size()
+	if t.manifest != nil {
+		t.manifest.record(job.inputName, ManifestEntry{
+			InputHash:      inputHash,
+			SettingsHash:   p.settingsHash(job.Options, job.rule),
+			Output:         job.outputName,
+			OutputHash:     formatHash(outputHash.Sum(nil)),
+			Format:         report.Format,
+			OriginalSize:   report.InputSize,
+			CompressedSize: report.OutputSize,
+		})
 	}
 
+	// 圧縮結果を反映
+	result.Warning = joinWarnings(result.Warning, warning)
+	result.Report = report
+	result.OriginalSize = report.InputSize
+	result.CompressedSize = report.OutputSize
+	result.DetectedFormat = report.Format
+
 	return result
 }
 
@@ -244,6 +758,8 @@ func (p *Processor) processJob(job Job) Result {
 type Statistics struct {
 	TotalFiles          int
 	SuccessFiles        int
+	CachedFiles         int // 成功のうち、前回から変更がないため圧縮を省略した数
+	ResumedFiles        int // 成功のうち、中断した前回の実行で処理済みのため省略した数
 	FailedFiles         int
 	TotalOriginalSize   int64
 	TotalCompressedSize int64
@@ -252,23 +768,32 @@ type Statistics struct {
 
 // CalculateStatistics は処理結果から統計情報を計算します。
 func CalculateStatistics(results []Result) Statistics {
-	stats := Statistics{
-		TotalFiles: len(results),
+	var stats Statistics
+	for _, result := range results {
+		stats.Add(result)
 	}
+	return stats
+}
 
-	for _, result := range results {
-		if result.Error != nil {
-			stats.FailedFiles++
-		} else {
-			stats.SuccessFiles++
-			stats.TotalOriginalSize += result.OriginalSize
-			stats.TotalCompressedSize += result.CompressedSize
+// Add は処理結果を1件ずつ統計情報に加えます。StreamDirectory などで結果を保持せずに集計する場合に使用します。
+func (s *Statistics) Add(result Result) {
+	s.TotalFiles++
+	if result.Error != nil {
+		s.FailedFiles++
+	} else {
+		s.SuccessFiles++
+		if result.Cached {
+			s.CachedFiles++
+		}
+		if result.Resumed {
+			s.ResumedFiles++
 		}
+		s.TotalOriginalSize += result.OriginalSize
+		s.TotalCompressedSize += result.CompressedSize
 	}
 
-	if stats.TotalOriginalSize > 0 {
-		stats.CompressionRatio = 100.0 - (float64(stats.TotalCompressedSize) / float64(stats.TotalOriginalSize) * 100.0)
+	s.CompressionRatio = 0
+	if s.TotalOriginalSize > 0 {
+		s.CompressionRatio = 100.0 - (float64(s.TotalCompressedSize) / float64(s.TotalOriginalSize) * 100.0)
 	}
-
-	return stats
 }
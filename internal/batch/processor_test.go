@@ -2,15 +2,26 @@ package batch
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"image"
 	"image/color"
 	"image/jpeg"
 	"image/png"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/gen2brain/webp"
+	"github.com/takumines/shuku/internal/backup"
+	"github.com/takumines/shuku/internal/storage"
 	"github.com/takumines/shuku/pkg/shuku"
 )
 
@@ -29,8 +40,8 @@ func createTestImage(width, height int) image.Image {
 	return img
 }
 
-// JPEG画像ファイルを作成
-func createTestJPEGFile(t *testing.T, dir, filename string, width, height int) string {
+// JPEG画像データを生成
+func encodeTestJPEG(t *testing.T, width, height int) []byte {
 	t.Helper()
 	img := createTestImage(width, height)
 	var buf bytes.Buffer
@@ -38,9 +49,26 @@ func createTestJPEGFile(t *testing.T, dir, filename string, width, height int) s
 	if err != nil {
 		t.Fatalf("JPEG画像データの生成に失敗しました: %v", err)
 	}
+	return buf.Bytes()
+}
+
+// PNG画像データを生成
+func encodeTestPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := createTestImage(width, height)
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		t.Fatalf("PNG画像データの生成に失敗しました: %v", err)
+	}
+	return buf.Bytes()
+}
 
+// JPEG画像ファイルを作成
+func createTestJPEGFile(t *testing.T, dir, filename string, width, height int) string {
+	t.Helper()
 	filePath := filepath.Join(dir, filename)
-	err = os.WriteFile(filePath, buf.Bytes(), 0644)
+	err := os.WriteFile(filePath, encodeTestJPEG(t, width, height), 0644)
 	if err != nil {
 		t.Fatalf("テストファイルの作成に失敗しました: %v", err)
 	}
@@ -51,15 +79,8 @@ func createTestJPEGFile(t *testing.T, dir, filename string, width, height int) s
 // PNG画像ファイルを作成
 func createTestPNGFile(t *testing.T, dir, filename string, width, height int) string {
 	t.Helper()
-	img := createTestImage(width, height)
-	var buf bytes.Buffer
-	err := png.Encode(&buf, img)
-	if err != nil {
-		t.Fatalf("PNG画像データの生成に失敗しました: %v", err)
-	}
-
 	filePath := filepath.Join(dir, filename)
-	err = os.WriteFile(filePath, buf.Bytes(), 0644)
+	err := os.WriteFile(filePath, encodeTestPNG(t, width, height), 0644)
 	if err != nil {
 		t.Fatalf("テストファイルの作成に失敗しました: %v", err)
 	}
@@ -208,11 +229,12 @@ func TestProcessor_shouldIncludeFile(t *testing.T) {
 	processor := NewProcessor(4, "/tmp")
 
 	tests := []struct {
-		name     string
-		filePath string
-		include  []string
-		exclude  []string
-		expected bool
+		name       string
+		filePath   string
+		include    []string
+		exclude    []string
+		ignoreCase bool
+		expected   bool
 	}{
 		{
 			name:     "JPEG画像ファイル（包含）",
@@ -256,12 +278,45 @@ func TestProcessor_shouldIncludeFile(t *testing.T) {
 			exclude:  []string{"*_thumb*", "*_backup*"},
 			expected: true,
 		},
+		{
+			name:     "パスのパターン（包含）",
+			filePath: "static/img/logo.png",
+			include:  []string{"static/img/*.png"},
+			expected: true,
+		},
+		{
+			name:     "パスのパターン（別のディレクトリ）",
+			filePath: "static/css/logo.png",
+			include:  []string{"static/img/*.png"},
+			expected: false,
+		},
+		{
+			name:     "** を含む除外パターン",
+			filePath: "vendor/lib/icon.png",
+			include:  []string{"*.png"},
+			exclude:  []string{"vendor/**"},
+			expected: false,
+		},
+		{
+			name:     "大文字の拡張子（区別する）",
+			filePath: "DCIM/DSC0001.JPG",
+			include:  []string{"*.jpg"},
+			expected: false,
+		},
+		{
+			name:       "大文字の拡張子（区別しない）",
+			filePath:   "DCIM/DSC0001.JPG",
+			include:    []string{"*.jpg"},
+			ignoreCase: true,
+			expected:   true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			processor.SetIncludePatterns(tt.include)
 			processor.SetExcludePatterns(tt.exclude)
+			processor.SetIgnoreCase(tt.ignoreCase)
 
 			result := processor.shouldIncludeFile(tt.filePath)
 			if result != tt.expected {
@@ -314,6 +369,89 @@ func TestProcessor_generateOutputPath(t *testing.T) {
 	}
 }
 
+func TestProcessor_SkipGeneratedOutputs(t *testing.T) {
+	options := shuku.Options{Quality: 70, PaletteSize: 256}
+
+	// listFiles はディレクトリ以下のファイルを相対パスで返します。
+	listFiles := func(t *testing.T, dir string) []string {
+		t.Helper()
+		var files []string
+		_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				rel, _ := filepath.Rel(dir, path)
+				files = append(files, filepath.ToSlash(rel))
+			}
+			return err
+		})
+		return files
+	}
+
+	tests := []struct {
+		name      string
+		suffix    string
+		outputDir string // 入力ディレクトリからの相対パス
+		wantFiles []string
+	}{
+		{
+			name:      "既定のサフィックス",
+			suffix:    DefaultOutputSuffix,
+			wantFiles: []string{"photo.jpg", "photo_compressed.jpg", "sub/icon.png", "sub/icon_compressed.png"},
+		},
+		{
+			name:      "サフィックスの指定",
+			suffix:    ".min",
+			wantFiles: []string{"photo.jpg", "photo.min.jpg", "sub/icon.min.png", "sub/icon.png"},
+		},
+		{
+			name:      "入力ディレクトリ内の出力ディレクトリ",
+			suffix:    DefaultOutputSuffix,
+			outputDir: "out",
+			wantFiles: []string{"out/photo.jpg", "out/sub/icon.png", "photo.jpg", "sub/icon.png"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			createTestJPEGFile(t, dir, "photo.jpg", 60, 60)
+			if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+				t.Fatalf("サブディレクトリの作成に失敗しました: %v", err)
+			}
+			createTestPNGFile(t, filepath.Join(dir, "sub"), "icon.png", 40, 40)
+
+			outputDir := ""
+			if tt.outputDir != "" {
+				outputDir = filepath.Join(dir, tt.outputDir)
+			}
+			processor := NewProcessor(2, outputDir)
+			processor.SetRecursive(true)
+			processor.SetOutputSuffix(tt.suffix)
+
+			// 2回目以降の実行でも以前の出力は処理対象にならない
+			for run := 1; run <= 3; run++ {
+				results, err := processor.ProcessDirectory(dir, options)
+				if err != nil {
+					t.Fatalf("%d回目の ProcessDirectory() error = %v", run, err)
+				}
+				if len(results) != 2 {
+					t.Errorf("%d回目の処理ファイル数 = %d, want 2", run, len(results))
+				}
+			}
+			if got, want := strings.Join(listFiles(t, dir), ","), strings.Join(tt.wantFiles, ","); got != want {
+				t.Errorf("ファイル = %v, want %v", got, want)
+			}
+		})
+	}
+
+	t.Run("空のサフィックス", func(t *testing.T) {
+		processor := NewProcessor(1, "")
+		processor.SetOutputSuffix("")
+		if _, err := processor.ProcessDirectory(t.TempDir(), options); err == nil {
+			t.Error("出力ディレクトリなしで空のサフィックスに対してエラーが発生しませんでした")
+		}
+	})
+}
+
 func TestProcessor_ProcessDirectory(t *testing.T) {
 	// テストディレクトリを作成
 	tmpDir, err := os.MkdirTemp("", "batch_test_")
@@ -362,8 +500,17 @@ func TestProcessor_ProcessDirectory(t *testing.T) {
 			}
 
 			// 出力ファイルが存在することを確認
-			if _, err := os.Stat(result.Job.OutputPath); os.IsNotExist(err) {
+			outputInfo, err := os.Stat(result.Job.OutputPath)
+			if os.IsNotExist(err) {
 				t.Errorf("出力ファイルが作成されませんでした: %s", result.Job.OutputPath)
+				continue
+			}
+
+			// 圧縮結果の詳細がReportから設定されていることを確認
+			if result.Report == nil {
+				t.Errorf("Result.Report が設定されていません: %s", result.Job.InputPath)
+			} else if result.CompressedSize != outputInfo.Size() || result.OriginalSize != result.Report.InputSize {
+				t.Errorf("Result のサイズが Report と一致しません: %+v", result)
 			}
 		}
 	})
@@ -517,3 +664,635 @@ func abs(x float64) float64 {
 	}
 	return x
 }
+
+func TestDefaultIncludePatterns(t *testing.T) {
+	globs := DefaultIncludePatterns()
+
+	// 登録済みの全形式がパターンに含まれることを確認
+	for _, format := range shuku.Formats() {
+		found := false
+		for _, glob := range globs {
+			if glob == "*."+format {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("DefaultIncludePatterns() = %v, *.%s が含まれていません", globs, format)
+		}
+	}
+}
+
+func TestProcessor_ProcessDirectoryContext(t *testing.T) {
+	tmpDir := t.TempDir()
+	createTestJPEGFile(t, tmpDir, "image1.jpg", 50, 50)
+	createTestPNGFile(t, tmpDir, "image2.png", 50, 50)
+
+	t.Run("キャンセル済みのコンテキスト", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		processor := NewProcessor(2, filepath.Join(tmpDir, "output"))
+		_, err := processor.ProcessDirectoryContext(ctx, tmpDir, shuku.Options{Quality: 70, PaletteSize: 256})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ProcessDirectoryContext() error = %v, want %v", err, context.Canceled)
+		}
+	})
+
+	t.Run("処理中のキャンセルは全ジョブに伝播する", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		processor := NewProcessor(1, "")
+		tgt := target{src: os.DirFS(tmpDir), dst: storage.NewMemoryStorage()}
+		var jobs []Job
+		err := processor.collectJobs(ctx, tgt, shuku.Options{Quality: 70}, func(job Job) error {
+			jobs = append(jobs, job)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("collectJobs() error = %v", err)
+		}
+		cancel()
+
+		jobChan := make(chan Job, len(jobs))
+		resultChan := make(chan Result, len(jobs))
+		for _, job := range jobs {
+			jobChan <- job
+		}
+		close(jobChan)
+		processor.executeJobs(ctx, tgt, jobChan, resultChan)
+		close(resultChan)
+
+		var results []Result
+		for result := range resultChan {
+			results = append(results, result)
+		}
+		if len(results) != len(jobs) {
+			t.Fatalf("executeJobs() results count = %v, want %v", len(results), len(jobs))
+		}
+		for _, result := range results {
+			if !errors.Is(result.Error, context.Canceled) {
+				t.Errorf("result.Error = %v, want %v", result.Error, context.Canceled)
+			}
+		}
+	})
+}
+
+func TestProcessor_processJobFormatMismatch(t *testing.T) {
+	// PNGデータを .jpg 拡張子で保存
+	fsys := fstest.MapFS{
+		"mislabeled.jpg": {Data: encodeTestPNG(t, 50, 50), Mode: 0644},
+	}
+
+	tests := []struct {
+		name         string
+		fixExtension bool
+		expectedName string
+	}{
+		{"拡張子を修正しない", false, "mislabeled.jpg"},
+		{"拡張子を修正する", true, "mislabeled.png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewProcessor(1, "")
+			processor.SetFixExtension(tt.fixExtension)
+			dst := storage.NewMemoryStorage()
+
+			result := processor.processJob(context.Background(), target{src: fsys, dst: dst}, Job{
+				InputPath:  "mislabeled.jpg",
+				OutputPath: "mislabeled.jpg",
+				Options:    shuku.Options{PaletteSize: 256},
+				inputName:  "mislabeled.jpg",
+				outputName: "mislabeled.jpg",
+			})
+
+			if result.Error != nil {
+				t.Fatalf("processJob() error = %v", result.Error)
+			}
+			if result.DetectedFormat != "png" {
+				t.Errorf("DetectedFormat = %v, want png", result.DetectedFormat)
+			}
+			if result.Warning == "" {
+				t.Error("拡張子の不一致に対して警告が設定されていません")
+			}
+			if result.Job.OutputPath != tt.expectedName {
+				t.Errorf("OutputPath = %v, want %v", result.Job.OutputPath, tt.expectedName)
+			}
+			if _, err := dst.ReadFile(tt.expectedName); err != nil {
+				t.Errorf("出力ファイルが作成されませんでした: %v", err)
+			}
+		})
+	}
+}
+
+func TestProcessor_ProcessFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"image1.jpg":        {Data: encodeTestJPEG(t, 50, 50), Mode: 0644},
+		"image2.png":        {Data: encodeTestPNG(t, 50, 50), Mode: 0644},
+		"readme.txt":        {Data: []byte("これはテキストファイルです"), Mode: 0644},
+		"subdir/image3.jpg": {Data: encodeTestJPEG(t, 30, 30), Mode: 0644},
+	}
+
+	tests := []struct {
+		name          string
+		recursive     bool
+		expectedNames []string
+	}{
+		{"非再帰", false, []string{"image1.jpg", "image2.png"}},
+		{"再帰", true, []string{"image1.jpg", "image2.png", "subdir/image3.jpg"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewProcessor(2, "")
+			processor.SetRecursive(tt.recursive)
+			dst := storage.NewMemoryStorage()
+
+			results, err := processor.ProcessFS(fsys, dst, shuku.Options{Quality: 70, PaletteSize: 256})
+			if err != nil {
+				t.Fatalf("ProcessFS() error = %v", err)
+			}
+			if len(results) != len(tt.expectedNames) {
+				t.Fatalf("ProcessFS() results count = %v, want %v", len(results), len(tt.expectedNames))
+			}
+			for _, result := range results {
+				if result.Error != nil {
+					t.Errorf("%s: error = %v", result.Job.InputPath, result.Error)
+					continue
+				}
+				if result.Report.OutputPath != result.Job.InputPath {
+					t.Errorf("Report.OutputPath = %v, want %v", result.Report.OutputPath, result.Job.InputPath)
+				}
+				data, err := dst.ReadFile(result.Job.InputPath)
+				if err != nil {
+					t.Errorf("出力が保存されていません: %v", err)
+					continue
+				}
+				if int64(len(data)) != result.CompressedSize {
+					t.Errorf("出力サイズ = %d, want %d", len(data), result.CompressedSize)
+				}
+			}
+			if names := dst.Names(); !reflect.DeepEqual(names, tt.expectedNames) {
+				t.Errorf("出力ファイル = %v, want %v", names, tt.expectedNames)
+			}
+		})
+	}
+
+	t.Run("入力元の場所を表示用のパスに使用", func(t *testing.T) {
+		processor := NewProcessor(1, "")
+		processor.SetExcludePatterns([]string{"image2.*"})
+		results, err := processor.ProcessFS(locatedFS{fsys}, storage.NewMemoryStorage(), shuku.Options{Quality: 70})
+		if err != nil {
+			t.Fatalf("ProcessFS() error = %v", err)
+		}
+		if len(results) != 1 || results[0].Job.InputPath != "mem://image1.jpg" {
+			t.Errorf("results = %+v, want mem://image1.jpg のみ", results)
+		}
+	})
+
+	t.Run("読み込みエラー", func(t *testing.T) {
+		processor := NewProcessor(1, "")
+		results, err := processor.ProcessFS(fstest.MapFS{
+			"broken.jpg": {Data: []byte{0xFF, 0xD8, 0xFF, 0x00}, Mode: 0644},
+		}, storage.NewMemoryStorage(), shuku.Options{})
+		if err != nil {
+			t.Fatalf("ProcessFS() error = %v", err)
+		}
+		if len(results) != 1 || !errors.Is(results[0].Error, shuku.ErrInvalidImage) {
+			t.Errorf("results = %+v, want ErrInvalidImage", results)
+		}
+	})
+
+	t.Run("上書きモード", func(t *testing.T) {
+		processor := NewProcessor(1, "")
+		processor.SetInPlace(true, backup.Config{})
+		if _, err := processor.ProcessFS(fsys, storage.NewMemoryStorage(), shuku.Options{}); err == nil {
+			t.Error("上書きモードに対してエラーが発生しませんでした")
+		}
+	})
+}
+
+// locatedFS は表示用の場所を返す入力元です。
+type locatedFS struct {
+	fs.FS
+}
+
+func (l locatedFS) Location(name string) string {
+	return "mem://" + name
+}
+
+// gatedFS は gatedDir の一覧の取得を gate が閉じられるまで待機する入力元です。
+// 探索の完了前に圧縮と結果の受け渡しが始まることの確認に使用します。
+type gatedFS struct {
+	fstest.MapFS
+	gatedDir string
+	gate     chan struct{}
+}
+
+func (g gatedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == g.gatedDir {
+		select {
+		case <-g.gate:
+		case <-time.After(5 * time.Second):
+			return nil, errors.New("最初の結果を受け取る前に探索の完了を待っています")
+		}
+	}
+	return g.MapFS.ReadDir(name)
+}
+
+func TestProcessor_StreamFS(t *testing.T) {
+	jpegData := encodeTestJPEG(t, 40, 40)
+
+	t.Run("探索の完了を待たずに結果を受け渡す", func(t *testing.T) {
+		fsys := gatedFS{
+			MapFS: fstest.MapFS{
+				"first.jpg":        {Data: jpegData, Mode: 0644},
+				"later/second.jpg": {Data: jpegData, Mode: 0644},
+			},
+			gatedDir: "later",
+			gate:     make(chan struct{}),
+		}
+		processor := NewProcessor(2, "")
+		processor.SetRecursive(true)
+
+		var names []string
+		err := processor.StreamFS(fsys, storage.NewMemoryStorage(), shuku.Options{Quality: 70}, func(result Result) error {
+			if result.Error != nil {
+				t.Errorf("%s: error = %v", result.Job.InputPath, result.Error)
+			}
+			if len(names) == 0 {
+				close(fsys.gate)
+			}
+			names = append(names, result.Job.InputPath)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("StreamFS() error = %v", err)
+		}
+		if got := strings.Join(names, ","); got != "first.jpg,later/second.jpg" {
+			t.Errorf("結果 = %v, want first.jpg,later/second.jpg", got)
+		}
+	})
+
+	t.Run("fn のエラーで処理を中断する", func(t *testing.T) {
+		const fileCount = 50
+		fsys := fstest.MapFS{}
+		for i := 0; i < fileCount; i++ {
+			fsys[fmt.Sprintf("image%02d.jpg", i)] = &fstest.MapFile{Data: jpegData, Mode: 0644}
+		}
+		dst := storage.NewMemoryStorage()
+		stopErr := errors.New("中断")
+
+		calls := 0
+		err := NewProcessor(2, "").StreamFS(fsys, dst, shuku.Options{Quality: 70}, func(result Result) error {
+			calls++
+			return stopErr
+		})
+		if !errors.Is(err, stopErr) {
+			t.Fatalf("StreamFS() error = %v, want %v", err, stopErr)
+		}
+		if calls != 1 {
+			t.Errorf("fn の呼び出し回数 = %d, want 1", calls)
+		}
+		// 中断後は新しいファイルを探索・圧縮しない
+		if written := len(dst.Names()); written >= fileCount {
+			t.Errorf("出力ファイル数 = %d, 中断後も処理が続いています", written)
+		}
+	})
+}
+
+// blockingFS は blocked を開く処理を gate が閉じられるまで待機する入力元です。
+// 完了順と探索順が異なる場合の確認に使用します。
+type blockingFS struct {
+	fstest.MapFS
+	blocked string
+	gate    chan struct{}
+}
+
+func (b blockingFS) wait(name string) error {
+	if name != b.blocked {
+		return nil
+	}
+	select {
+	case <-b.gate:
+		return nil
+	case <-time.After(5 * time.Second):
+		return errors.New("他のファイルの完了を待っています")
+	}
+}
+
+func (b blockingFS) Open(name string) (fs.File, error) {
+	if err := b.wait(name); err != nil {
+		return nil, err
+	}
+	return b.MapFS.Open(name)
+}
+
+func (b blockingFS) ReadFile(name string) ([]byte, error) {
+	if err := b.wait(name); err != nil {
+		return nil, err
+	}
+	return b.MapFS.ReadFile(name)
+}
+
+func TestProcessor_ProcessFSResultOrder(t *testing.T) {
+	jpegData := encodeTestJPEG(t, 40, 40)
+	fsys := blockingFS{
+		MapFS: fstest.MapFS{
+			"a.jpg":     {Data: jpegData, Mode: 0644},
+			"b.jpg":     {Data: jpegData, Mode: 0644},
+			"sub/c.jpg": {Data: jpegData, Mode: 0644},
+		},
+		blocked: "a.jpg",
+		gate:    make(chan struct{}),
+	}
+
+	// a.jpg は b.jpg の完了後に読み込まれるため、完了順は探索順と異なる
+	var finished []string
+	processor := NewProcessor(3, "")
+	processor.SetRecursive(true)
+	processor.SetProgress(func(e Event) {
+		if e.Kind != EventFileFinished {
+			return
+		}
+		finished = append(finished, e.Job.InputPath)
+		if e.Job.InputPath == "b.jpg" {
+			close(fsys.gate)
+		}
+	})
+
+	results, err := processor.ProcessFS(fsys, storage.NewMemoryStorage(), shuku.Options{Quality: 70})
+	if err != nil {
+		t.Fatalf("ProcessFS() error = %v", err)
+	}
+	if finished[0] == "a.jpg" {
+		t.Fatalf("完了順 = %v, a.jpg が最初に完了しています", finished)
+	}
+
+	var names []string
+	for _, result := range results {
+		if result.Error != nil {
+			t.Errorf("%s: error = %v", result.Job.InputPath, result.Error)
+		}
+		names = append(names, result.Job.InputPath)
+	}
+	if got := strings.Join(names, ","); got != "a.jpg,b.jpg,sub/c.jpg" {
+		t.Errorf("結果の順序 = %v, want a.jpg,b.jpg,sub/c.jpg", got)
+	}
+}
+
+// statCountingFS はファイルに対する Stat の呼び出し回数を数える fs.FS です（探索の起点の "." は数えない）。
+type statCountingFS struct {
+	fstest.MapFS
+	stats *atomic.Int32
+}
+
+func (s statCountingFS) Stat(name string) (fs.FileInfo, error) {
+	if name != "." {
+		s.stats.Add(1)
+	}
+	return s.MapFS.Stat(name)
+}
+
+func TestProcessor_ProcessFSListedInfo(t *testing.T) {
+	fsys := statCountingFS{
+		MapFS: fstest.MapFS{
+			"a.jpg":     {Data: encodeTestJPEG(t, 40, 40), Mode: 0600},
+			"sub/b.jpg": {Data: encodeTestJPEG(t, 40, 40), Mode: 0640},
+		},
+		stats: &atomic.Int32{},
+	}
+	outDir := t.TempDir()
+
+	processor := NewProcessor(2, "")
+	processor.SetRecursive(true)
+	results, err := processor.ProcessFS(fsys, storage.NewDirStorage(outDir), shuku.Options{Quality: 70})
+	if err != nil {
+		t.Fatalf("ProcessFS() error = %v", err)
+	}
+	if stats := CalculateStatistics(results); stats.SuccessFiles != 2 {
+		t.Errorf("統計 = %+v", stats)
+	}
+
+	// 入力ファイルの情報は探索で取得済みのため、ファイルごとに問い合わせない
+	if n := fsys.stats.Load(); n != 0 {
+		t.Errorf("Stat の呼び出し回数 = %d, want 0", n)
+	}
+	for name, file := range fsys.MapFS {
+		info, err := os.Stat(filepath.Join(outDir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("出力が作成されていません: %v", err)
+		}
+		if info.Mode().Perm() != file.Mode.Perm() {
+			t.Errorf("%s のパーミッション = %v, want %v", name, info.Mode().Perm(), file.Mode.Perm())
+		}
+	}
+}
+
+func TestProcessor_IgnoreFile(t *testing.T) {
+	jpegData := encodeTestJPEG(t, 20, 20)
+	fsys := fstest.MapFS{
+		".shukuignore":          {Data: []byte("# 生成物\nbuild/\n*_draft.jpg\n"), Mode: 0644},
+		"a.jpg":                 {Data: jpegData, Mode: 0644},
+		"a_draft.jpg":           {Data: jpegData, Mode: 0644},
+		"build/out.jpg":         {Data: jpegData, Mode: 0644},
+		"photos/.shukuignore":   {Data: []byte("raw/\n!keep_draft.jpg\n"), Mode: 0644},
+		"photos/b.jpg":          {Data: jpegData, Mode: 0644},
+		"photos/keep_draft.jpg": {Data: jpegData, Mode: 0644},
+		"photos/raw/c.jpg":      {Data: jpegData, Mode: 0644},
+		"icons/raw/d.jpg":       {Data: jpegData, Mode: 0644},
+		"icons/other_draft.jpg": {Data: jpegData, Mode: 0644},
+	}
+
+	tests := []struct {
+		name       string
+		ignoreFile string
+		expected   string
+	}{
+		{
+			name:       "除外ファイルを適用する",
+			ignoreFile: DefaultIgnoreFileName,
+			expected:   "a.jpg,icons/raw/d.jpg,photos/b.jpg,photos/keep_draft.jpg",
+		},
+		{
+			name:       "除外ファイルを読み込まない",
+			ignoreFile: "",
+			expected:   "a.jpg,a_draft.jpg,build/out.jpg,icons/other_draft.jpg,icons/raw/d.jpg,photos/b.jpg,photos/keep_draft.jpg,photos/raw/c.jpg",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewProcessor(2, "")
+			processor.SetRecursive(true)
+			processor.SetIgnoreFile(tt.ignoreFile)
+
+			results, err := processor.ProcessFS(fsys, storage.NewMemoryStorage(), shuku.Options{Quality: 70})
+			if err != nil {
+				t.Fatalf("ProcessFS() error = %v", err)
+			}
+			var names []string
+			for _, result := range results {
+				names = append(names, result.Job.InputPath)
+			}
+			if got := strings.Join(names, ","); got != tt.expected {
+				t.Errorf("処理したファイル = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	t.Run("不正な除外ファイル", func(t *testing.T) {
+		invalid := fstest.MapFS{
+			".shukuignore": {Data: []byte("[\n"), Mode: 0644},
+			"a.jpg":        {Data: jpegData, Mode: 0644},
+		}
+		_, err := NewProcessor(1, "").ProcessFS(invalid, storage.NewMemoryStorage(), shuku.Options{Quality: 70})
+		if err == nil || !strings.Contains(err.Error(), ".shukuignore") {
+			t.Errorf("ProcessFS() error = %v, want 除外ファイルのエラー", err)
+		}
+	})
+}
+
+func TestStatistics_Add(t *testing.T) {
+	results := []Result{
+		{OriginalSize: 1000, CompressedSize: 600},
+		{OriginalSize: 500, CompressedSize: 400, Cached: true},
+		{Error: errors.New("エラー")},
+	}
+
+	var stats Statistics
+	for _, result := range results {
+		stats.Add(result)
+	}
+	if want := CalculateStatistics(results); stats != want {
+		t.Errorf("Add() の集計 = %+v, want %+v", stats, want)
+	}
+	if stats.TotalFiles != 3 || stats.CachedFiles != 1 || stats.FailedFiles != 1 {
+		t.Errorf("Add() の集計 = %+v", stats)
+	}
+}
+
+func TestProcessor_InPlace(t *testing.T) {
+	tmpDir := t.TempDir()
+	inputPath := createTestJPEGFile(t, tmpDir, "photo.jpg", 80, 80)
+	original, _ := os.ReadFile(inputPath)
+	backupDir := filepath.Join(tmpDir, "backup")
+
+	processor := NewProcessor(1, "")
+	processor.SetRecursive(true)
+	processor.SetInPlace(true, backup.Config{Dir: backupDir})
+
+	results, err := processor.ProcessDirectory(tmpDir, shuku.Options{Quality: 30})
+	if err != nil {
+		t.Fatalf("ProcessDirectory() error = %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("ProcessDirectory() results count = %v, want 1", len(results))
+	}
+	if results[0].Error != nil {
+		t.Fatalf("ProcessDirectory() result error: %v", results[0].Error)
+	}
+	if results[0].Job.OutputPath != inputPath {
+		t.Errorf("OutputPath = %v, want %v", results[0].Job.OutputPath, inputPath)
+	}
+
+	backupData, err := os.ReadFile(filepath.Join(backupDir, "photo.jpg"))
+	if err != nil {
+		t.Fatalf("バックアップが作成されませんでした: %v", err)
+	}
+	if !bytes.Equal(backupData, original) {
+		t.Error("バックアップの内容が元ファイルと異なります")
+	}
+
+	// 2回目の実行ではバックアップディレクトリを処理対象にしない
+	results, err = processor.ProcessDirectory(tmpDir, shuku.Options{Quality: 30})
+	if err != nil {
+		t.Fatalf("ProcessDirectory() error = %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("バックアップディレクトリが処理対象に含まれました: %d 件", len(results))
+	}
+
+	t.Run("拡張子の修正", func(t *testing.T) {
+		dir := t.TempDir()
+		createTestJPEGFile(t, dir, "mislabeled.png", 40, 40)
+		conflictData := []byte("既存のファイル")
+		createTestJPEGFile(t, dir, "taken.png", 40, 40)
+		if err := os.WriteFile(filepath.Join(dir, "taken.jpeg"), conflictData, 0644); err != nil {
+			t.Fatal(err)
+		}
+		backupConfig := backup.Config{Suffix: ".orig"}
+
+		processor := NewProcessor(1, "")
+		processor.SetInPlace(true, backupConfig)
+		processor.SetFixExtension(true)
+		processor.SetIncludePatterns([]string{"*.png"})
+		results, err := processor.ProcessDirectory(dir, shuku.Options{Quality: 30})
+		if err != nil {
+			t.Fatalf("ProcessDirectory() error = %v", err)
+		}
+		if len(results) != 2 || results[0].Error != nil || results[1].Error == nil {
+			t.Fatalf("results = %+v, want mislabeled.png to succeed and taken.png to fail", results)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "mislabeled.jpeg")); err != nil {
+			t.Errorf("拡張子を修正したファイルが作成されていません: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "mislabeled.png")); !os.IsNotExist(err) {
+			t.Error("拡張子が誤った元のファイルが残っています")
+		}
+		if data, _ := os.ReadFile(filepath.Join(dir, "taken.jpeg")); !bytes.Equal(data, conflictData) {
+			t.Error("拡張子を修正した出力先の既存ファイルが上書きされました")
+		}
+
+		restored, err := backupConfig.Restore(dir)
+		if err != nil {
+			t.Fatalf("Restore() error = %v", err)
+		}
+		// 出力先が既存のファイルと衝突した taken.png は変更されないため、バックアップもない
+		if len(restored) != 1 {
+			t.Errorf("Restore() = %+v, want 1 file", restored)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "mislabeled.jpeg")); !os.IsNotExist(err) {
+			t.Error("復元後に拡張子を修正したファイルが残っています")
+		}
+		if _, err := os.Stat(filepath.Join(dir, "mislabeled.png")); err != nil {
+			t.Errorf("元のファイルが復元されていません: %v", err)
+		}
+	})
+
+	t.Run("出力ディレクトリとの併用", func(t *testing.T) {
+		processor := NewProcessor(1, filepath.Join(tmpDir, "out"))
+		processor.SetInPlace(true, backup.Config{})
+		if _, err := processor.ProcessDirectory(tmpDir, shuku.Options{}); err == nil {
+			t.Error("上書きモードと出力ディレクトリの併用に対してエラーが発生しませんでした")
+		}
+	})
+}
+
+func TestProcessDirectoryErrorKinds(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "broken.jpg"), []byte{0xFF, 0xD8, 0xFF, 0x00}, 0644); err != nil {
+		t.Fatalf("テストファイルの作成に失敗: %v", err)
+	}
+
+	processor := NewProcessor(1, outputDir)
+	results, err := processor.ProcessDirectory(inputDir, shuku.Options{Quality: 80})
+	if err != nil {
+		t.Fatalf("ProcessDirectory() error = %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("結果数 = %d, want 1", len(results))
+	}
+
+	resultErr := results[0].Error
+	if !errors.Is(resultErr, shuku.ErrInvalidImage) {
+		t.Errorf("errors.Is(err, shuku.ErrInvalidImage) = false, err = %v", resultErr)
+	}
+	var compressErr *shuku.CompressError
+	if !errors.As(resultErr, &compressErr) || compressErr.Stage != shuku.StageDecode {
+		t.Errorf("CompressError の処理段階を取得できません: %v", resultErr)
+	}
+}
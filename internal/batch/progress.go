@@ -0,0 +1,133 @@
+package batch
+
+import (
+	"sync"
+	"time"
+)
+
+// EventKind は進捗イベントの種類です。
+type EventKind int
+
+const (
+	// EventFileStarted はファイルの処理を開始したことを表します。
+	EventFileStarted EventKind = iota + 1
+	// EventFileFinished はファイルの処理が完了したことを表します（失敗・キャッシュ済みを含む）。
+	EventFileFinished
+	// EventScanCompleted は処理対象ファイルの探索が終了し、総数が確定したことを表します。
+	// 探索を中断した場合も、実際に処理するファイル数を総数として送信します。
+	EventScanCompleted
+)
+
+// Event はバッチ処理の進捗イベントです。
+type Event struct {
+	Kind     EventKind
+	Job      Job      // 対象のジョブ（EventScanCompleted では空）
+	Result   *Result  // 処理結果（EventFileFinished のみ）
+	Progress Progress // イベント発生時点の進捗
+}
+
+// Progress はバッチ処理全体の進捗です。
+type Progress struct {
+	Found           int           // 探索で見つかった処理対象ファイル数（探索の終了後は処理するファイルの総数）
+	ScanCompleted   bool          // 探索が終了し、Found が確定したかどうか
+	Started         int           // 処理を開始したファイル数
+	Finished        int           // 処理が完了したファイル数（失敗・キャッシュ済みを含む）
+	Failed          int           // 処理に失敗したファイル数
+	OriginalBytes   int64         // 成功したファイルの元のサイズの合計
+	CompressedBytes int64         // 成功したファイルの圧縮後のサイズの合計
+	Elapsed         time.Duration // 処理の開始からの経過時間
+}
+
+// SavedBytes は圧縮によって削減したサイズの合計を返します。
+func (p Progress) SavedBytes() int64 {
+	return p.OriginalBytes - p.CompressedBytes
+}
+
+// ETA はこれまでの処理速度から残りの処理時間を推定します。
+// 探索が完了しておらず総数が不明な場合や、完了したファイルがない場合は false を返します。
+func (p Progress) ETA() (time.Duration, bool) {
+	if !p.ScanCompleted || p.Finished == 0 {
+		return 0, false
+	}
+	remaining := p.Found - p.Finished
+	return p.Elapsed / time.Duration(p.Finished) * time.Duration(remaining), true
+}
+
+// SetProgress は進捗イベントを受け取る関数を設定します。nil の場合はイベントを送信しません。
+// fn の呼び出しは直列化されますが、ワーカーのゴルーチンから呼び出されるため、時間のかかる処理は避けてください。
+func (p *Processor) SetProgress(fn func(Event)) {
+	p.Progress = fn
+}
+
+// progressTracker は実行中の進捗を集計し、イベントを送信します。並行に使用しても安全です。
+// nil の場合は何もしません。
+type progressTracker struct {
+	mu       sync.Mutex
+	fn       func(Event)
+	start    time.Time
+	progress Progress
+}
+
+// newProgressTracker は進捗イベントの送信先が設定されている場合のみ progressTracker を作成します。
+func (p *Processor) newProgressTracker() *progressTracker {
+	if p.Progress == nil {
+		return nil
+	}
+	return &progressTracker{fn: p.Progress, start: time.Now()}
+}
+
+// found は処理対象ファイルが見つかったことを記録します。イベントは送信しません。
+func (t *progressTracker) found() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Found++
+}
+
+// scanCompleted は探索の終了を記録し、total を総数として確定します。
+// 中断により送信できなかったファイルは found で数えた数から除かれます。
+func (t *progressTracker) scanCompleted(total int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Found = total
+	t.progress.ScanCompleted = true
+	t.send(Event{Kind: EventScanCompleted})
+}
+
+func (t *progressTracker) started(job Job) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Started++
+	t.send(Event{Kind: EventFileStarted, Job: job})
+}
+
+func (t *progressTracker) finished(result Result) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Finished++
+	if result.Error != nil {
+		t.progress.Failed++
+	} else {
+		t.progress.OriginalBytes += result.OriginalSize
+		t.progress.CompressedBytes += result.CompressedSize
+	}
+	t.send(Event{Kind: EventFileFinished, Job: result.Job, Result: &result})
+}
+
+// send は現在の進捗を設定してイベントを送信します。t.mu を保持した状態で呼び出します。
+func (t *progressTracker) send(event Event) {
+	t.progress.Elapsed = time.Since(t.start)
+	event.Progress = t.progress
+	t.fn(event)
+}
@@ -0,0 +1,147 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/takumines/shuku/internal/storage"
+	"github.com/takumines/shuku/pkg/shuku"
+)
+
+func TestProcessor_SetProgress(t *testing.T) {
+	fsys := fstest.MapFS{
+		"image1.jpg": {Data: encodeTestJPEG(t, 50, 50), Mode: 0644},
+		"image2.png": {Data: encodeTestPNG(t, 50, 50), Mode: 0644},
+		"broken.jpg": {Data: []byte{0xFF, 0xD8, 0xFF, 0x00}, Mode: 0644},
+	}
+
+	var events []Event
+	processor := NewProcessor(2, "")
+	processor.SetProgress(func(event Event) {
+		events = append(events, event)
+	})
+	results, err := processor.ProcessFS(fsys, storage.NewMemoryStorage(), shuku.Options{Quality: 70, PaletteSize: 256})
+	if err != nil {
+		t.Fatalf("ProcessFS() error = %v", err)
+	}
+
+	counts := map[EventKind]int{}
+	for i, event := range events {
+		counts[event.Kind]++
+		if i > 0 && event.Progress.Finished < events[i-1].Progress.Finished {
+			t.Errorf("イベント[%d] の完了数が減少しました: %+v", i, event.Progress)
+		}
+		if event.Progress.Started > event.Progress.Found {
+			t.Errorf("イベント[%d] の開始数が見つかった数を超えています: %+v", i, event.Progress)
+		}
+		if event.Kind == EventFileFinished && (event.Result == nil || event.Result.Job.InputPath != event.Job.InputPath) {
+			t.Errorf("完了イベントに結果が設定されていません: %+v", event)
+		}
+	}
+	if counts[EventFileStarted] != 3 || counts[EventFileFinished] != 3 || counts[EventScanCompleted] != 1 {
+		t.Errorf("イベント数 = %v", counts)
+	}
+
+	// 最後のイベントの進捗は統計情報と一致する
+	last := events[len(events)-1].Progress
+	stats := CalculateStatistics(results)
+	if last.Found != 3 || !last.ScanCompleted || last.Finished != 3 || last.Failed != stats.FailedFiles {
+		t.Errorf("最後の進捗 = %+v", last)
+	}
+	if last.OriginalBytes != stats.TotalOriginalSize || last.SavedBytes() != stats.TotalOriginalSize-stats.TotalCompressedSize {
+		t.Errorf("最後の進捗のサイズ = %+v, want %+v", last, stats)
+	}
+}
+
+func TestProcessor_SetProgressArchive(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "assets.zip")
+	createTestZip(t, inputPath, []testArchiveEntry{
+		{"a.jpg", encodeTestJPEG(t, 40, 40)},
+		{"readme.txt", []byte("text")},
+		{"b.png", encodeTestPNG(t, 40, 40)},
+	})
+
+	var last Progress
+	finished := 0
+	processor := NewProcessor(2, "")
+	processor.SetProgress(func(event Event) {
+		if event.Kind == EventFileFinished {
+			finished++
+		}
+		last = event.Progress
+	})
+	if _, err := processor.ProcessArchive(inputPath, filepath.Join(dir, "out.zip"), shuku.Options{Quality: 70}); err != nil {
+		t.Fatalf("ProcessArchive() error = %v", err)
+	}
+	if finished != 2 || last.Found != 2 || last.Finished != 2 {
+		t.Errorf("完了イベント数 = %d, 最後の進捗 = %+v", finished, last)
+	}
+}
+
+func TestProcessor_SetProgressCanceled(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for i := 0; i < 10; i++ {
+		fsys[fmt.Sprintf("image%d.jpg", i)] = &fstest.MapFile{Data: encodeTestJPEG(t, 40, 40), Mode: 0644}
+	}
+
+	// 最初のファイルの完了時に中断し、探索を途中で終了させる
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var last Progress
+	scanEvents := 0
+	processor := NewProcessor(1, "")
+	processor.SetProgress(func(event Event) {
+		if event.Kind == EventFileFinished {
+			cancel()
+		}
+		if event.Kind == EventScanCompleted {
+			scanEvents++
+		}
+		last = event.Progress
+	})
+	finished := 0
+	err := processor.StreamFSContext(ctx, fsys, storage.NewMemoryStorage(), shuku.Options{Quality: 70}, func(Result) error {
+		finished++
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("StreamFSContext() error = %v, want context.Canceled", err)
+	}
+
+	// 総数は実際に処理したファイル数で確定し、未処理のファイルが残らない
+	if scanEvents != 1 || !last.ScanCompleted {
+		t.Errorf("探索の終了イベント数 = %d, 最後の進捗 = %+v", scanEvents, last)
+	}
+	if last.Found != finished || last.Finished != finished || finished >= len(fsys) {
+		t.Errorf("最後の進捗 = %+v, 結果数 = %d", last, finished)
+	}
+}
+
+func TestProgress_ETA(t *testing.T) {
+	tests := []struct {
+		name     string
+		progress Progress
+		want     time.Duration
+		ok       bool
+	}{
+		{"探索中", Progress{Found: 10, Finished: 5, Elapsed: 5 * time.Second}, 0, false},
+		{"完了なし", Progress{Found: 10, ScanCompleted: true, Elapsed: time.Second}, 0, false},
+		{"半分完了", Progress{Found: 10, ScanCompleted: true, Finished: 5, Elapsed: 5 * time.Second}, 5 * time.Second, true},
+		{"すべて完了", Progress{Found: 4, ScanCompleted: true, Finished: 4, Elapsed: 2 * time.Second}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.progress.ETA()
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("ETA() = (%v, %v), want (%v, %v)", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
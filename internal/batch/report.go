@@ -0,0 +1,262 @@
+package batch
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/takumines/shuku/pkg/shuku"
+)
+
+// レポートの形式です。
+const (
+	ReportJSON  = "json"  // 統計情報と全ファイルの結果を含む JSON
+	ReportCSV   = "csv"   // 1ファイル1行の CSV（最後の行は合計）
+	ReportJUnit = "junit" // 各ファイルを1テストケースとし、失敗したファイルを失敗として扱う JUnit XML
+)
+
+// ReportFormats は WriteReport が対応する形式の一覧を返します。
+func ReportFormats() []string {
+	return []string{ReportJSON, ReportCSV, ReportJUnit}
+}
+
+// 結果の状態です。
+const (
+	statusSuccess = "success"
+	statusCached  = "cached"
+	statusResumed = "resumed"
+	statusFailed  = "failed"
+)
+
+// reportResult はレポートに出力する1ファイルの結果です。
+type reportResult struct {
+	InputPath        string  `json:"input_path"`
+	OutputPath       string  `json:"output_path"`
+	Status           string  `json:"status"`
+	Format           string  `json:"format,omitempty"`
+	OriginalSize     int64   `json:"original_size"`
+	CompressedSize   int64   `json:"compressed_size"`
+	CompressionRatio float64 `json:"compression_ratio"`
+	DurationSeconds  float64 `json:"duration_seconds"`
+	Warning          string  `json:"warning,omitempty"`
+	Rule             string  `json:"rule,omitempty"`  // 適用したルールの名前
+	Stage            string  `json:"stage,omitempty"` // 失敗した処理段階（shuku.CompressError の場合のみ）
+	Error            string  `json:"error,omitempty"`
+}
+
+// reportStatistics はレポートに出力する統計情報です。
+type reportStatistics struct {
+	TotalFiles          int     `json:"total_files"`
+	SuccessFiles        int     `json:"success_files"`
+	CachedFiles         int     `json:"cached_files"`
+	ResumedFiles        int     `json:"resumed_files"`
+	FailedFiles         int     `json:"failed_files"`
+	TotalOriginalSize   int64   `json:"total_original_size"`
+	TotalCompressedSize int64   `json:"total_compressed_size"`
+	CompressionRatio    float64 `json:"compression_ratio"`
+	DurationSeconds     float64 `json:"duration_seconds"` // 各ファイルの処理時間の合計
+}
+
+// WriteReport は処理結果と統計情報を format の形式で w に書き込みます。
+// 結果は実行ごとに同じ順序になるよう、入力パスの順に並べ替えて出力します。
+func WriteReport(w io.Writer, format string, results []Result, stats Statistics) error {
+	sorted := make([]reportResult, len(results))
+	var total time.Duration
+	for i, result := range results {
+		sorted[i] = newReportResult(result)
+		total += result.Duration
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].InputPath < sorted[j].InputPath })
+
+	summary := reportStatistics{
+		TotalFiles:          stats.TotalFiles,
+		SuccessFiles:        stats.SuccessFiles,
+		CachedFiles:         stats.CachedFiles,
+		ResumedFiles:        stats.ResumedFiles,
+		FailedFiles:         stats.FailedFiles,
+		TotalOriginalSize:   stats.TotalOriginalSize,
+		TotalCompressedSize: stats.TotalCompressedSize,
+		CompressionRatio:    stats.CompressionRatio,
+		DurationSeconds:     total.Seconds(),
+	}
+
+	switch format {
+	case ReportJSON:
+		return writeJSONReport(w, sorted, summary)
+	case ReportCSV:
+		return writeCSVReport(w, sorted, summary)
+	case ReportJUnit:
+		return writeJUnitReport(w, sorted, summary)
+	}
+	return fmt.Errorf("サポートされていないレポート形式です: %s", format)
+}
+
+func newReportResult(result Result) reportResult {
+	r := reportResult{
+		InputPath:       result.Job.InputPath,
+		OutputPath:      result.Job.OutputPath,
+		Status:          statusSuccess,
+		Format:          result.DetectedFormat,
+		DurationSeconds: result.Duration.Seconds(),
+		Warning:         result.Warning,
+		Rule:            result.Rule,
+	}
+	switch {
+	case result.Error != nil:
+		r.Status = statusFailed
+		r.Error = result.Error.Error()
+		var compressErr *shuku.CompressError
+		if errors.As(result.Error, &compressErr) {
+			r.Stage = string(compressErr.Stage)
+		}
+		return r
+	case result.Cached:
+		r.Status = statusCached
+	case result.Resumed:
+		r.Status = statusResumed
+	}
+	r.OriginalSize = result.OriginalSize
+	r.CompressedSize = result.CompressedSize
+	if result.OriginalSize > 0 {
+		r.CompressionRatio = 100.0 - float64(result.CompressedSize)/float64(result.OriginalSize)*100.0
+	}
+	return r
+}
+
+func writeJSONReport(w io.Writer, results []reportResult, stats reportStatistics) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Statistics reportStatistics `json:"statistics"`
+		Results    []reportResult   `json:"results"`
+	}{stats, results})
+}
+
+// writeCSVReport は1ファイル1行の CSV を書き込み、最後に status が "total" の合計行を追加します。
+func writeCSVReport(w io.Writer, results []reportResult, stats reportStatistics) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{
+		"input_path", "output_path", "status", "format", "original_size", "compressed_size",
+		"compression_ratio", "duration_seconds", "warning", "stage", "error", "rule",
+	})
+	for _, r := range results {
+		_ = cw.Write([]string{
+			r.InputPath, r.OutputPath, r.Status, r.Format,
+			strconv.FormatInt(r.OriginalSize, 10), strconv.FormatInt(r.CompressedSize, 10),
+			formatFloat(r.CompressionRatio), formatFloat(r.DurationSeconds),
+			r.Warning, r.Stage, r.Error, r.Rule,
+		})
+	}
+	_ = cw.Write([]string{
+		"", "", "total", "",
+		strconv.FormatInt(stats.TotalOriginalSize, 10), strconv.FormatInt(stats.TotalCompressedSize, 10),
+		formatFloat(stats.CompressionRatio), formatFloat(stats.DurationSeconds),
+		"", "", fmt.Sprintf("%d/%d files failed", stats.FailedFiles, stats.TotalFiles), "",
+	})
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// JUnit XML の要素です。
+type junitTestSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	Cases      []junitCase     `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnitReport は各ファイルを1テストケースとする JUnit XML を書き込みます。
+// 失敗したファイルは failure 要素を持ち、統計情報はテストスイートの properties に含めます。
+func writeJUnitReport(w io.Writer, results []reportResult, stats reportStatistics) error {
+	suite := junitSuite{
+		Name:     "shuku batch",
+		Tests:    len(results),
+		Failures: stats.FailedFiles,
+		Time:     formatFloat(stats.DurationSeconds),
+		Properties: []junitProperty{
+			{"success_files", strconv.Itoa(stats.SuccessFiles)},
+			{"cached_files", strconv.Itoa(stats.CachedFiles)},
+			{"resumed_files", strconv.Itoa(stats.ResumedFiles)},
+			{"total_original_size", strconv.FormatInt(stats.TotalOriginalSize, 10)},
+			{"total_compressed_size", strconv.FormatInt(stats.TotalCompressedSize, 10)},
+			{"compression_ratio", formatFloat(stats.CompressionRatio)},
+		},
+		Cases: make([]junitCase, 0, len(results)),
+	}
+
+	for _, r := range results {
+		c := junitCase{ClassName: "shuku.batch", Name: r.InputPath, Time: formatFloat(r.DurationSeconds)}
+		if r.Status == statusFailed {
+			failureType := r.Stage
+			if failureType == "" {
+				failureType = "error"
+			}
+			c.Failure = &junitFailure{Message: r.Error, Type: failureType, Text: r.Error}
+		} else {
+			c.SystemOut = fmt.Sprintf("%s → %s (%s, %s, %d → %d bytes, %s%%)",
+				r.InputPath, r.OutputPath, r.Status, r.Format, r.OriginalSize, r.CompressedSize, formatFloat(r.CompressionRatio))
+		}
+		if r.Warning != "" {
+			c.SystemOut = r.Warning + "\n" + c.SystemOut
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	err := encoder.Encode(junitTestSuites{
+		Name:     "shuku",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Time:     suite.Time,
+		Suites:   []junitSuite{suite},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
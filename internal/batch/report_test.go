@@ -0,0 +1,142 @@
+package batch
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/takumines/shuku/pkg/shuku"
+)
+
+// testReportResults は完了順（入力パスの順ではない）に並んだ処理結果です。
+func testReportResults() []Result {
+	return []Result{
+		{
+			Job:            Job{InputPath: "images/c.png", OutputPath: "out/c.png"},
+			OriginalSize:   1000,
+			CompressedSize: 400,
+			DetectedFormat: "png",
+			Duration:       1500 * time.Millisecond,
+			Rule:           "icons",
+		},
+		{
+			Job:      Job{InputPath: "images/a.jpg", OutputPath: "out/a.jpg"},
+			Error:    fmt.Errorf("圧縮処理エラー: %w", &shuku.CompressError{Stage: shuku.StageDecode, Format: "jpeg", Err: shuku.ErrInvalidImage}),
+			Duration: 500 * time.Millisecond,
+		},
+		{
+			Job:            Job{InputPath: "images/b.jpg", OutputPath: "out/b.jpg"},
+			OriginalSize:   200,
+			CompressedSize: 150,
+			DetectedFormat: "jpeg",
+			Cached:         true,
+			Warning:        "拡張子(.jpg)と内容(png)が一致しません",
+		},
+	}
+}
+
+func TestWriteReport_JSON(t *testing.T) {
+	results := testReportResults()
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, ReportJSON, results, CalculateStatistics(results)); err != nil {
+		t.Fatalf("WriteReport() error = %v", err)
+	}
+
+	var report struct {
+		Statistics reportStatistics `json:"statistics"`
+		Results    []reportResult   `json:"results"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("JSON を読み込めません: %v\n%s", err, buf.String())
+	}
+
+	stats := report.Statistics
+	if stats.TotalFiles != 3 || stats.FailedFiles != 1 || stats.CachedFiles != 1 || stats.TotalOriginalSize != 1200 || stats.DurationSeconds != 2 {
+		t.Errorf("statistics = %+v", stats)
+	}
+
+	tests := []struct {
+		input  string
+		status string
+		stage  string
+		ratio  float64
+	}{
+		{"images/a.jpg", "failed", "decode", 0},
+		{"images/b.jpg", "cached", "", 25},
+		{"images/c.png", "success", "", 60},
+	}
+	if len(report.Results) != len(tests) {
+		t.Fatalf("results count = %d, want %d", len(report.Results), len(tests))
+	}
+	for i, tt := range tests {
+		got := report.Results[i]
+		if got.InputPath != tt.input || got.Status != tt.status || got.Stage != tt.stage || got.CompressionRatio != tt.ratio {
+			t.Errorf("results[%d] = %+v, want %s (%s)", i, got, tt.input, tt.status)
+		}
+	}
+	if report.Results[0].Error == "" || report.Results[1].Warning == "" || report.Results[2].Rule != "icons" {
+		t.Errorf("エラー・警告・ルールが出力されていません: %+v", report.Results)
+	}
+}
+
+func TestWriteReport_CSV(t *testing.T) {
+	results := testReportResults()
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, ReportCSV, results, CalculateStatistics(results)); err != nil {
+		t.Fatalf("WriteReport() error = %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV を読み込めません: %v", err)
+	}
+	// ヘッダー・3ファイル・合計の5行
+	if len(records) != 5 {
+		t.Fatalf("行数 = %d, want 5", len(records))
+	}
+	if records[0][0] != "input_path" || records[1][0] != "images/a.jpg" || records[3][0] != "images/c.png" || records[3][11] != "icons" {
+		t.Errorf("行の順序が不正です: %v", records)
+	}
+	if total := records[4]; total[2] != "total" || total[4] != "1200" || total[5] != "550" {
+		t.Errorf("合計行 = %v", total)
+	}
+}
+
+func TestWriteReport_JUnit(t *testing.T) {
+	results := testReportResults()
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, ReportJUnit, results, CalculateStatistics(results)); err != nil {
+		t.Fatalf("WriteReport() error = %v", err)
+	}
+
+	var suites junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("XML を読み込めません: %v\n%s", err, buf.String())
+	}
+	if suites.Tests != 3 || suites.Failures != 1 || len(suites.Suites) != 1 {
+		t.Fatalf("testsuites = %+v", suites)
+	}
+	cases := suites.Suites[0].Cases
+	if len(cases) != 3 || cases[0].Name != "images/a.jpg" || cases[2].Name != "images/c.png" {
+		t.Fatalf("testcase = %+v", cases)
+	}
+	if cases[0].Failure == nil || cases[0].Failure.Type != "decode" {
+		t.Errorf("失敗したファイルの failure = %+v", cases[0].Failure)
+	}
+	if cases[1].Failure != nil || cases[2].Failure != nil {
+		t.Error("成功したファイルに failure が設定されています")
+	}
+	if cases[2].Time != "1.5" {
+		t.Errorf("time = %v, want 1.5", cases[2].Time)
+	}
+}
+
+func TestWriteReport_UnsupportedFormat(t *testing.T) {
+	if err := WriteReport(&bytes.Buffer{}, "yaml", nil, Statistics{}); err == nil {
+		t.Error("サポートされていない形式に対してエラーが発生しませんでした")
+	}
+}
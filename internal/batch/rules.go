@@ -0,0 +1,375 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/takumines/shuku/internal/glob"
+	"github.com/takumines/shuku/pkg/shuku"
+)
+
+// Rule はパターンに一致するファイルに適用する圧縮オプションの上書きです。
+// 指定されていない設定は、処理全体に指定したオプションをそのまま使用します。
+//
+// PNG のエンコーダーは品質やパレットの色数を出力に反映しないため、品質と上限サイズは JPEG・WebP にのみ効果があります。
+type Rule struct {
+	Name    string `json:"name,omitempty"`     // 結果に記録する名前（空の場合は Pattern）
+	Pattern string `json:"pattern"`            // 入力元のルートからの相対パスと比較するパターン（書式は glob.Match と同じ）
+	Quality *int   `json:"quality,omitempty"`  // JPEG・WebP の品質
+	Copy    bool   `json:"copy,omitempty"`     // 再圧縮せず、元の内容をそのまま出力するかどうか
+	MaxSize int64  `json:"max_size,omitempty"` // 出力の上限（バイト）。超える場合は品質を下げて圧縮し直す（0 は上限なし）
+}
+
+// Label は結果に記録するルールの名前を返します。nil の場合は空文字列を返します。
+func (r *Rule) Label() string {
+	if r == nil {
+		return ""
+	}
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Pattern
+}
+
+// Validate はルールの値が有効かどうかを検証します。
+func (r Rule) Validate() error {
+	var errs []error
+	if r.Pattern == "" {
+		errs = append(errs, errors.New("パターンが指定されていません"))
+	} else if err := glob.Validate(r.Pattern); err != nil {
+		errs = append(errs, fmt.Errorf("パターンが不正です: %s: %w", r.Pattern, err))
+	}
+	if err := r.apply(shuku.Options{}).Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if r.MaxSize < 0 {
+		errs = append(errs, fmt.Errorf("上限サイズは0以上で指定してください: %d", r.MaxSize))
+	}
+	if r.Copy && (r.Quality != nil || r.MaxSize > 0) {
+		errs = append(errs, errors.New("copy は品質・上限サイズと同時に指定できません"))
+	}
+	if r.pngOnly() && (r.Quality != nil || r.MaxSize > 0) {
+		errs = append(errs, errors.New("PNG のエンコーダーは品質を調整できないため、PNG のみに一致するパターンには品質・上限サイズを指定できません"))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("ルール %s: %w", r.Label(), err)
+	}
+	return nil
+}
+
+// UnmarshalJSON は max_size にバイト数の数値か、"300KB" のような文字列を受け付けます。
+// 設定の書き間違いに気付けるよう、不明なフィールドはエラーにします。
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	type plain Rule
+	var v struct {
+		plain
+		MaxSize json.RawMessage `json:"max_size,omitempty"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&v); err != nil {
+		return err
+	}
+	*r = Rule(v.plain)
+	if len(v.MaxSize) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(v.MaxSize, &r.MaxSize); err == nil {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(v.MaxSize, &s); err != nil {
+		return fmt.Errorf("max_size にはバイト数か \"300KB\" のような文字列を指定してください: %s", v.MaxSize)
+	}
+	size, err := ParseSize(s)
+	if err != nil {
+		return err
+	}
+	r.MaxSize = size
+	return nil
+}
+
+// ParseRule は "パターン:設定:設定..." 形式の文字列をルールに変換します。
+// 設定には quality=品質、max-size=サイズ（"300KB" など）、copy、name=名前 を指定できます。
+// 例: "photos/**:quality=75"、"icons/**:copy"、"hero/**:max-size=300KB"
+func ParseRule(s string) (Rule, error) {
+	parts := strings.Split(s, ":")
+	rule := Rule{Pattern: strings.TrimSpace(parts[0])}
+	for _, part := range parts[1:] {
+		key, value, hasValue := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "quality":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return Rule{}, fmt.Errorf("ルール %s: %s には整数を指定してください: %q", s, key, value)
+			}
+			rule.Quality = &n
+		case "max-size":
+			size, err := ParseSize(value)
+			if err != nil {
+				return Rule{}, fmt.Errorf("ルール %s: %w", s, err)
+			}
+			rule.MaxSize = size
+		case "copy":
+			if hasValue {
+				return Rule{}, fmt.Errorf("ルール %s: copy には値を指定できません", s)
+			}
+			rule.Copy = true
+		case "name":
+			rule.Name = value
+		default:
+			return Rule{}, fmt.Errorf("ルール %s: 不明な設定です: %q", s, part)
+		}
+	}
+	if err := rule.Validate(); err != nil {
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+// sizeUnits はサイズの単位と倍率です（1KB = 1024 バイト）。長い単位から順に比較します。
+var sizeUnits = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize は "300KB" や "1.5MB"、"4096" のようなサイズの文字列をバイト数に変換します。
+// 単位は大文字と小文字を区別せず、1KB を 1024 バイトとして扱います。
+func ParseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := 1.0
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("サイズの形式が不正です: %q", s)
+	}
+	return int64(n * multiplier), nil
+}
+
+// rulesFile はルールの設定ファイルの形式です。
+type rulesFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// LoadRules は JSON の設定ファイルからルールを読み込みます。
+// 設定ファイルは {"rules": [{"pattern": "photos/**", "quality": 75}, ...]} の形式で、ルールは記述した順に比較されます。
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file rulesFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("ルールの設定ファイルを読み込めません: %s: %w", path, err)
+	}
+	if err := validateRules(file.Rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file.Rules, nil
+}
+
+// validateRules はすべてのルールを検証します。
+func validateRules(rules []Rule) error {
+	var errs []error
+	for _, rule := range rules {
+		errs = append(errs, rule.Validate())
+	}
+	return errors.Join(errs...)
+}
+
+// SetRules はパターンごとの圧縮オプションの上書きを設定します。
+// 各ファイルには入力元のルートからの相対パスに最初に一致したルールが適用され、
+// 適用したルールの名前は Result の Rule に記録されます。ルールは処理の開始時に検証されます。
+func (p *Processor) SetRules(rules []Rule) {
+	p.Rules = rules
+}
+
+// matchRule は name（入力元のルートからの "/" 区切りの相対パス）に最初に一致したルールを返します。
+func (p *Processor) matchRule(name string) *Rule {
+	for i := range p.Rules {
+		if matched, _ := glob.Match(p.Rules[i].Pattern, name, p.IgnoreCase); matched {
+			return &p.Rules[i]
+		}
+	}
+	return nil
+}
+
+// pngOnly はパターンが PNG のファイルにのみ一致する（最後の要素が ".png" で終わる）かどうかを返します。
+func (r Rule) pngOnly() bool {
+	return strings.EqualFold(path.Ext(r.Pattern), ".png")
+}
+
+// apply は options にルールの設定を上書きしたオプションを返します。
+// ルールの品質は、形式ごとの設定より優先されます。
+func (r *Rule) apply(options shuku.Options) shuku.Options {
+	if r == nil {
+		return options
+	}
+	if r.Quality != nil {
+		options.Quality = *r.Quality
+		options.JPEG = nil
+		options.WebP = nil
+	}
+	return options
+}
+
+// compressJob は rule に従って data を圧縮し、結果を w に書き込みます。
+// 上限サイズに収まらなかった場合などの警告は warning として返します。
+func compressJob(ctx context.Context, w io.Writer, path string, data []byte, options shuku.Options, rule *Rule) (report *shuku.Report, warning string, err error) {
+	switch {
+	case rule != nil && rule.Copy:
+		report, err = copyUnchanged(w, path, data)
+		return report, "", err
+	case rule != nil && rule.MaxSize > 0:
+		return compressWithinSize(ctx, w, path, data, options, rule.MaxSize)
+	}
+	report, err = shuku.CompressWithReportContext(ctx, path, data, w, options)
+	return report, "", err
+}
+
+// copyUnchanged は data を再圧縮せずにそのまま w に書き込みます。
+func copyUnchanged(w io.Writer, path string, data []byte) (*shuku.Report, error) {
+	format, err := shuku.DetectFormat(data)
+	if err != nil {
+		return nil, &shuku.CompressError{Stage: shuku.StageDetect, Path: path, Err: err}
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	size := int64(len(data))
+	report := &shuku.Report{InputPath: path, InputSize: size, OutputSize: size, Format: format}
+	if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		report.Width, report.Height = config.Width, config.Height
+	}
+	return report, nil
+}
+
+// compressWithinSize は出力が maxSize バイト以下になるよう、品質を下げて圧縮します。
+// 品質は上限に収まる最大の値を二分探索で求めます。どの品質でも収まらない場合は最も小さい出力を書き込み、警告を返します。
+// 品質を調整できない形式（PNG など）は圧縮し直さず、上限を超える場合は警告のみを返します。
+// 圧縮し直す場合、入力は一度だけデコードし、探索の各段階ではエンコードのみを行います。
+func compressWithinSize(ctx context.Context, w io.Writer, path string, data []byte, options shuku.Options, maxSize int64) (*shuku.Report, string, error) {
+	var buf bytes.Buffer
+	report, err := shuku.CompressWithReportContext(ctx, path, data, &buf, options)
+	if err != nil {
+		return nil, "", err
+	}
+	output := buf.Bytes()
+
+	var fit []byte
+	var fitReport *shuku.Report
+	smallest, smallestReport := output, report
+	if report.OutputSize <= maxSize {
+		fit, fitReport = output, report
+	} else if report.Format == "jpeg" || report.Format == "webp" {
+		encode, err := qualityEncoder(ctx, path, data, report)
+		if err != nil {
+			return nil, "", err
+		}
+
+		// 最も小さい出力と、上限に収まった出力のうち最も画質の高いものを記録する
+		lo, hi := 0, report.Quality-1
+		for lo <= hi {
+			mid := (lo + hi) / 2
+			o := options
+			o.Quality, o.JPEG, o.WebP = mid, nil, nil
+			out, rep, err := encode(o)
+			if err != nil {
+				return nil, "", err
+			}
+			if rep.OutputSize < smallestReport.OutputSize {
+				smallest, smallestReport = out, rep
+			}
+			if rep.OutputSize <= maxSize {
+				fit, fitReport = out, rep
+				lo = mid + 1
+			} else {
+				hi = mid - 1
+			}
+		}
+	}
+
+	var warning string
+	if fitReport == nil {
+		fit, fitReport = smallest, smallestReport
+		warning = fmt.Sprintf("出力(%d バイト)が上限(%d バイト)を超えています", fitReport.OutputSize, maxSize)
+		if fitReport.Format != "jpeg" && fitReport.Format != "webp" {
+			warning += fmt.Sprintf("（%s は品質を調整できません）", fitReport.Format)
+		}
+	}
+	if _, err := w.Write(fit); err != nil {
+		return nil, "", err
+	}
+	return fitReport, warning, nil
+}
+
+// qualityEncoder は data を品質を変えて圧縮し直す関数を返します。
+// 入力形式のコンプレッサーが Codec を実装している場合は一度だけデコードし、返す関数ではエンコードのみを行います。
+// 実装していない場合は、呼び出しごとに data 全体を圧縮し直します。
+func qualityEncoder(ctx context.Context, path string, data []byte, report *shuku.Report) (func(shuku.Options) ([]byte, *shuku.Report, error), error) {
+	comp, _ := shuku.Lookup(report.Format)
+	codec, ok := comp.(shuku.Codec)
+	if !ok {
+		return func(o shuku.Options) ([]byte, *shuku.Report, error) {
+			var buf bytes.Buffer
+			rep, err := shuku.CompressWithReportContext(ctx, path, data, &buf, o)
+			return buf.Bytes(), rep, err
+		}, nil
+	}
+
+	img, err := codec.Decode(ctx, bytes.NewReader(data))
+	if err != nil {
+		return nil, &shuku.CompressError{Stage: shuku.StageDecode, Format: report.Format, Path: path, Err: err}
+	}
+	return func(o shuku.Options) ([]byte, *shuku.Report, error) {
+		start := time.Now()
+		encoded, err := shuku.EncodeImageContext(ctx, img, report.Format, o)
+		if err != nil {
+			return nil, nil, err
+		}
+		rep := *report
+		rep.OutputSize = int64(encoded.Len())
+		rep.Quality = o.Quality
+		rep.EncodeDuration = time.Since(start)
+		return encoded.Bytes(), &rep, nil
+	}, nil
+}
+
+// joinWarnings は空でない警告を連結します。
+func joinWarnings(warnings ...string) string {
+	var nonEmpty []string
+	for _, w := range warnings {
+		if w != "" {
+			nonEmpty = append(nonEmpty, w)
+		}
+	}
+	return strings.Join(nonEmpty, "、")
+}
@@ -0,0 +1,296 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/takumines/shuku/internal/storage"
+	"github.com/takumines/shuku/pkg/shuku"
+)
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Rule
+		wantErr  bool
+	}{
+		{"品質", "photos/**:quality=75", Rule{Pattern: "photos/**", Quality: intPtr(75)}, false},
+		{"copy", "icons/**:copy", Rule{Pattern: "icons/**", Copy: true}, false},
+		{"上限サイズと名前", "hero/**:max-size=300KB:name=hero", Rule{Name: "hero", Pattern: "hero/**", MaxSize: 300 * 1024}, false},
+		{"PNG のみのパターンへの copy", "*.png:copy", Rule{Pattern: "*.png", Copy: true}, false},
+		{"設定なし", "raw/**", Rule{Pattern: "raw/**"}, false},
+		{"範囲外の品質", "photos/**:quality=101", Rule{}, true},
+		{"整数ではない品質", "photos/**:quality=high", Rule{}, true},
+		{"不明な設定", "photos/**:speed=1", Rule{}, true},
+		{"copy と品質", "icons/**:copy:quality=80", Rule{}, true},
+		{"copy の値", "icons/**:copy=true", Rule{}, true},
+		{"パレットの色数", "*.png:palette-size=64", Rule{}, true},
+		{"PNG のみのパターンへの品質", "icons/*.png:quality=80", Rule{}, true},
+		{"PNG のみのパターンへの上限サイズ", "**/*.PNG:max-size=10KB", Rule{}, true},
+		{"パターンなし", ":quality=80", Rule{}, true},
+		{"不正なパターン", "[:quality=80", Rule{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRule(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Name != tt.expected.Name || got.Pattern != tt.expected.Pattern || got.Copy != tt.expected.Copy ||
+				got.MaxSize != tt.expected.MaxSize || !equalIntPtr(got.Quality, tt.expected.Quality) {
+				t.Errorf("ParseRule() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func equalIntPtr(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		wantErr  bool
+	}{
+		{"4096", 4096, false},
+		{"512B", 512, false},
+		{"300KB", 300 * 1024, false},
+		{"300kb", 300 * 1024, false},
+		{"1.5MB", 1536 * 1024, false},
+		{"2M", 2 << 20, false},
+		{"1GB", 1 << 30, false},
+		{" 10 KB ", 10 * 1024, false},
+		{"", 0, true},
+		{"-1KB", 0, true},
+		{"large", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("ParseSize() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		check   func(t *testing.T, rules []Rule)
+		wantErr bool
+	}{
+		{
+			name: "ルールを記述した順に読み込む",
+			content: `{"rules": [
+				{"pattern": "photos/**", "quality": 75},
+				{"name": "icons", "pattern": "icons/**", "copy": true},
+				{"pattern": "hero/**", "max_size": "300KB"},
+				{"pattern": "banner/**", "max_size": 1024, "quality": 60}
+			]}`,
+			check: func(t *testing.T, rules []Rule) {
+				if len(rules) != 4 {
+					t.Fatalf("ルール数 = %d, want 4", len(rules))
+				}
+				if rules[0].Pattern != "photos/**" || !equalIntPtr(rules[0].Quality, intPtr(75)) {
+					t.Errorf("rules[0] = %+v", rules[0])
+				}
+				if rules[1].Label() != "icons" || !rules[1].Copy {
+					t.Errorf("rules[1] = %+v", rules[1])
+				}
+				if rules[2].MaxSize != 300*1024 || rules[2].Label() != "hero/**" {
+					t.Errorf("rules[2] = %+v", rules[2])
+				}
+				if rules[3].MaxSize != 1024 || !equalIntPtr(rules[3].Quality, intPtr(60)) {
+					t.Errorf("rules[3] = %+v", rules[3])
+				}
+			},
+		},
+		{name: "不明なフィールド", content: `{"rules": [{"pattern": "a/**", "qualty": 75}]}`, wantErr: true},
+		{name: "不正な上限サイズ", content: `{"rules": [{"pattern": "a/**", "max_size": "big"}]}`, wantErr: true},
+		{name: "不正なルール", content: `{"rules": [{"pattern": "a/**", "quality": 200}]}`, wantErr: true},
+		{name: "パレットの色数", content: `{"rules": [{"pattern": "*.png", "palette_size": 64}]}`, wantErr: true},
+		{name: "不正な JSON", content: `{"rules": [`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "shuku.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("設定ファイルの作成に失敗しました: %v", err)
+			}
+			rules, err := LoadRules(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, rules)
+			}
+		})
+	}
+
+	t.Run("ファイルが存在しない", func(t *testing.T) {
+		if _, err := LoadRules(filepath.Join(t.TempDir(), "missing.json")); !os.IsNotExist(err) {
+			t.Errorf("LoadRules() error = %v, want ファイルが存在しないエラー", err)
+		}
+	})
+}
+
+// compressedSize はテスト用の画像を options で圧縮したサイズを返します。
+func compressedSize(t *testing.T, data []byte, options shuku.Options) int64 {
+	t.Helper()
+	report, err := shuku.CompressWithReportContext(context.Background(), "test", data, io.Discard, options)
+	if err != nil {
+		t.Fatalf("圧縮に失敗しました: %v", err)
+	}
+	return report.OutputSize
+}
+
+func TestProcessor_Rules(t *testing.T) {
+	jpegData := encodeTestJPEG(t, 200, 200)
+	pngData := encodeTestPNG(t, 120, 120)
+	options := shuku.Options{Quality: 95}
+
+	// 上限は品質 95 では超え、品質 10 では収まるサイズにする
+	jpegMax := (compressedSize(t, jpegData, shuku.Options{Quality: 10}) + compressedSize(t, jpegData, options)) / 2
+
+	fsys := fstest.MapFS{
+		"photos/a.jpg":   {Data: jpegData, Mode: 0644},
+		"icons/logo.png": {Data: pngData, Mode: 0644},
+		"hero/top.jpg":   {Data: jpegData, Mode: 0644},
+		"hero/top.png":   {Data: pngData, Mode: 0644},
+		"hero/tiny.jpg":  {Data: jpegData, Mode: 0644},
+		"other.jpg":      {Data: jpegData, Mode: 0644},
+	}
+	rules := []Rule{
+		{Name: "tiny", Pattern: "hero/tiny.jpg", MaxSize: 10},
+		{Pattern: "photos/**", Quality: intPtr(30)},
+		{Name: "icons", Pattern: "icons/**", Copy: true},
+		{Pattern: "hero/*.jpg", MaxSize: jpegMax},
+		// PNG は品質を調整できないため、上限を超えても圧縮し直さない
+		{Pattern: "hero/**", MaxSize: 10},
+		// 先に一致したルールが適用されるため、photos/** 以下には適用されない
+		{Pattern: "**/*.jpg", Quality: intPtr(50)},
+	}
+
+	processor := NewProcessor(2, "")
+	processor.SetRecursive(true)
+	processor.SetRules(rules)
+	dst := storage.NewMemoryStorage()
+	results, err := processor.ProcessFS(fsys, dst, options)
+	if err != nil {
+		t.Fatalf("ProcessFS() error = %v", err)
+	}
+
+	byName := make(map[string]Result)
+	for _, result := range results {
+		if result.Error != nil {
+			t.Fatalf("%s: error = %v", result.Job.InputPath, result.Error)
+		}
+		byName[result.Job.InputPath] = result
+	}
+
+	tests := []struct {
+		name    string
+		rule    string
+		quality int
+	}{
+		{"photos/a.jpg", "photos/**", 30},
+		{"icons/logo.png", "icons", 0},
+		{"hero/top.jpg", "hero/*.jpg", 0},
+		{"hero/top.png", "hero/**", 0},
+		{"hero/tiny.jpg", "tiny", 0},
+		{"other.jpg", "**/*.jpg", 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := byName[tt.name]
+			if result.Rule != tt.rule {
+				t.Errorf("Rule = %q, want %q", result.Rule, tt.rule)
+			}
+			if tt.quality != 0 && (result.Job.Options.Quality != tt.quality || result.Report.Quality != tt.quality) {
+				t.Errorf("Quality = %d (適用 %d), want %d", result.Job.Options.Quality, result.Report.Quality, tt.quality)
+			}
+		})
+	}
+
+	t.Run("copy は元の内容をそのまま出力する", func(t *testing.T) {
+		output, err := dst.ReadFile("icons/logo.png")
+		if err != nil || !bytes.Equal(output, pngData) {
+			t.Errorf("出力が元の内容と異なります (error = %v)", err)
+		}
+		if report := byName["icons/logo.png"].Report; report.Width != 120 || report.Height != 120 || report.Format != "png" {
+			t.Errorf("Report = %+v", report)
+		}
+	})
+
+	t.Run("上限サイズに収める", func(t *testing.T) {
+		result := byName["hero/top.jpg"]
+		output, err := dst.ReadFile("hero/top.jpg")
+		if err != nil {
+			t.Fatalf("出力を読み込めません: %v", err)
+		}
+		// 上限がない場合の出力より実際に小さくなり、上限に収まる
+		unlimited := compressedSize(t, jpegData, options)
+		if int64(len(output)) != result.CompressedSize || int64(len(output)) > jpegMax || int64(len(output)) >= unlimited || result.Warning != "" {
+			t.Errorf("出力 = %d バイト, 上限 = %d, 上限なし = %d, 警告 = %q", len(output), jpegMax, unlimited, result.Warning)
+		}
+		// 品質は上限に収まる範囲でできるだけ高くする
+		if q := byName["hero/top.jpg"].Report.Quality; q <= 10 || q >= 95 {
+			t.Errorf("hero/top.jpg の品質 = %d", q)
+		}
+	})
+
+	t.Run("上限サイズに収まらない場合は警告する", func(t *testing.T) {
+		result := byName["hero/tiny.jpg"]
+		if !strings.Contains(result.Warning, "上限") {
+			t.Errorf("Warning = %q", result.Warning)
+		}
+		if smallest := compressedSize(t, jpegData, shuku.Options{Quality: 0}); result.CompressedSize > smallest {
+			t.Errorf("サイズ = %d, want 最小の出力 %d 以下", result.CompressedSize, smallest)
+		}
+	})
+
+	t.Run("品質を調整できない形式は圧縮し直さずに警告する", func(t *testing.T) {
+		result := byName["hero/top.png"]
+		if !strings.Contains(result.Warning, "品質を調整できません") {
+			t.Errorf("Warning = %q", result.Warning)
+		}
+		if want := compressedSize(t, pngData, options); result.CompressedSize != want {
+			t.Errorf("サイズ = %d, want 上限なしの出力 %d", result.CompressedSize, want)
+		}
+	})
+
+	t.Run("不正なルール", func(t *testing.T) {
+		processor := NewProcessor(1, "")
+		processor.SetRules([]Rule{{Pattern: "[", Quality: intPtr(80)}})
+		if _, err := processor.ProcessFS(fsys, storage.NewMemoryStorage(), options); err == nil {
+			t.Error("不正なルールに対してエラーが発生しませんでした")
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package compressor
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"io"
+)
+
+// CompressContext は ctx を確認しながら画像を圧縮し、結果の画像を返します。
+// エンコードとデコードの各段階の前後でキャンセルを確認します。
+func CompressContext(ctx context.Context, c Codec, img image.Image, options Options) (image.Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+	if err := c.Encode(&contextWriter{ctx: ctx, w: buf}, img, options); err != nil {
+		return nil, contextErr(ctx, err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	compressed, err := c.Decode(&contextReader{ctx: ctx, r: buf})
+	if err != nil {
+		return nil, contextErr(ctx, err)
+	}
+
+	return compressed, nil
+}
+
+// CompressBytesContext は ctx を確認しながらバイトスライスの画像データを圧縮します。
+func CompressBytesContext(ctx context.Context, c Codec, data []byte, options Options) ([]byte, error) {
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+	if err := CompressReaderContext(ctx, c, bytes.NewReader(data), buf, options); err != nil {
+		return nil, err
+	}
+	// バッファはプールに戻すため、結果は呼び出し側が所有するスライスに複製する
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// CompressReaderContext は ctx を確認しながらリーダーの画像データを圧縮し、ライターに書き込みます。
+// デコード中・エンコード中の読み書きでもキャンセルを検知して処理を中断します。
+func CompressReaderContext(ctx context.Context, c Codec, r io.Reader, w io.Writer, options Options) error {
+	img, err := DecodeContext(ctx, c, r)
+	if err != nil {
+		return err
+	}
+
+	return EncodeContext(ctx, c, w, img, options)
+}
+
+// DecodeContext は ctx を確認しながらリーダーから画像をデコードします。
+func DecodeContext(ctx context.Context, c Codec, r io.Reader) (image.Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	img, err := c.Decode(&contextReader{ctx: ctx, r: r})
+	if err != nil {
+		return nil, contextErr(ctx, err)
+	}
+	return img, nil
+}
+
+// EncodeContext は ctx を確認しながら画像をエンコードし、ライターに書き込みます。
+func EncodeContext(ctx context.Context, c Codec, w io.Writer, img image.Image, options Options) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := c.Encode(&contextWriter{ctx: ctx, w: w}, img, options); err != nil {
+		return contextErr(ctx, err)
+	}
+	return nil
+}
+
+// contextErr は ctx がキャンセル済みの場合に、デコード・エンコードのエラーより優先して ctx のエラーを返します。
+func contextErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// contextReader は読み込みのたびに ctx のキャンセルを確認するリーダーです。
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// contextWriter は書き込みのたびに ctx のキャンセルを確認するライターです。
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw *contextWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(p)
+}
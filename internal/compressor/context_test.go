@@ -0,0 +1,99 @@
+package compressor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/png"
+	"testing"
+)
+
+func TestCompressContext(t *testing.T) {
+	img := createTestImage(100, 100)
+	codecs := []struct {
+		name  string
+		codec Codec
+	}{
+		{"JPEG", NewJPEGCompressor()},
+		{"PNG", NewPNGCompressor()},
+		{"WebP", NewWebPCompressor()},
+	}
+
+	for _, tc := range codecs {
+		t.Run(tc.name, func(t *testing.T) {
+			// 正常系
+			compressed, err := CompressContext(context.Background(), tc.codec, img, Options{Quality: 80})
+			if err != nil {
+				t.Fatalf("CompressContext() error = %v", err)
+			}
+			if compressed.Bounds() != img.Bounds() {
+				t.Errorf("CompressContext() bounds = %v, want %v", compressed.Bounds(), img.Bounds())
+			}
+
+			// キャンセル済みのコンテキスト
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if _, err := CompressContext(ctx, tc.codec, img, Options{Quality: 80}); !errors.Is(err, context.Canceled) {
+				t.Errorf("CompressContext() error = %v, want %v", err, context.Canceled)
+			}
+		})
+	}
+}
+
+func TestCompressReaderContext(t *testing.T) {
+	var input bytes.Buffer
+	if err := png.Encode(&input, createTestImage(100, 100)); err != nil {
+		t.Fatalf("Failed to create test PNG data: %v", err)
+	}
+	codec := NewPNGCompressor()
+
+	t.Run("正常系", func(t *testing.T) {
+		data, err := CompressBytesContext(context.Background(), codec, input.Bytes(), Options{})
+		if err != nil {
+			t.Fatalf("CompressBytesContext() error = %v", err)
+		}
+		if len(data) == 0 {
+			t.Error("CompressBytesContext() returned empty data")
+		}
+	})
+
+	t.Run("キャンセル済み", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var output bytes.Buffer
+		err := CompressReaderContext(ctx, codec, bytes.NewReader(input.Bytes()), &output, Options{})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("CompressReaderContext() error = %v, want %v", err, context.Canceled)
+		}
+		if output.Len() != 0 {
+			t.Error("CompressReaderContext() キャンセル後に出力が書き込まれました")
+		}
+	})
+
+	t.Run("デコード中のキャンセル", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		// 最初の読み込みでキャンセルされるリーダー
+		r := &cancelingReader{r: bytes.NewReader(input.Bytes()), cancel: cancel}
+
+		var output bytes.Buffer
+		err := CompressReaderContext(ctx, codec, r, &output, Options{})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("CompressReaderContext() error = %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+// cancelingReader は最初の読み込み後に ctx をキャンセルするリーダーです。
+type cancelingReader struct {
+	r      *bytes.Reader
+	cancel context.CancelFunc
+}
+
+func (c *cancelingReader) Read(p []byte) (int, error) {
+	defer c.cancel()
+	if len(p) > 16 {
+		p = p[:16]
+	}
+	return c.r.Read(p)
+}
@@ -4,16 +4,23 @@
 package compressor
 
 import (
+	"errors"
 	"image"
 	"io"
 )
 
+// ErrInvalidQuality は品質パラメータが有効な範囲（0-100）外であることを表します。
+var ErrInvalidQuality = errors.New("品質が範囲外です")
+
 // Options は圧縮オプションを表します。
 type Options struct {
 	// Quality はJPEG圧縮の品質設定です（0-100）
 	Quality int
 	// PaletteSize はPNG圧縮のパレットサイズです
 	PaletteSize int
+
+	// scratch は Pipeline のエンコード中に変換が中間画像を確保するための領域です
+	scratch *scratch
 }
 
 // DefaultOptions はデフォルトのオプションを返します。
@@ -40,3 +47,38 @@ type Compressor interface {
 	// SupportedFormat はこのコンプレッサーがサポートする画像形式を返します。
 	SupportedFormat() string
 }
+
+// Decoder は画像データを読み込む段階を表します。
+type Decoder interface {
+	// Decode はリーダーから画像をデコードします。
+	Decode(r io.Reader) (image.Image, error)
+}
+
+// Encoder は画像を書き出す段階を表します。
+type Encoder interface {
+	// Encode は画像をエンコードし、ライターに書き込みます。
+	Encode(w io.Writer, img image.Image, options Options) error
+}
+
+// Codec はデコードとエンコードを個別の段階として実行できるコンプレッサーです。
+// 段階の間でキャンセルを確認する処理（CompressBytesContextなど）で使用されます。
+type Codec interface {
+	Decoder
+	Encoder
+}
+
+// DecoderFunc は関数を Decoder として使用するためのアダプターです。
+type DecoderFunc func(r io.Reader) (image.Image, error)
+
+// Decode は f(r) を呼び出します。
+func (f DecoderFunc) Decode(r io.Reader) (image.Image, error) {
+	return f(r)
+}
+
+// EncoderFunc は関数を Encoder として使用するためのアダプターです。
+type EncoderFunc func(w io.Writer, img image.Image, options Options) error
+
+// Encode は f(w, img, options) を呼び出します。
+func (f EncoderFunc) Encode(w io.Writer, img image.Image, options Options) error {
+	return f(w, img, options)
+}
@@ -1,7 +1,6 @@
 package compressor
 
 import (
-	"bytes"
 	"fmt"
 	"image"
 	"image/jpeg"
@@ -10,114 +9,85 @@ import (
 
 // JPEGCompressor はJPEG形式の画像を圧縮するための実装です。
 // JPEG品質設定を調整することで圧縮率を制御します。
-type JPEGCompressor struct{}
+// Compress・CompressBytes・CompressReader は Pipeline によるデコード・変換・エンコードで実行されます。
+type JPEGCompressor struct {
+	*Pipeline
+}
 
 // NewJPEGCompressor は新しいJPEGCompressorインスタンスを作成します。
-func NewJPEGCompressor() *JPEGCompressor {
-	return &JPEGCompressor{}
+// transforms はデコード後、エンコード前に指定した順で適用されます。
+func NewJPEGCompressor(transforms ...Transform) *JPEGCompressor {
+	j := &JPEGCompressor{}
+	j.Pipeline = NewPipeline("jpeg", DecoderFunc(j.decode), EncoderFunc(j.encode), transforms...)
+	return j
 }
 
-// Compress はJPEG画像を圧縮します。
-// options.Qualityは0-100の値を使用して圧縮品質を指定します。
-// 値が低いほどファイルサイズは小さくなりますが、画質は劣化します。
-func (j *JPEGCompressor) Compress(img image.Image, options Options) (image.Image, error) {
-	// JPEG圧縮を適用したバイトデータを取得
-	var buf bytes.Buffer
-	err := jpeg.Encode(&buf, img, &jpeg.Options{
-		Quality: j.validateQuality(options.Quality),
-	})
-	if err != nil {
-		return nil, &CompressError{
-			OriginalErr: err,
-			Format:      "JPEG",
-		}
-	}
-
-	// 圧縮されたバイトデータを画像として再デコード
-	compressed, err := jpeg.Decode(&buf)
+// decode はリーダーからJPEG画像をデコードします。
+func (j *JPEGCompressor) decode(r io.Reader) (image.Image, error) {
+	img, err := jpeg.Decode(r)
 	if err != nil {
 		return nil, &CompressError{
 			OriginalErr: err,
 			Format:      "JPEG",
+			Stage:       StageDecode,
+			Message:     "入力データが有効なJPEG画像ではありません",
 		}
 	}
-
-	return compressed, nil
+	return img, nil
 }
 
-// CompressBytes はバイト配列として提供されたJPEG画像データを圧縮します。
-func (j *JPEGCompressor) CompressBytes(data []byte, options Options) ([]byte, error) {
-	// 入力データが有効なJPEG画像であることを確認
-	img, err := jpeg.Decode(bytes.NewReader(data))
-	if err != nil {
-		return nil, &CompressError{
-			OriginalErr: err,
-			Format:      "JPEG",
-			Message:     "入力データが有効なJPEG画像ではありません",
-		}
+// encode は画像をJPEG形式でエンコードし、ライターに書き込みます。
+// options.Qualityは0-100の値を使用して圧縮品質を指定します。
+// 値が低いほどファイルサイズは小さくなりますが、画質は劣化します。
+func (j *JPEGCompressor) encode(w io.Writer, img image.Image, options Options) error {
+	if err := j.validateQuality(options.Quality); err != nil {
+		return err
 	}
-
-	// 圧縮を適用
-	var buf bytes.Buffer
-	err = jpeg.Encode(&buf, img, &jpeg.Options{
-		Quality: j.validateQuality(options.Quality),
+	err := jpeg.Encode(w, img, &jpeg.Options{
+		Quality: options.Quality,
 	})
 	if err != nil {
-		return nil, &CompressError{
+		return &CompressError{
 			OriginalErr: err,
 			Format:      "JPEG",
+			Stage:       StageEncode,
 		}
 	}
+	return nil
+}
 
-	return buf.Bytes(), nil
+// EffectiveOptions はエンコード時に実際に適用されるオプションを返します。
+func (j *JPEGCompressor) EffectiveOptions(options Options) Options {
+	return Options{Quality: options.Quality}
 }
 
-// CompressReader はリーダーから読み取ったJPEG画像データを圧縮し、ライターに書き込みます。
-func (j *JPEGCompressor) CompressReader(r io.Reader, w io.Writer, options Options) error {
-	// 入力データが有効なJPEG画像であることを確認
-	img, err := jpeg.Decode(r)
-	if err != nil {
+// validateQuality は品質パラメータが有効な範囲（0-100）かどうかを検証します。
+func (j *JPEGCompressor) validateQuality(quality int) error {
+	if quality < 0 || quality > 100 {
 		return &CompressError{
-			OriginalErr: err,
+			OriginalErr: fmt.Errorf("%w: %d", ErrInvalidQuality, quality),
 			Format:      "JPEG",
-			Message:     "入力データが有効なJPEG画像ではありません",
+			Stage:       StageEncode,
+			Message:     "品質は0〜100の範囲で指定してください",
 		}
 	}
-
-	// 圧縮を適用して結果をライターに書き込む
-	err = jpeg.Encode(w, img, &jpeg.Options{
-		Quality: j.validateQuality(options.Quality),
-	})
-	if err != nil {
-		return &CompressError{
-			OriginalErr: err,
-			Format:      "JPEG",
-		}
-	}
-
 	return nil
 }
 
-// SupportedFormat はこのコンプレッサーがサポートするフォーマットを返します。
-func (j *JPEGCompressor) SupportedFormat() string {
-	return "jpeg"
-}
+// Stage はエラーが発生した処理段階を表します。
+type Stage string
 
-// validateQuality は品質パラメータが有効な範囲（0-100）に収まるように調整します。
-func (j *JPEGCompressor) validateQuality(quality int) int {
-	if quality < 0 {
-		return 0
-	}
-	if quality > 100 {
-		return 100
-	}
-	return quality
-}
+const (
+	StageDecode    Stage = "decode"    // 入力データのデコード
+	StageTransform Stage = "transform" // 画像の変換
+	StageEncode    Stage = "encode"    // 圧縮データのエンコード
+)
 
 // CompressError は圧縮処理で発生したエラーを表します。
 type CompressError struct {
 	OriginalErr error
 	Format      string
+	Stage       Stage
 	Message     string
 }
 
@@ -128,3 +98,8 @@ func (e *CompressError) Error() string {
 	}
 	return fmt.Sprintf("圧縮エラー(%s): %v", e.Format, e.OriginalErr)
 }
+
+// Unwrap は元のエラーを返します。
+func (e *CompressError) Unwrap() error {
+	return e.OriginalErr
+}
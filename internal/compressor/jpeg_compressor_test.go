@@ -2,6 +2,7 @@ package compressor
 
 import (
 	"bytes"
+	"errors"
 	"image"
 	"image/color"
 	"image/draw"
@@ -23,8 +24,8 @@ func TestJPEGCompressor_Compress(t *testing.T) {
 		{"高品質", 90, false},
 		{"中品質", 50, false},
 		{"低品質", 10, false},
-		{"品質超過", 101, false}, // validateQualityで100に調整される
-		{"品質不足", -10, false}, // validateQualityで0に調整される
+		{"品質超過", 101, true},
+		{"品質不足", -10, true},
 	}
 
 	for _, tt := range tests {
@@ -169,20 +170,23 @@ func TestJPEGCompressor_SupportedFormat(t *testing.T) {
 func TestJPEGCompressor_validateQuality(t *testing.T) {
 	compressor := NewJPEGCompressor()
 	tests := []struct {
-		input int
-		want  int
+		input   int
+		wantErr bool
 	}{
-		{50, 50},   // 通常の値はそのまま
-		{0, 0},     // 最小値
-		{100, 100}, // 最大値
-		{-10, 0},   // 下限を下回る場合は0に
-		{110, 100}, // 上限を超える場合は100に
+		{50, false},  // 通常の値
+		{0, false},   // 最小値
+		{100, false}, // 最大値
+		{-10, true},  // 下限を下回る場合はエラー
+		{110, true},  // 上限を超える場合はエラー
 	}
 
 	for _, tt := range tests {
-		got := compressor.validateQuality(tt.input)
-		if got != tt.want {
-			t.Errorf("validateQuality(%d) = %d, want %d", tt.input, got, tt.want)
+		err := compressor.validateQuality(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateQuality(%d) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidQuality) {
+			t.Errorf("validateQuality(%d) = %v, ErrInvalidQuality ではありません", tt.input, err)
 		}
 	}
 }
@@ -194,3 +198,36 @@ func createTestImage(width, height int) image.Image {
 	draw.Draw(img, img.Bounds(), &image.Uniform{blue}, image.Point{}, draw.Src)
 	return img
 }
+
+func BenchmarkJPEGCompressor_CompressBytes(b *testing.B) {
+	var input bytes.Buffer
+	if err := jpeg.Encode(&input, createTestImage(1024, 768), &jpeg.Options{Quality: 90}); err != nil {
+		b.Fatal(err)
+	}
+	data := input.Bytes()
+
+	// プールを使用しない従来の処理（毎回バッファを確保して拡張する）
+	b.Run("プールなし", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			img, err := jpeg.Decode(bytes.NewReader(data))
+			if err != nil {
+				b.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("プールあり", func(b *testing.B) {
+		compressor := NewJPEGCompressor()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := compressor.CompressBytes(data, Options{Quality: 80}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
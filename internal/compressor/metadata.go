@@ -0,0 +1,81 @@
+package compressor
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// HasMetadata は画像データにEXIF・XMP・ICCプロファイル・テキストなどの
+// メタデータが含まれるかどうかを判定します。
+// 組み込みのエンコーダーはメタデータを出力しないため、圧縮時に削除されるかどうかの判定に使用します。
+// 対応していない形式では false を返します。
+func HasMetadata(format string, data []byte) bool {
+	switch format {
+	case "jpeg", "jpg":
+		return jpegHasMetadata(data)
+	case "png":
+		return pngHasMetadata(data)
+	case "webp":
+		return webpHasMetadata(data)
+	default:
+		return false
+	}
+}
+
+// jpegHasMetadata はJPEGのセグメントからメタデータを探します。
+func jpegHasMetadata(data []byte) bool {
+	// SOIマーカーの後からセグメントを走査
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return false
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xDA: // SOS: 以降は画像データ
+			return false
+		case marker == 0xE1, marker == 0xE2, marker == 0xED, marker == 0xFE: // EXIF/XMP, ICC, IPTC, コメント
+			return true
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		i += 2 + length
+	}
+	return false
+}
+
+// pngHasMetadata はPNGのチャンクからメタデータを探します。
+func pngHasMetadata(data []byte) bool {
+	metadataChunks := []string{"tEXt", "zTXt", "iTXt", "eXIf", "iCCP", "tIME"}
+
+	// シグネチャ（8バイト）の後からチャンクを走査
+	for i := 8; i+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		chunkType := string(data[i+4 : i+8])
+		for _, t := range metadataChunks {
+			if chunkType == t {
+				return true
+			}
+		}
+		if chunkType == "IEND" || length < 0 {
+			return false
+		}
+		i += 12 + length // 長さ + 種類 + データ + CRC
+	}
+	return false
+}
+
+// webpHasMetadata はWebPのRIFFチャンクからメタデータを探します。
+func webpHasMetadata(data []byte) bool {
+	// RIFFヘッダー（12バイト）の後からチャンクを走査
+	for i := 12; i+8 <= len(data); {
+		fourCC := data[i : i+4]
+		if bytes.Equal(fourCC, []byte("EXIF")) || bytes.Equal(fourCC, []byte("XMP ")) || bytes.Equal(fourCC, []byte("ICCP")) {
+			return true
+		}
+		size := int(binary.LittleEndian.Uint32(data[i+4 : i+8]))
+		if size < 0 {
+			return false
+		}
+		i += 8 + size + size%2 // チャンクは偶数バイトに揃えられる
+	}
+	return false
+}
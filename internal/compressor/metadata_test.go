@@ -0,0 +1,78 @@
+package compressor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/gen2brain/webp"
+)
+
+// insertJPEGSegment はSOIの直後にセグメントを挿入します。
+func insertJPEGSegment(data []byte, marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	result := append([]byte{}, data[:2]...)
+	result = append(result, segment...)
+	return append(result, data[2:]...)
+}
+
+// insertPNGChunk はIHDRチャンクの直後にチャンクを挿入します。
+func insertPNGChunk(data []byte, chunkType string, payload []byte) []byte {
+	chunk := make([]byte, 4)
+	binary.BigEndian.PutUint32(chunk, uint32(len(payload)))
+	body := append([]byte(chunkType), payload...)
+	chunk = append(chunk, body...)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(body))
+	chunk = append(chunk, crc...)
+
+	// シグネチャ(8) + IHDRチャンク(25)
+	result := append([]byte{}, data[:33]...)
+	result = append(result, chunk...)
+	return append(result, data[33:]...)
+}
+
+func TestHasMetadata(t *testing.T) {
+	img := createTestImage(20, 20)
+
+	var jpegBuf, pngBuf, webpBuf bytes.Buffer
+	if err := jpeg.Encode(&jpegBuf, img, nil); err != nil {
+		t.Fatalf("Failed to create test JPEG data: %v", err)
+	}
+	if err := png.Encode(&pngBuf, img); err != nil {
+		t.Fatalf("Failed to create test PNG data: %v", err)
+	}
+	if err := webp.Encode(&webpBuf, img, webp.Options{Quality: 80}); err != nil {
+		t.Fatalf("Failed to create test WebP data: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		format   string
+		data     []byte
+		expected bool
+	}{
+		{"JPEG（メタデータなし）", "jpeg", jpegBuf.Bytes(), false},
+		{"JPEG（EXIFあり）", "jpeg", insertJPEGSegment(jpegBuf.Bytes(), 0xE1, []byte("Exif\x00\x00")), true},
+		{"JPEG（コメントあり）", "jpg", insertJPEGSegment(jpegBuf.Bytes(), 0xFE, []byte("comment")), true},
+		{"PNG（メタデータなし）", "png", pngBuf.Bytes(), false},
+		{"PNG（tEXtあり）", "png", insertPNGChunk(pngBuf.Bytes(), "tEXt", []byte("Author\x00shuku")), true},
+		{"WebP（メタデータなし）", "webp", webpBuf.Bytes(), false},
+		{"未対応の形式", "bmp", []byte("BM"), false},
+		{"壊れたデータ", "jpeg", []byte{0xFF, 0xD8, 0xFF}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasMetadata(tt.format, tt.data); got != tt.expected {
+				t.Errorf("HasMetadata() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
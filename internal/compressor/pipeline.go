@@ -0,0 +1,102 @@
+package compressor
+
+import (
+	"bytes"
+	"image"
+	"io"
+)
+
+// Pipeline はデコード・変換・エンコードの各段階を順に実行するコンプレッサーです。
+// 形式ごとの処理は Decoder と Encoder だけを実装し、
+// リサイズや透過の合成などの前処理は Transform として組み合わせます。
+type Pipeline struct {
+	format     string
+	decoder    Decoder
+	encoder    Encoder
+	transforms []Transform
+}
+
+// NewPipeline は新しい Pipeline を作成します。
+// transforms はデコード後、エンコード前に指定した順で適用されます。
+func NewPipeline(format string, decoder Decoder, encoder Encoder, transforms ...Transform) *Pipeline {
+	return &Pipeline{
+		format:     format,
+		decoder:    decoder,
+		encoder:    encoder,
+		transforms: transforms,
+	}
+}
+
+// Compress は画像に変換を適用してエンコードし、その結果を再デコードした画像を返します。
+func (p *Pipeline) Compress(img image.Image, options Options) (image.Image, error) {
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+	if err := p.Encode(buf, img, options); err != nil {
+		return nil, err
+	}
+	return p.Decode(buf)
+}
+
+// CompressBytes はバイトスライスの画像データをデコード・変換・エンコードし、結果を返します。
+func (p *Pipeline) CompressBytes(data []byte, options Options) ([]byte, error) {
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+	if err := p.CompressReader(bytes.NewReader(data), buf, options); err != nil {
+		return nil, err
+	}
+	// バッファはプールに戻すため、結果は呼び出し側が所有するスライスに複製する
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// CompressReader はリーダーの画像データをデコード・変換・エンコードし、ライターに書き込みます。
+func (p *Pipeline) CompressReader(r io.Reader, w io.Writer, options Options) error {
+	img, err := p.Decode(r)
+	if err != nil {
+		return err
+	}
+	return p.Encode(w, img, options)
+}
+
+// Decode はリーダーから画像をデコードします。
+func (p *Pipeline) Decode(r io.Reader) (image.Image, error) {
+	return p.decoder.Decode(r)
+}
+
+// Encode は画像に変換を順に適用したあと、エンコードしてライターに書き込みます。
+// 組み込みの変換が作成する中間画像の画素バッファはプールから確保され、エンコード後に再利用されます。
+func (p *Pipeline) Encode(w io.Writer, img image.Image, options Options) error {
+	if len(p.transforms) == 0 {
+		return p.encoder.Encode(w, img, options)
+	}
+
+	s := &scratch{}
+	defer s.release()
+	options.scratch = s
+
+	img, err := p.Transform(img, options)
+	if err != nil {
+		return err
+	}
+	return p.encoder.Encode(w, img, options)
+}
+
+// Transform は登録された変換を順に適用した画像を返します。
+func (p *Pipeline) Transform(img image.Image, options Options) (image.Image, error) {
+	for _, transform := range p.transforms {
+		var err error
+		img, err = transform(img, options)
+		if err != nil {
+			return nil, &CompressError{
+				OriginalErr: err,
+				Format:      p.format,
+				Stage:       StageTransform,
+			}
+		}
+	}
+	return img, nil
+}
+
+// SupportedFormat はこのパイプラインが扱う画像形式を返します。
+func (p *Pipeline) SupportedFormat() string {
+	return p.format
+}
@@ -0,0 +1,124 @@
+package compressor
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// 呼び出された変換の名前を記録する変換を作成する
+func recordingTransform(name string, calls *[]string) Transform {
+	return func(img image.Image, options Options) (image.Image, error) {
+		*calls = append(*calls, name)
+		return img, nil
+	}
+}
+
+func TestPipeline_TransformOrder(t *testing.T) {
+	var calls []string
+	compressor := NewPNGCompressor(
+		recordingTransform("first", &calls),
+		recordingTransform("second", &calls),
+		recordingTransform("third", &calls),
+	)
+
+	var input bytes.Buffer
+	if err := png.Encode(&input, createTestImage(20, 20)); err != nil {
+		t.Fatalf("Failed to create test PNG data: %v", err)
+	}
+
+	if _, err := compressor.CompressBytes(input.Bytes(), Options{}); err != nil {
+		t.Fatalf("CompressBytes() error = %v", err)
+	}
+
+	want := []string{"first", "second", "third"}
+	if len(calls) != len(want) {
+		t.Fatalf("変換の呼び出し = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("変換の呼び出し順 = %v, want %v", calls, want)
+			break
+		}
+	}
+}
+
+func TestPipeline_Stages(t *testing.T) {
+	var stages []string
+	decoder := DecoderFunc(func(r io.Reader) (image.Image, error) {
+		stages = append(stages, "decode")
+		return png.Decode(r)
+	})
+	encoder := EncoderFunc(func(w io.Writer, img image.Image, options Options) error {
+		stages = append(stages, "encode")
+		return png.Encode(w, img)
+	})
+	pipeline := NewPipeline("test", decoder, encoder, recordingTransform("transform", &stages))
+
+	var input, output bytes.Buffer
+	if err := png.Encode(&input, createTestImage(20, 20)); err != nil {
+		t.Fatalf("Failed to create test PNG data: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		run  func() error
+		want []string
+	}{
+		{
+			name: "CompressReader",
+			run:  func() error { return pipeline.CompressReader(bytes.NewReader(input.Bytes()), &output, Options{}) },
+			want: []string{"decode", "transform", "encode"},
+		},
+		{
+			name: "Compress",
+			run: func() error {
+				_, err := pipeline.Compress(createTestImage(20, 20), Options{})
+				return err
+			},
+			want: []string{"transform", "encode", "decode"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stages = nil
+			if err := tt.run(); err != nil {
+				t.Fatalf("%s() error = %v", tt.name, err)
+			}
+			if len(stages) != len(tt.want) {
+				t.Fatalf("実行された段階 = %v, want %v", stages, tt.want)
+			}
+			for i := range tt.want {
+				if stages[i] != tt.want[i] {
+					t.Errorf("実行された段階 = %v, want %v", stages, tt.want)
+					break
+				}
+			}
+		})
+	}
+
+	if pipeline.SupportedFormat() != "test" {
+		t.Errorf("SupportedFormat() = %v, want %v", pipeline.SupportedFormat(), "test")
+	}
+}
+
+func TestPipeline_TransformError(t *testing.T) {
+	transformErr := errors.New("変換エラー")
+	compressor := NewJPEGCompressor(func(img image.Image, options Options) (image.Image, error) {
+		return nil, transformErr
+	})
+
+	_, err := compressor.Compress(createTestImage(20, 20), Options{Quality: 80})
+	if !errors.Is(err, transformErr) {
+		t.Fatalf("Compress() error = %v, want %v", err, transformErr)
+	}
+
+	var compressErr *CompressError
+	if !errors.As(err, &compressErr) || compressErr.Stage != StageTransform {
+		t.Errorf("変換エラーの処理段階が記録されていません: %v", err)
+	}
+}
@@ -1,96 +1,73 @@
 package compressor
 
 import (
-	"bytes"
 	"image"
 	"image/png"
 	"io"
+	"sync"
 )
 
-// PNGCompressor はPNG形式の画像を圧縮するための実装です。
-// パレットサイズを調整することで圧縮率を制御します。
-type PNGCompressor struct{}
+// pngEncoder は圧縮用の内部バッファを呼び出し間で再利用するPNGエンコーダーです。
+var pngEncoder = &png.Encoder{BufferPool: &pngBufferPool{}}
 
-// NewPNGCompressor は新しいPNGCompressorインスタンスを作成します。
-func NewPNGCompressor() *PNGCompressor {
-	return &PNGCompressor{}
+// pngBufferPool は png.EncoderBufferPool を sync.Pool で実装します。並行に使用しても安全です。
+type pngBufferPool struct {
+	pool sync.Pool
 }
 
-// Compress はPNG画像を圧縮します。
-// options.PaletteSizeはパレットサイズを指定します。
-func (p *PNGCompressor) Compress(img image.Image, options Options) (image.Image, error) {
-	// PNG圧縮を適用したバイトデータを取得
-	var buf bytes.Buffer
-	err := png.Encode(&buf, img)
-	if err != nil {
-		return nil, &CompressError{
-			OriginalErr: err,
-			Format:      "PNG",
-		}
-	}
-
-	// 圧縮されたバイトデータを画像として再デコード
-	compressed, err := png.Decode(&buf)
-	if err != nil {
-		return nil, &CompressError{
-			OriginalErr: err,
-			Format:      "PNG",
-		}
-	}
-
-	return compressed, nil
+func (p *pngBufferPool) Get() *png.EncoderBuffer {
+	buf, _ := p.pool.Get().(*png.EncoderBuffer)
+	return buf
 }
 
-// CompressBytes はバイト配列として提供されたPNG画像データを圧縮します。
-func (p *PNGCompressor) CompressBytes(data []byte, options Options) ([]byte, error) {
-	// 入力データが有効なPNG画像であることを確認
-	img, err := png.Decode(bytes.NewReader(data))
-	if err != nil {
-		return nil, &CompressError{
-			OriginalErr: err,
-			Format:      "PNG",
-			Message:     "入力データが有効なPNG画像ではありません",
-		}
-	}
+func (p *pngBufferPool) Put(buf *png.EncoderBuffer) {
+	p.pool.Put(buf)
+}
 
-	// 圧縮を適用
-	var buf bytes.Buffer
-	err = png.Encode(&buf, img)
-	if err != nil {
-		return nil, &CompressError{
-			OriginalErr: err,
-			Format:      "PNG",
-		}
-	}
+// PNGCompressor はPNG形式の画像を圧縮するための実装です。
+// パレットサイズを調整することで圧縮率を制御します。
+// Compress・CompressBytes・CompressReader は Pipeline によるデコード・変換・エンコードで実行されます。
+type PNGCompressor struct {
+	*Pipeline
+}
 
-	return buf.Bytes(), nil
+// NewPNGCompressor は新しいPNGCompressorインスタンスを作成します。
+// transforms はデコード後、エンコード前に指定した順で適用されます。
+func NewPNGCompressor(transforms ...Transform) *PNGCompressor {
+	p := &PNGCompressor{}
+	p.Pipeline = NewPipeline("png", DecoderFunc(p.decode), EncoderFunc(p.encode), transforms...)
+	return p
 }
 
-// CompressReader はリーダーから読み取ったPNG画像データを圧縮し、ライターに書き込みます。
-func (p *PNGCompressor) CompressReader(r io.Reader, w io.Writer, options Options) error {
-	// 入力データが有効なPNG画像であることを確認
+// decode はリーダーからPNG画像をデコードします。
+func (p *PNGCompressor) decode(r io.Reader) (image.Image, error) {
 	img, err := png.Decode(r)
 	if err != nil {
-		return &CompressError{
+		return nil, &CompressError{
 			OriginalErr: err,
 			Format:      "PNG",
+			Stage:       StageDecode,
 			Message:     "入力データが有効なPNG画像ではありません",
 		}
 	}
+	return img, nil
+}
 
-	// 圧縮を適用して結果をライターに書き込む
-	err = png.Encode(w, img)
+// encode は画像をPNG形式でエンコードし、ライターに書き込みます。
+func (p *PNGCompressor) encode(w io.Writer, img image.Image, options Options) error {
+	err := pngEncoder.Encode(w, img)
 	if err != nil {
 		return &CompressError{
 			OriginalErr: err,
 			Format:      "PNG",
+			Stage:       StageEncode,
 		}
 	}
-
 	return nil
 }
 
-// SupportedFormat はこのコンプレッサーがサポートするフォーマットを返します。
-func (p *PNGCompressor) SupportedFormat() string {
-	return "png"
+// EffectiveOptions はエンコード時に実際に適用されるオプションを返します。
+func (p *PNGCompressor) EffectiveOptions(options Options) Options {
+	// パレットサイズは現在エンコードに反映されないため、何も適用されません
+	return Options{}
 }
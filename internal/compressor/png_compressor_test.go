@@ -167,3 +167,36 @@ func TestPNGCompressor_SupportedFormat(t *testing.T) {
 }
 
 // Note: createTestImage関数はjpeg_compressor_test.goで定義されているため、ここでは定義しない
+
+func BenchmarkPNGCompressor_CompressBytes(b *testing.B) {
+	var input bytes.Buffer
+	if err := png.Encode(&input, createTestImage(1024, 768)); err != nil {
+		b.Fatal(err)
+	}
+	data := input.Bytes()
+
+	// プールを使用しない従来の処理（毎回バッファを確保して拡張する）
+	b.Run("プールなし", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			img, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				b.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, img); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("プールあり", func(b *testing.B) {
+		compressor := NewPNGCompressor()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := compressor.CompressBytes(data, Options{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
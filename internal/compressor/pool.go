@@ -0,0 +1,109 @@
+package compressor
+
+import (
+	"bytes"
+	"image"
+	"sync"
+)
+
+// プールに戻すバッファ・画素バッファの上限サイズ
+// これより大きいものは保持し続けるとメモリを圧迫するため、プールに戻さずに破棄します。
+const (
+	maxPooledBufferSize = 32 << 20 // 32MiB
+	maxPooledPixelSize  = 64 << 20 // 64MiB（約4000x4000のRGBA）
+)
+
+// bufferPool はエンコード結果を書き込むバッファのプールです。
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// GetBuffer はプールから空のバッファを取得します。
+// 使用後は PutBuffer でプールに戻してください。並行に使用しても安全です。
+func GetBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// PutBuffer はバッファをプールに戻します。
+// 戻したあとはバッファ・buf.Bytes() で取得したスライスを使用しないでください。
+func PutBuffer(buf *bytes.Buffer) {
+	if buf == nil || buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// pixelPool は変換処理で使用する画素バッファのプールです。
+var pixelPool sync.Pool
+
+// getPixels は長さ n の画素バッファを取得します。
+// プールのバッファを再利用した場合、内容は初期化されていません。
+func getPixels(n int) []uint8 {
+	if p, ok := pixelPool.Get().(*[]uint8); ok {
+		if cap(*p) >= n {
+			return (*p)[:n]
+		}
+	}
+	return make([]uint8, n)
+}
+
+// putPixels は画素バッファをプールに戻します。
+func putPixels(pix []uint8) {
+	if cap(pix) == 0 || cap(pix) > maxPooledPixelSize {
+		return
+	}
+	pixelPool.Put(&pix)
+}
+
+// scratch は1回のエンコードの間だけ使用する画素バッファを管理します。
+// Pipeline は変換の中間画像をここから確保し、エンコードの完了後にまとめてプールに戻します。
+type scratch struct {
+	mu  sync.Mutex
+	pix [][]uint8
+}
+
+// newRGBA は r の範囲の RGBA 画像を確保します。
+// s が nil の場合（Pipeline の外で変換を使用した場合）は通常どおり新しく確保します。
+// 返される画像の内容は初期化されていないため、呼び出し側ですべての画素を書き込む必要があります。
+func (s *scratch) newRGBA(r image.Rectangle) *image.RGBA {
+	if s == nil {
+		return image.NewRGBA(r)
+	}
+
+	pix := getPixels(4 * r.Dx() * r.Dy())
+	s.mu.Lock()
+	s.pix = append(s.pix, pix)
+	s.mu.Unlock()
+	return &image.RGBA{Pix: pix, Stride: 4 * r.Dx(), Rect: r}
+}
+
+// release は確保したすべての画素バッファをプールに戻します。
+func (s *scratch) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, pix := range s.pix {
+		putPixels(pix)
+	}
+	s.pix = nil
+}
+
+// TransformPooled は transforms を順に適用し、中間画像の画素バッファをプールから確保します。
+// 返された画像は release を呼び出すまで有効で、release のあとは使用できません。
+// 組み込みの変換（FlattenAlpha・Resize）以外の変換は通常どおり画像を確保します。
+func TransformPooled(img image.Image, options Options, transforms ...Transform) (image.Image, func(), error) {
+	s := &scratch{}
+	options.scratch = s
+	for _, transform := range transforms {
+		var err error
+		img, err = transform(img, options)
+		if err != nil {
+			s.release()
+			return nil, func() {}, err
+		}
+	}
+	return img, s.release, nil
+}
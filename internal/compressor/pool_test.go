@@ -0,0 +1,126 @@
+package compressor
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"sync"
+	"testing"
+)
+
+func TestGetBuffer(t *testing.T) {
+	buf := GetBuffer()
+	buf.WriteString("前回の内容")
+	PutBuffer(buf)
+
+	// 再利用されたバッファも空の状態で返される
+	if got := GetBuffer(); got.Len() != 0 {
+		t.Errorf("GetBuffer().Len() = %d, want 0", got.Len())
+	}
+
+	// 上限を超えるバッファはプールに戻さない（パニックしないことを確認）
+	large := bytes.NewBuffer(make([]byte, 0, maxPooledBufferSize+1))
+	PutBuffer(large)
+	PutBuffer(nil)
+}
+
+func TestCompressBytesResultNotShared(t *testing.T) {
+	var input bytes.Buffer
+	if err := png.Encode(&input, createTestImage(50, 50)); err != nil {
+		t.Fatalf("Failed to create test PNG data: %v", err)
+	}
+	compressor := NewPNGCompressor()
+
+	first, err := compressor.CompressBytes(input.Bytes(), Options{})
+	if err != nil {
+		t.Fatalf("CompressBytes() error = %v", err)
+	}
+	snapshot := bytes.Clone(first)
+
+	// 続けて圧縮しても、先に返した結果がプールのバッファで上書きされない
+	for i := 0; i < 5; i++ {
+		if _, err := compressor.CompressBytes(input.Bytes(), Options{}); err != nil {
+			t.Fatalf("CompressBytes() error = %v", err)
+		}
+	}
+	if !bytes.Equal(first, snapshot) {
+		t.Error("CompressBytes() の結果が後続の呼び出しで変更されました")
+	}
+}
+
+func TestTransformPooled(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	white := color.RGBA{255, 255, 255, 255}
+
+	transformed, release, err := TransformPooled(img, Options{}, FlattenAlpha(white), Resize(20, 0))
+	if err != nil {
+		t.Fatalf("TransformPooled() error = %v", err)
+	}
+	if transformed.Bounds().Dx() != 20 || transformed.Bounds().Dy() != 20 {
+		t.Errorf("TransformPooled() bounds = %v, want 20x20", transformed.Bounds())
+	}
+	if got := color.RGBAModel.Convert(transformed.At(10, 10)); got != white {
+		t.Errorf("TransformPooled() At(10, 10) = %v, want %v", got, white)
+	}
+	release()
+
+	// 変換が失敗した場合も release を呼び出せる
+	_, release, err = TransformPooled(img, Options{}, Resize(-1, 0))
+	if err == nil {
+		t.Error("TransformPooled() 不正な変換でエラーが返されませんでした")
+	}
+	release()
+}
+
+func TestPipelinePooledTransformsConcurrent(t *testing.T) {
+	compressor := NewJPEGCompressor(FlattenAlpha(color.White), Resize(32, 32))
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				compressed, err := compressor.Compress(img, Options{Quality: 80})
+				if err != nil {
+					t.Errorf("Compress() error = %v", err)
+					return
+				}
+				// 透明部分は白で合成される（プールの画素が混ざらない）
+				r, g, b, _ := compressed.At(16, 16).RGBA()
+				if r>>8 < 250 || g>>8 < 250 || b>>8 < 250 {
+					t.Errorf("合成後の色 = (%d, %d, %d), want 白", r>>8, g>>8, b>>8)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkResize(b *testing.B) {
+	img := image.NewNRGBA(image.Rect(0, 0, 1024, 768))
+
+	b.Run("プールなし", func(b *testing.B) {
+		resize := Resize(256, 0)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := resize(img, Options{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("プールあり", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, release, err := TransformPooled(img, Options{}, Resize(256, 0))
+			if err != nil {
+				b.Fatal(err)
+			}
+			release()
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package compressor
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Transform はデコードとエンコードの間で画像に適用する変換です。
+// 変換後の画像を返し、入力の画像は変更しません。
+type Transform func(img image.Image, options Options) (image.Image, error)
+
+// FlattenAlpha は透過を含む画像を背景色 bg の上に合成し、不透明な画像に変換します。
+// 透過を扱えない形式（JPEGなど）で、透明部分が黒く潰れるのを防ぎます。
+// 既に不透明な画像はそのまま返します。
+func FlattenAlpha(bg color.Color) Transform {
+	return func(img image.Image, options Options) (image.Image, error) {
+		if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+			return img, nil
+		}
+
+		bounds := img.Bounds()
+		flattened := options.scratch.newRGBA(bounds)
+		draw.Draw(flattened, bounds, image.NewUniform(bg), image.Point{}, draw.Src)
+		draw.Draw(flattened, bounds, img, bounds.Min, draw.Over)
+		return flattened, nil
+	}
+}
+
+// Resize は画像が maxWidth×maxHeight に収まるよう、縦横比を保って縮小します。
+// 0 を指定した辺は制限しません。元の画像が既に収まる場合は拡大せずにそのまま返します。
+// 縮小には面積平均法を使用します。
+func Resize(maxWidth, maxHeight int) Transform {
+	return func(img image.Image, options Options) (image.Image, error) {
+		if maxWidth < 0 || maxHeight < 0 {
+			return nil, fmt.Errorf("リサイズ後のサイズが不正です: %dx%d", maxWidth, maxHeight)
+		}
+
+		bounds := img.Bounds()
+		width, height := FitSize(bounds.Dx(), bounds.Dy(), maxWidth, maxHeight)
+		if width == bounds.Dx() && height == bounds.Dy() {
+			return img, nil
+		}
+		return resizeArea(img, width, height, options.scratch), nil
+	}
+}
+
+// FitSize は width×height の画像を maxWidth×maxHeight に収めたときのサイズを返します。
+// 0 を指定した辺は制限しません。拡大は行いません。
+func FitSize(width, height, maxWidth, maxHeight int) (int, int) {
+	scale := 1.0
+	if maxWidth > 0 && width > maxWidth {
+		scale = min(scale, float64(maxWidth)/float64(width))
+	}
+	if maxHeight > 0 && height > maxHeight {
+		scale = min(scale, float64(maxHeight)/float64(height))
+	}
+	if scale >= 1 {
+		return width, height
+	}
+	return max(1, int(math.Round(float64(width)*scale))), max(1, int(math.Round(float64(height)*scale)))
+}
+
+// resizeArea は縮小先の各画素に対応する範囲の画素を平均して、画像を width×height に縮小します。
+// 縮小先の画像は s から確保します。
+func resizeArea(img image.Image, width, height int, s *scratch) *image.RGBA {
+	src, ok := img.(*image.RGBA)
+	if !ok {
+		// 乗算済みアルファのRGBAに変換してから平均する（変換用の画素バッファは縮小後に再利用する）
+		bounds := img.Bounds()
+		pix := getPixels(4 * bounds.Dx() * bounds.Dy())
+		defer putPixels(pix)
+		src = &image.RGBA{Pix: pix, Stride: 4 * bounds.Dx(), Rect: bounds}
+		draw.Draw(src, bounds, img, bounds.Min, draw.Src)
+	}
+
+	sb := src.Bounds()
+	srcWidth, srcHeight := sb.Dx(), sb.Dy()
+	dst := s.newRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		sy0 := y * srcHeight / height
+		sy1 := max((y+1)*srcHeight/height, sy0+1)
+		for x := 0; x < width; x++ {
+			sx0 := x * srcWidth / width
+			sx1 := max((x+1)*srcWidth/width, sx0+1)
+
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				offset := src.PixOffset(sb.Min.X+sx0, sb.Min.Y+sy)
+				for sx := sx0; sx < sx1; sx++ {
+					r += uint64(src.Pix[offset])
+					g += uint64(src.Pix[offset+1])
+					b += uint64(src.Pix[offset+2])
+					a += uint64(src.Pix[offset+3])
+					offset += 4
+					n++
+				}
+			}
+
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset] = uint8(r / n)
+			dst.Pix[offset+1] = uint8(g / n)
+			dst.Pix[offset+2] = uint8(b / n)
+			dst.Pix[offset+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,131 @@
+package compressor
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+func TestFlattenAlpha(t *testing.T) {
+	white := color.RGBA{255, 255, 255, 255}
+
+	t.Run("透明部分を背景色で合成する", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+		img.Set(0, 0, color.NRGBA{255, 0, 0, 255})
+		// (1,1) は完全な透明のまま
+
+		flattened, err := FlattenAlpha(white)(img, Options{})
+		if err != nil {
+			t.Fatalf("FlattenAlpha() error = %v", err)
+		}
+
+		tests := []struct {
+			name string
+			x, y int
+			want color.RGBA
+		}{
+			{"不透明な画素", 0, 0, color.RGBA{255, 0, 0, 255}},
+			{"透明な画素", 1, 1, white},
+		}
+		for _, tt := range tests {
+			got := color.RGBAModel.Convert(flattened.At(tt.x, tt.y)).(color.RGBA)
+			if got != tt.want {
+				t.Errorf("%s: At(%d, %d) = %v, want %v", tt.name, tt.x, tt.y, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("不透明な画像はそのまま返す", func(t *testing.T) {
+		img := createTestImage(4, 4)
+		flattened, err := FlattenAlpha(white)(img, Options{})
+		if err != nil {
+			t.Fatalf("FlattenAlpha() error = %v", err)
+		}
+		if flattened != img {
+			t.Error("不透明な画像が複製されました")
+		}
+	})
+
+	t.Run("JPEGのパイプラインに組み込む", func(t *testing.T) {
+		compressor := NewJPEGCompressor(FlattenAlpha(white))
+		img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+
+		var buf bytes.Buffer
+		if err := compressor.Encode(&buf, img, Options{Quality: 90}); err != nil {
+			t.Fatalf("Encode() error = %v", err)
+		}
+		decoded, err := jpeg.Decode(&buf)
+		if err != nil {
+			t.Fatalf("jpeg.Decode() error = %v", err)
+		}
+
+		r, g, b, _ := decoded.At(8, 8).RGBA()
+		if r>>8 < 250 || g>>8 < 250 || b>>8 < 250 {
+			t.Errorf("透明部分が背景色になっていません: (%d, %d, %d)", r>>8, g>>8, b>>8)
+		}
+	})
+}
+
+func TestFitSize(t *testing.T) {
+	tests := []struct {
+		name                string
+		width, height       int
+		maxWidth, maxHeight int
+		wantWidth           int
+		wantHeight          int
+	}{
+		{"幅の制限", 400, 200, 100, 0, 100, 50},
+		{"高さの制限", 400, 200, 0, 50, 100, 50},
+		{"両方の制限（高さが優先）", 400, 200, 300, 50, 100, 50},
+		{"制限なし", 400, 200, 0, 0, 400, 200},
+		{"拡大しない", 40, 20, 100, 100, 40, 20},
+		{"最小1ピクセル", 1000, 1, 10, 0, 10, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, h := FitSize(tt.width, tt.height, tt.maxWidth, tt.maxHeight)
+			if w != tt.wantWidth || h != tt.wantHeight {
+				t.Errorf("FitSize() = %dx%d, want %dx%d", w, h, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestResize(t *testing.T) {
+	t.Run("縦横比を保って縮小する", func(t *testing.T) {
+		img := createTestImage(300, 200)
+		resized, err := Resize(150, 0)(img, Options{})
+		if err != nil {
+			t.Fatalf("Resize() error = %v", err)
+		}
+		if resized.Bounds().Dx() != 150 || resized.Bounds().Dy() != 100 {
+			t.Errorf("Resize() bounds = %v, want 150x100", resized.Bounds())
+		}
+
+		// 単色画像は縮小後も同じ色になる
+		got := color.RGBAModel.Convert(resized.At(75, 50)).(color.RGBA)
+		if got != (color.RGBA{0, 0, 255, 255}) {
+			t.Errorf("縮小後の色 = %v, want %v", got, color.RGBA{0, 0, 255, 255})
+		}
+	})
+
+	t.Run("収まる画像はそのまま返す", func(t *testing.T) {
+		img := createTestImage(30, 20)
+		resized, err := Resize(100, 100)(img, Options{})
+		if err != nil {
+			t.Fatalf("Resize() error = %v", err)
+		}
+		if resized != img {
+			t.Error("収まる画像が複製されました")
+		}
+	})
+
+	t.Run("負のサイズ", func(t *testing.T) {
+		if _, err := Resize(-1, 0)(createTestImage(10, 10), Options{}); err == nil {
+			t.Error("負のサイズでエラーが返されませんでした")
+		}
+	})
+}
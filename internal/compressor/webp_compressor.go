@@ -1,7 +1,7 @@
 package compressor
 
 import (
-	"bytes"
+	"fmt"
 	"image"
 	"io"
 
@@ -10,112 +10,67 @@ import (
 
 // WebPCompressor はWebP形式の画像を圧縮するための実装です。
 // WebP品質設定を調整することで圧縮率を制御します。
-type WebPCompressor struct{}
-
-// NewWebPCompressor は新しいWebPCompressorインスタンスを作成します。
-func NewWebPCompressor() *WebPCompressor {
-	return &WebPCompressor{}
+// Compress・CompressBytes・CompressReader は Pipeline によるデコード・変換・エンコードで実行されます。
+type WebPCompressor struct {
+	*Pipeline
 }
 
-// Compress はWebP画像を圧縮します。
-// options.Qualityは0-100の値を使用して圧縮品質を指定します。
-// 値が低いほどファイルサイズは小さくなりますが、画質は劣化します。
-func (w *WebPCompressor) Compress(img image.Image, options Options) (image.Image, error) {
-	// WebP圧縮を適用したバイトデータを取得
-	var buf bytes.Buffer
-	quality := w.validateQuality(options.Quality)
-
-	err := webp.Encode(&buf, img, webp.Options{
-		Quality: quality,
-	})
-	if err != nil {
-		return nil, &CompressError{
-			OriginalErr: err,
-			Format:      "WebP",
-		}
-	}
-
-	// 圧縮されたバイトデータを画像として再デコード
-	compressed, err := webp.Decode(&buf)
-	if err != nil {
-		return nil, &CompressError{
-			OriginalErr: err,
-			Format:      "WebP",
-		}
-	}
-
-	return compressed, nil
+// NewWebPCompressor は新しいWebPCompressorインスタンスを作成します。
+// transforms はデコード後、エンコード前に指定した順で適用されます。
+func NewWebPCompressor(transforms ...Transform) *WebPCompressor {
+	w := &WebPCompressor{}
+	w.Pipeline = NewPipeline("webp", DecoderFunc(w.decode), EncoderFunc(w.encode), transforms...)
+	return w
 }
 
-// CompressBytes はバイト配列として提供されたWebP画像データを圧縮します。
-func (w *WebPCompressor) CompressBytes(data []byte, options Options) ([]byte, error) {
-	// 入力データが有効なWebP画像であることを確認
-	img, err := webp.Decode(bytes.NewReader(data))
+// decode はリーダーからWebP画像をデコードします。
+func (w *WebPCompressor) decode(r io.Reader) (image.Image, error) {
+	img, err := webp.Decode(r)
 	if err != nil {
 		return nil, &CompressError{
 			OriginalErr: err,
 			Format:      "WebP",
+			Stage:       StageDecode,
 			Message:     "入力データが有効なWebP画像ではありません",
 		}
 	}
-
-	// 圧縮を適用
-	var buf bytes.Buffer
-	quality := w.validateQuality(options.Quality)
-
-	err = webp.Encode(&buf, img, webp.Options{
-		Quality: quality,
-	})
-	if err != nil {
-		return nil, &CompressError{
-			OriginalErr: err,
-			Format:      "WebP",
-		}
-	}
-
-	return buf.Bytes(), nil
+	return img, nil
 }
 
-// CompressReader はリーダーから読み取ったWebP画像データを圧縮し、ライターに書き込みます。
-func (w *WebPCompressor) CompressReader(r io.Reader, wr io.Writer, options Options) error {
-	// 入力データが有効なWebP画像であることを確認
-	img, err := webp.Decode(r)
-	if err != nil {
-		return &CompressError{
-			OriginalErr: err,
-			Format:      "WebP",
-			Message:     "入力データが有効なWebP画像ではありません",
-		}
+// encode は画像をWebP形式でエンコードし、ライターに書き込みます。
+// options.Qualityは0-100の値を使用して圧縮品質を指定します。
+// 値が低いほどファイルサイズは小さくなりますが、画質は劣化します。
+func (w *WebPCompressor) encode(wr io.Writer, img image.Image, options Options) error {
+	if err := w.validateQuality(options.Quality); err != nil {
+		return err
 	}
-
-	// 圧縮を適用して結果をライターに書き込む
-	quality := w.validateQuality(options.Quality)
-
-	err = webp.Encode(wr, img, webp.Options{
-		Quality: quality,
+	err := webp.Encode(wr, img, webp.Options{
+		Quality: options.Quality,
 	})
 	if err != nil {
 		return &CompressError{
 			OriginalErr: err,
 			Format:      "WebP",
+			Stage:       StageEncode,
 		}
 	}
-
 	return nil
 }
 
-// SupportedFormat はこのコンプレッサーがサポートするフォーマットを返します。
-func (w *WebPCompressor) SupportedFormat() string {
-	return "webp"
+// EffectiveOptions はエンコード時に実際に適用されるオプションを返します。
+func (w *WebPCompressor) EffectiveOptions(options Options) Options {
+	return Options{Quality: options.Quality}
 }
 
-// validateQuality は品質パラメータが有効な範囲（0-100）に収まるように調整します。
-func (w *WebPCompressor) validateQuality(quality int) int {
-	if quality < 0 {
-		return 0
-	}
-	if quality > 100 {
-		return 100
+// validateQuality は品質パラメータが有効な範囲（0-100）かどうかを検証します。
+func (w *WebPCompressor) validateQuality(quality int) error {
+	if quality < 0 || quality > 100 {
+		return &CompressError{
+			OriginalErr: fmt.Errorf("%w: %d", ErrInvalidQuality, quality),
+			Format:      "WebP",
+			Stage:       StageEncode,
+			Message:     "品質は0〜100の範囲で指定してください",
+		}
 	}
-	return quality
+	return nil
 }
@@ -2,6 +2,7 @@ package compressor
 
 import (
 	"bytes"
+	"errors"
 	"image"
 	"image/color"
 	"image/draw"
@@ -42,22 +43,25 @@ func TestWebPCompressor_validateQuality(t *testing.T) {
 	compressor := NewWebPCompressor()
 
 	tests := []struct {
-		name     string
-		input    int
-		expected int
+		name    string
+		input   int
+		wantErr bool
 	}{
-		{"Valid quality", 80, 80},
-		{"Below minimum", -10, 0},
-		{"Above maximum", 110, 100},
-		{"Minimum", 0, 0},
-		{"Maximum", 100, 100},
+		{"Valid quality", 80, false},
+		{"Below minimum", -10, true},
+		{"Above maximum", 110, true},
+		{"Minimum", 0, false},
+		{"Maximum", 100, false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actual := compressor.validateQuality(tt.input)
-			if actual != tt.expected {
-				t.Errorf("Expected %d, got %d", tt.expected, actual)
+			err := compressor.validateQuality(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateQuality(%d) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidQuality) {
+				t.Errorf("validateQuality(%d) = %v, ErrInvalidQuality ではありません", tt.input, err)
 			}
 		})
 	}
@@ -285,3 +289,36 @@ func TestWebPCompressor_Integration(t *testing.T) {
 		t.Errorf("Compressed result is not valid WebP: %v", err)
 	}
 }
+
+func BenchmarkWebPCompressor_CompressBytes(b *testing.B) {
+	var input bytes.Buffer
+	if err := webp.Encode(&input, createTestImage(1024, 768), webp.Options{Quality: 90}); err != nil {
+		b.Fatal(err)
+	}
+	data := input.Bytes()
+
+	// プールを使用しない従来の処理（毎回バッファを確保して拡張する）
+	b.Run("プールなし", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			img, err := webp.Decode(bytes.NewReader(data))
+			if err != nil {
+				b.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := webp.Encode(&buf, img, webp.Options{Quality: 80}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("プールあり", func(b *testing.B) {
+		compressor := NewWebPCompressor()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := compressor.CompressBytes(data, Options{Quality: 80}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,62 @@
+// Package fileutil はファイル書き込みの共通処理を提供します。
+package fileutil
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic は path と同じディレクトリの一時ファイルに write で書き込み、
+// fsync した後に rename で path を置き換えます。
+// write や同期が失敗した場合は一時ファイルを削除するため、既存の path は変更されず、
+// 途中まで書き込まれたファイルも残りません。
+func WriteFileAtomic(path string, perm os.FileMode, write func(w io.Writer) error) (err error) {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	// 失敗した場合は一時ファイルを削除
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if err = write(tmp); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	// rename をディスクに反映するためディレクトリを同期（未対応の環境では無視）
+	if d, dirErr := os.Open(dir); dirErr == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+
+	return nil
+}
+
+// OutputMode は出力ファイルに設定するパーミッションを返します。
+// 出力先が既に存在する場合はそのパーミッションを維持し、存在しない場合は入力ファイルのパーミッションを使用します。
+func OutputMode(inputInfo os.FileInfo, outputPath string) os.FileMode {
+	if outputInfo, err := os.Stat(outputPath); err == nil {
+		return outputInfo.Mode().Perm()
+	}
+	return inputInfo.Mode().Perm()
+}
@@ -0,0 +1,104 @@
+package fileutil
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	t.Run("新規作成", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "output.jpg")
+
+		err := WriteFileAtomic(path, 0640, func(w io.Writer) error {
+			_, err := w.Write([]byte("compressed"))
+			return err
+		})
+		if err != nil {
+			t.Fatalf("WriteFileAtomic() error = %v", err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("出力ファイルの読み込みに失敗しました: %v", err)
+		}
+		if string(data) != "compressed" {
+			t.Errorf("出力内容 = %q, want %q", data, "compressed")
+		}
+
+		info, _ := os.Stat(path)
+		if info.Mode().Perm() != 0640 {
+			t.Errorf("パーミッション = %v, want %v", info.Mode().Perm(), os.FileMode(0640))
+		}
+	})
+
+	t.Run("失敗時は既存ファイルを変更しない", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "output.jpg")
+		if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+		}
+
+		writeErr := errors.New("書き込みエラー")
+		err := WriteFileAtomic(path, 0644, func(w io.Writer) error {
+			_, _ = w.Write([]byte("partial"))
+			return writeErr
+		})
+		if !errors.Is(err, writeErr) {
+			t.Fatalf("WriteFileAtomic() error = %v, want %v", err, writeErr)
+		}
+
+		data, _ := os.ReadFile(path)
+		if string(data) != "original" {
+			t.Errorf("既存ファイルが変更されました: %q", data)
+		}
+
+		// 一時ファイルが残っていないことを確認
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 1 {
+			t.Errorf("一時ファイルが残っています: %v", entries)
+		}
+	})
+
+	t.Run("存在しないディレクトリ", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nonexistent", "output.jpg")
+		err := WriteFileAtomic(path, 0644, func(w io.Writer) error { return nil })
+		if err == nil {
+			t.Error("存在しないディレクトリに対してエラーが発生しませんでした")
+		}
+	})
+}
+
+func TestOutputMode(t *testing.T) {
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "input.jpg")
+	if err := os.WriteFile(inputPath, []byte("input"), 0600); err != nil {
+		t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+	}
+	if err := os.Chmod(inputPath, 0600); err != nil {
+		t.Fatalf("パーミッションの設定に失敗しました: %v", err)
+	}
+	inputInfo, _ := os.Stat(inputPath)
+
+	t.Run("出力先が存在しない場合は入力のパーミッション", func(t *testing.T) {
+		if got := OutputMode(inputInfo, filepath.Join(dir, "new.jpg")); got != 0600 {
+			t.Errorf("OutputMode() = %v, want %v", got, os.FileMode(0600))
+		}
+	})
+
+	t.Run("出力先が存在する場合はそのパーミッション", func(t *testing.T) {
+		outputPath := filepath.Join(dir, "existing.jpg")
+		if err := os.WriteFile(outputPath, []byte("output"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+		}
+		if err := os.Chmod(outputPath, 0644); err != nil {
+			t.Fatalf("パーミッションの設定に失敗しました: %v", err)
+		}
+		if got := OutputMode(inputInfo, outputPath); got != 0644 {
+			t.Errorf("OutputMode() = %v, want %v", got, os.FileMode(0644))
+		}
+	})
+}
@@ -0,0 +1,79 @@
+// Package glob は入力ディレクトリからの相対パスに対するパターンマッチと、
+// gitignore と同じ書式の除外ファイルの解釈を提供します。
+// パスとパターンの区切り文字は OS によらず "/" です。
+package glob
+
+import (
+	"path"
+	"strings"
+)
+
+// Match は name（"/" 区切りの相対パス）が pattern に一致するかどうかを返します。
+//
+// pattern に "/" を含まない場合はファイル名（最後の要素）のみと比較するため、"*.jpg" は
+// どの階層の JPEG にも一致します。"/" を含む場合は name 全体と先頭から比較し、先頭の "/" は無視します。
+// 各要素の比較は path.Match と同じで、要素全体が "**" の場合は0個以上の要素に一致します
+// （"vendor/**" は vendor 以下のすべてのファイル、"**/icons/*.png" は任意の階層の icons 内の PNG に一致します）。
+// ignoreCase が true の場合は大文字と小文字を区別しません。
+// pattern の書式が不正な場合は path.ErrBadPattern を返します。
+func Match(pattern, name string, ignoreCase bool) (bool, error) {
+	if err := Validate(pattern); err != nil {
+		return false, err
+	}
+	if ignoreCase {
+		pattern = strings.ToLower(pattern)
+		name = strings.ToLower(name)
+	}
+
+	name = strings.Trim(name, "/")
+	if !strings.Contains(pattern, "/") {
+		return path.Match(pattern, path.Base(name))
+	}
+	return matchSegments(splitPath(pattern), splitPath(name)), nil
+}
+
+// Validate は pattern の書式が正しいかどうかを検証します。
+func Validate(pattern string) error {
+	for _, segment := range splitPath(pattern) {
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitPath は前後の "/" を取り除いて要素に分割します。
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// matchSegments はパターンの要素と名前の要素を先頭から比較します。
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// 連続する "**" は1つとして扱う
+			rest := pattern[1:]
+			for len(rest) > 0 && rest[0] == "**" {
+				rest = rest[1:]
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
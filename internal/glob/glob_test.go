@@ -0,0 +1,73 @@
+package glob
+
+import (
+	"errors"
+	"path"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		pattern    string
+		path       string
+		ignoreCase bool
+		expected   bool
+	}{
+		{"ファイル名のみのパターン（ルート）", "*.jpg", "photo.jpg", false, true},
+		{"ファイル名のみのパターン（サブディレクトリ）", "*.jpg", "a/b/photo.jpg", false, true},
+		{"ファイル名のみのパターン（不一致）", "*.jpg", "a/photo.png", false, false},
+		{"大文字と小文字を区別する", "*.jpg", "DSC0001.JPG", false, false},
+		{"大文字と小文字を区別しない", "*.jpg", "DSC0001.JPG", true, true},
+		{"パスのパターン", "static/img/*.png", "static/img/logo.png", false, true},
+		{"パスのパターン（別の階層）", "static/img/*.png", "other/static/img/logo.png", false, false},
+		{"パスのパターン（深い階層）", "static/img/*.png", "static/img/sub/logo.png", false, false},
+		{"先頭の / は無視する", "/static/*.png", "static/logo.png", false, true},
+		{"末尾の **", "vendor/**", "vendor/a/b/c.jpg", false, true},
+		{"末尾の **（別のディレクトリ）", "vendor/**", "vendors/a.jpg", false, false},
+		{"先頭の **", "**/icons/*.png", "icons/a.png", false, true},
+		{"先頭の **（深い階層）", "**/icons/*.png", "a/b/icons/a.png", false, true},
+		{"途中の **", "assets/**/*.png", "assets/a.png", false, true},
+		{"途中の **（深い階層）", "assets/**/*.png", "assets/x/y/a.png", false, true},
+		{"途中の **（不一致）", "assets/**/*.png", "other/x/a.png", false, false},
+		{"? と文字クラス", "img/photo?.[jp]*", "img/photo1.png", false, true},
+		{"大文字と小文字を区別しないパス", "Photos/**", "photos/2024/a.jpg", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Match(tt.pattern, tt.path, tt.ignoreCase)
+			if err != nil {
+				t.Fatalf("Match() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{"*.jpg", false},
+		{"vendor/**", false},
+		{"img/[a-z]*.png", false},
+		{"[", true},
+		{"img/[a-/x.png", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			err := Validate(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, matchErr := Match(tt.pattern, "a.png", false); tt.wantErr && !errors.Is(matchErr, path.ErrBadPattern) {
+				t.Errorf("Match() error = %v, want %v", matchErr, path.ErrBadPattern)
+			}
+		})
+	}
+}
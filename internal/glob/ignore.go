@@ -0,0 +1,149 @@
+package glob
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Ignore は gitignore と同じ書式の除外ファイルから読み込んだ規則の集まりです。
+// 各規則はその除外ファイルがあるディレクトリ以下のパスにのみ適用され、
+// 後から追加された規則（より深いディレクトリの規則や、同じファイルの後の行）が優先されます。
+type Ignore struct {
+	ignoreCase bool
+	rules      []ignoreRule
+}
+
+// ignoreRule は除外ファイルの1行を表します。
+type ignoreRule struct {
+	dir     []string // 除外ファイルがあるディレクトリの要素（ルートの場合は空）
+	pattern []string // dir からの相対パスと比較するパターンの要素
+	negate  bool     // "!" で始まる（除外を取り消す）規則かどうか
+	dirOnly bool     // "/" で終わる（ディレクトリのみに一致する）規則かどうか
+}
+
+// NewIgnore は空の Ignore を作成します。
+// ignoreCase が true の場合は大文字と小文字を区別せずに比較します。
+func NewIgnore(ignoreCase bool) *Ignore {
+	return &Ignore{ignoreCase: ignoreCase}
+}
+
+// Add は dir（"/" 区切りの相対パス、ルートの場合は "." または空）にある除外ファイルの内容を r から読み込みます。
+// 空行と "#" で始まる行は無視し、"!" で始まる行は除外を取り消します。
+// "/" で終わるパターンはディレクトリのみに一致します。先頭または途中に "/" を含むパターンは dir からの相対パスと比較し、
+// それ以外は dir 以下の任意の階層のファイル名と比較します。"**" は Match と同じく0個以上の要素に一致します。
+func (ig *Ignore) Add(dir string, r io.Reader) error {
+	if dir == "." {
+		dir = ""
+	}
+	if ig.ignoreCase {
+		dir = strings.ToLower(dir)
+	}
+	dirSegments := splitPath(dir)
+
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		rule, ok := parseIgnoreLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		pattern := strings.Join(rule.pattern, "/")
+		if err := Validate(pattern); err != nil {
+			return fmt.Errorf("%d 行目のパターンが不正です: %s: %w", lineNum, pattern, err)
+		}
+		if ig.ignoreCase {
+			for i, segment := range rule.pattern {
+				rule.pattern[i] = strings.ToLower(segment)
+			}
+		}
+		rule.dir = dirSegments
+		ig.rules = append(ig.rules, rule)
+	}
+	return scanner.Err()
+}
+
+// parseIgnoreLine は除外ファイルの1行を規則に変換します。規則を含まない行の場合は false を返します。
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// 末尾の空白は "\" でエスケープされていない限り無視する
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// "/" を含まないパターンは任意の階層に一致する
+	if !strings.Contains(line, "/") {
+		line = "**/" + line
+	}
+	rule.pattern = splitPath(line)
+	return rule, true
+}
+
+// Match は name（"/" 区切りの相対パス）が除外されるかどうかを返します。isDir は name がディレクトリかどうかです。
+// gitignore と同じく、親ディレクトリが除外されている場合は否定の規則によらず除外されます。
+// nil の Ignore は何も除外しません。
+func (ig *Ignore) Match(name string, isDir bool) bool {
+	if ig == nil || len(ig.rules) == 0 {
+		return false
+	}
+	name = strings.Trim(name, "/")
+	if ig.ignoreCase {
+		name = strings.ToLower(name)
+	}
+
+	segments := splitPath(name)
+	for i := 1; i < len(segments); i++ {
+		if ig.match(segments[:i], true) {
+			return true
+		}
+	}
+	return ig.match(segments, isDir)
+}
+
+// match は親ディレクトリを考慮せずに、最後に一致した規則で除外されるかどうかを判定します。
+func (ig *Ignore) match(segments []string, isDir bool) bool {
+	ignored := false
+	for _, rule := range ig.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if !hasPrefix(segments, rule.dir) {
+			continue
+		}
+		if matchSegments(rule.pattern, segments[len(rule.dir):]) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// hasPrefix は segments が dir より深い階層のパスかどうかを返します。
+func hasPrefix(segments, dir []string) bool {
+	if len(segments) <= len(dir) {
+		return false
+	}
+	for i := range dir {
+		if segments[i] != dir[i] {
+			return false
+		}
+	}
+	return true
+}
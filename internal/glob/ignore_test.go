@@ -0,0 +1,106 @@
+package glob
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIgnore_Match(t *testing.T) {
+	ig := NewIgnore(false)
+	root := `# コメント
+*.psd
+/build
+tmp/
+raw/**
+!keep.psd
+\#hash.jpg
+trailing.jpg   
+`
+	if err := ig.Add(".", strings.NewReader(root)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	// サブディレクトリの規則はルートの規則より優先される
+	if err := ig.Add("photos", strings.NewReader("*.jpg\n!hero.jpg\n/only-here.png\n")); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"任意の階層のファイル名", "a/b/design.psd", false, true},
+		{"否定の規則", "a/keep.psd", false, false},
+		{"先頭の / はルートのみ", "build", true, true},
+		{"先頭の / はルートのみ（サブディレクトリ）", "a/build", true, false},
+		{"ディレクトリのみの規則", "x/tmp", true, true},
+		{"ディレクトリのみの規則（ファイル）", "x/tmp", false, false},
+		{"除外したディレクトリ内のファイル", "x/tmp/a.jpg", false, true},
+		{"** 以下のファイル", "raw/2024/a.jpg", false, true},
+		{"エスケープした #", "#hash.jpg", false, true},
+		{"末尾の空白は無視する", "trailing.jpg", false, true},
+		{"サブディレクトリの規則", "photos/a.jpg", false, true},
+		{"サブディレクトリの否定の規則", "photos/hero.jpg", false, false},
+		{"サブディレクトリの規則は他のディレクトリに適用しない", "icons/a.jpg", false, false},
+		{"サブディレクトリからの相対パス", "photos/only-here.png", false, true},
+		{"サブディレクトリからの相対パス（深い階層）", "photos/x/only-here.png", false, false},
+		{"一致しない", "icons/a.png", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ig.Match(tt.path, tt.isDir); got != tt.expected {
+				t.Errorf("Match(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIgnore_ParentDirectoryExcluded(t *testing.T) {
+	// 親ディレクトリが除外されている場合は、否定の規則でファイルを含めることはできない
+	ig := NewIgnore(false)
+	if err := ig.Add("", strings.NewReader("vendor/\n!vendor/keep.jpg\n")); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if !ig.Match("vendor/keep.jpg", false) {
+		t.Error("除外したディレクトリ内のファイルが含まれています")
+	}
+}
+
+func TestIgnore_IgnoreCase(t *testing.T) {
+	tests := []struct {
+		name       string
+		ignoreCase bool
+		expected   bool
+	}{
+		{"大文字と小文字を区別する", false, false},
+		{"大文字と小文字を区別しない", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ig := NewIgnore(tt.ignoreCase)
+			if err := ig.Add("Photos", strings.NewReader("*.raw\n")); err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+			if got := ig.Match("photos/IMG_0001.RAW", false); got != tt.expected {
+				t.Errorf("Match() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIgnore_Nil(t *testing.T) {
+	var ig *Ignore
+	if ig.Match("a.jpg", false) {
+		t.Error("nil の Ignore がファイルを除外しました")
+	}
+}
+
+func TestIgnore_AddInvalidPattern(t *testing.T) {
+	err := NewIgnore(false).Add("", strings.NewReader("*.jpg\n[\n"))
+	if err == nil || !strings.Contains(err.Error(), "2 行目") {
+		t.Errorf("Add() error = %v, want 2 行目のエラー", err)
+	}
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/takumines/shuku/internal/fileutil"
+)
+
+// DirStorage はOSのディレクトリに書き込む Storage です。
+// 書き込みは一時ファイルを経由してアトミックに行われ、必要な親ディレクトリは自動で作成されます。
+type DirStorage struct {
+	Dir string // 書き込み先のルートディレクトリ
+}
+
+// NewDirStorage は dir に書き込む DirStorage を作成します。
+func NewDirStorage(dir string) *DirStorage {
+	return &DirStorage{Dir: dir}
+}
+
+// WriteFile は write の内容を Dir 配下の name にアトミックに書き込みます。
+// 既存のファイルを置き換える場合はそのパーミッションを維持し、
+// シンボリックリンクの場合はリンク自体ではなくリンク先を置き換えます。
+func (d *DirStorage) WriteFile(ctx context.Context, name string, perm fs.FileMode, write func(w io.Writer) error) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return errWriteCanceled(ctx, name)
+	}
+
+	path := d.path(name)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			path = resolved
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(path, perm, write)
+}
+
+// ReadFile は Dir 配下の name の内容を返します。
+func (d *DirStorage) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	return os.ReadFile(d.path(name))
+}
+
+// Location は name に対応するOSのファイルパスを返します。
+func (d *DirStorage) Location(name string) string {
+	return d.path(name)
+}
+
+// path は name をOSのファイルパスに変換します。
+func (d *DirStorage) path(name string) string {
+	return filepath.Join(d.Dir, filepath.FromSlash(name))
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeString(s string) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+}
+
+func TestDirStorage_WriteFile(t *testing.T) {
+	t.Run("親ディレクトリを作成して書き込む", func(t *testing.T) {
+		dir := t.TempDir()
+		s := NewDirStorage(dir)
+
+		if err := s.WriteFile(context.Background(), "sub/dir/output.jpg", 0640, writeString("compressed")); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+
+		path := filepath.Join(dir, "sub", "dir", "output.jpg")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("出力ファイルの読み込みに失敗しました: %v", err)
+		}
+		if string(data) != "compressed" {
+			t.Errorf("出力内容 = %q, want %q", data, "compressed")
+		}
+		info, _ := os.Stat(path)
+		if info.Mode().Perm() != 0640 {
+			t.Errorf("パーミッション = %v, want %v", info.Mode().Perm(), os.FileMode(0640))
+		}
+		if got := s.Location("sub/dir/output.jpg"); got != path {
+			t.Errorf("Location() = %v, want %v", got, path)
+		}
+	})
+
+	t.Run("既存ファイルのパーミッションを維持する", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "output.jpg")
+		if err := os.WriteFile(path, []byte("original"), 0600); err != nil {
+			t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+		}
+
+		if err := NewDirStorage(dir).WriteFile(context.Background(), "output.jpg", 0644, writeString("compressed")); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		info, _ := os.Stat(path)
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("パーミッション = %v, want %v", info.Mode().Perm(), os.FileMode(0600))
+		}
+	})
+
+	t.Run("シンボリックリンクはリンク先を置き換える", func(t *testing.T) {
+		dir := t.TempDir()
+		realPath := filepath.Join(dir, "real.jpg")
+		if err := os.WriteFile(realPath, []byte("original"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+		}
+		if err := os.Symlink(realPath, filepath.Join(dir, "link.jpg")); err != nil {
+			t.Skipf("シンボリックリンクを作成できません: %v", err)
+		}
+
+		if err := NewDirStorage(dir).WriteFile(context.Background(), "link.jpg", 0644, writeString("compressed")); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		info, err := os.Lstat(filepath.Join(dir, "link.jpg"))
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("シンボリックリンクが置き換えられました")
+		}
+		data, _ := os.ReadFile(realPath)
+		if string(data) != "compressed" {
+			t.Errorf("リンク先の内容 = %q, want %q", data, "compressed")
+		}
+	})
+
+	t.Run("失敗時は既存ファイルを変更しない", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "output.jpg")
+		if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+		}
+
+		writeErr := errors.New("書き込みエラー")
+		err := NewDirStorage(dir).WriteFile(context.Background(), "output.jpg", 0644, func(w io.Writer) error {
+			_, _ = io.WriteString(w, "partial")
+			return writeErr
+		})
+		if !errors.Is(err, writeErr) {
+			t.Fatalf("WriteFile() error = %v, want %v", err, writeErr)
+		}
+		data, _ := os.ReadFile(path)
+		if string(data) != "original" {
+			t.Errorf("既存ファイルが変更されました: %q", data)
+		}
+	})
+
+	t.Run("不正なファイル名", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, name := range []string{"", ".", "../outside.jpg", "/abs.jpg"} {
+			err := NewDirStorage(dir).WriteFile(context.Background(), name, 0644, writeString("x"))
+			if !errors.Is(err, fs.ErrInvalid) {
+				t.Errorf("WriteFile(%q) error = %v, want %v", name, err, fs.ErrInvalid)
+			}
+		}
+	})
+
+	t.Run("キャンセル済みのコンテキスト", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		dir := t.TempDir()
+		err := NewDirStorage(dir).WriteFile(ctx, "output.jpg", 0644, writeString("x"))
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("WriteFile() error = %v, want %v", err, context.Canceled)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "output.jpg")); !os.IsNotExist(err) {
+			t.Error("キャンセル後に出力ファイルが作成されました")
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"sort"
+	"sync"
+)
+
+// MemoryStorage はメモリ上に書き込む Storage です。並行に使用しても安全です。
+// テストや、圧縮結果をファイルに保存せずに利用する場合に使用します。
+type MemoryStorage struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+// NewMemoryStorage は空の MemoryStorage を作成します。
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{files: map[string][]byte{}}
+}
+
+// WriteFile は write の内容を name に保存します。
+// write が成功した場合のみ内容が置き換えられます。
+func (m *MemoryStorage) WriteFile(ctx context.Context, name string, perm fs.FileMode, write func(w io.Writer) error) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return errWriteCanceled(ctx, name)
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[name] = buf.Bytes()
+	return nil
+}
+
+// Location は name をそのまま返します。
+func (m *MemoryStorage) Location(name string) string {
+	return name
+}
+
+// ReadFile は name に保存された内容を返します。
+func (m *MemoryStorage) ReadFile(name string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return bytes.Clone(data), nil
+}
+
+// Names は保存されているファイル名をソートして返します。
+func (m *MemoryStorage) Names() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.files))
+	for name := range m.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	t.Run("書き込みと読み込み", func(t *testing.T) {
+		s := NewMemoryStorage()
+		if err := s.WriteFile(context.Background(), "b/output.png", 0644, writeString("png")); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		if err := s.WriteFile(context.Background(), "a.jpg", 0644, writeString("jpeg")); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+
+		data, err := s.ReadFile("a.jpg")
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		if string(data) != "jpeg" {
+			t.Errorf("ReadFile() = %q, want %q", data, "jpeg")
+		}
+		if names := s.Names(); !reflect.DeepEqual(names, []string{"a.jpg", "b/output.png"}) {
+			t.Errorf("Names() = %v", names)
+		}
+		if got := s.Location("a.jpg"); got != "a.jpg" {
+			t.Errorf("Location() = %v, want a.jpg", got)
+		}
+	})
+
+	t.Run("失敗時は既存の内容を変更しない", func(t *testing.T) {
+		s := NewMemoryStorage()
+		_ = s.WriteFile(context.Background(), "output.jpg", 0644, writeString("original"))
+
+		writeErr := errors.New("書き込みエラー")
+		err := s.WriteFile(context.Background(), "output.jpg", 0644, func(w io.Writer) error {
+			_, _ = io.WriteString(w, "partial")
+			return writeErr
+		})
+		if !errors.Is(err, writeErr) {
+			t.Fatalf("WriteFile() error = %v, want %v", err, writeErr)
+		}
+		data, _ := s.ReadFile("output.jpg")
+		if string(data) != "original" {
+			t.Errorf("既存の内容が変更されました: %q", data)
+		}
+	})
+
+	t.Run("存在しないファイル", func(t *testing.T) {
+		if _, err := NewMemoryStorage().ReadFile("missing.jpg"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("ReadFile() error = %v, want %v", err, fs.ErrNotExist)
+		}
+	})
+
+	t.Run("並行書き込み", func(t *testing.T) {
+		s := NewMemoryStorage()
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				name := fmt.Sprintf("image%02d.jpg", i)
+				if err := s.WriteFile(context.Background(), name, 0644, writeString(name)); err != nil {
+					t.Errorf("WriteFile() error = %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+		if len(s.Names()) != 20 {
+			t.Errorf("Names() count = %d, want 20", len(s.Names()))
+		}
+	})
+}
@@ -0,0 +1,446 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// fakeS3 は ListObjectsV2・GetObject・HeadObject・PutObject とマルチパートアップロードのみを実装した
+// S3 互換のテスト用サーバーです。パス形式（/bucket/key）のリクエストを受け付けます。
+type fakeS3 struct {
+	bucket   string
+	pageSize int // 一覧の1ページあたりの件数（継続トークンの確認用）
+
+	mu           sync.Mutex
+	objects      map[string][]byte
+	contentTypes map[string]string
+	uploads      map[string]map[int][]byte // 進行中のマルチパートアップロードのパート
+	requests     map[string]int            // メソッドごとのリクエスト数
+}
+
+func newFakeS3(t *testing.T, bucket string, objects map[string]string) (*fakeS3, *httptest.Server) {
+	t.Helper()
+	f := &fakeS3{
+		bucket:       bucket,
+		pageSize:     2,
+		objects:      map[string][]byte{},
+		contentTypes: map[string]string{},
+		uploads:      map[string]map[int][]byte{},
+		requests:     map[string]int{},
+	}
+	for key, data := range objects {
+		f.objects[key] = []byte(data)
+	}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=test-key/") {
+		f.writeError(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		f.writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests[r.Method]++
+
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodGet && key == "" && query.Get("list-type") == "2":
+		f.list(w, r)
+	case query.Has("uploads") || query.Has("uploadId"):
+		f.multipart(w, r, key)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			f.writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Last-Modified", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		if got := r.Header.Get("X-Amz-Content-Sha256"); got != hashHex(data) {
+			f.writeError(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch")
+			return
+		}
+		f.objects[key] = data
+		f.contentTypes[key] = r.Header.Get("Content-Type")
+	default:
+		f.writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// multipart はマルチパートアップロードの開始・パートの送信・完了・中止を処理します。
+func (f *fakeS3) multipart(w http.ResponseWriter, r *http.Request, key string) {
+	query := r.URL.Query()
+	if r.Method == http.MethodPost && query.Has("uploads") {
+		id := strconv.Itoa(len(f.uploads) + len(f.objects) + 1)
+		f.uploads[id] = map[int][]byte{}
+		f.contentTypes[key] = r.Header.Get("Content-Type")
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+		return
+	}
+
+	id := query.Get("uploadId")
+	parts, ok := f.uploads[id]
+	if !ok {
+		f.writeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+	data, _ := io.ReadAll(r.Body)
+	if got := r.Header.Get("X-Amz-Content-Sha256"); got != hashHex(data) {
+		f.writeError(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		n, _ := strconv.Atoi(query.Get("partNumber"))
+		parts[n] = data
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, n))
+	case http.MethodPost:
+		var complete struct {
+			Parts []struct {
+				PartNumber int
+				ETag       string
+			} `xml:"Part"`
+		}
+		if err := xml.Unmarshal(data, &complete); err != nil || len(complete.Parts) != len(parts) {
+			f.writeError(w, http.StatusBadRequest, "InvalidPart")
+			return
+		}
+		var object []byte
+		for i, part := range complete.Parts {
+			if part.PartNumber != i+1 || part.ETag != fmt.Sprintf(`"etag-%d"`, i+1) {
+				f.writeError(w, http.StatusBadRequest, "InvalidPartOrder")
+				return
+			}
+			object = append(object, parts[part.PartNumber]...)
+		}
+		f.objects[key] = object
+		delete(f.uploads, id)
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case http.MethodDelete:
+		delete(f.uploads, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	maxKeys := f.pageSize
+	if n, err := strconv.Atoi(query.Get("max-keys")); err == nil && n < maxKeys {
+		maxKeys = n
+	}
+
+	// キーとプレフィックスをまとめて名前順に並べる
+	isPrefix := map[string]bool{}
+	var items []string
+	for key := range f.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		item, cut := key, false
+		if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+			item, cut = key[:len(prefix)+i+1], true
+		}
+		if _, ok := isPrefix[item]; !ok {
+			items = append(items, item)
+		}
+		isPrefix[item] = cut
+	}
+	sort.Strings(items)
+
+	start := 0
+	if token := query.Get("continuation-token"); token != "" {
+		start, _ = strconv.Atoi(token)
+	}
+	end := min(start+maxKeys, len(items))
+
+	type content struct {
+		Key          string
+		Size         int
+		LastModified string
+	}
+	type commonPrefix struct {
+		Prefix string
+	}
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+		Contents              []content
+		CommonPrefixes        []commonPrefix
+	}{IsTruncated: end < len(items)}
+	if result.IsTruncated {
+		result.NextContinuationToken = strconv.Itoa(end)
+	}
+	for _, item := range items[start:end] {
+		if isPrefix[item] {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: item})
+		} else {
+			result.Contents = append(result.Contents, content{Key: item, Size: len(f.objects[item]), LastModified: "2024-01-02T03:04:05.000Z"})
+		}
+	}
+	_ = xml.NewEncoder(w).Encode(result)
+}
+
+func (f *fakeS3) writeError(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func newTestS3Storage(t *testing.T, server *httptest.Server, prefix string) *S3Storage {
+	t.Helper()
+	s, err := NewS3Storage(S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "assets",
+		Prefix:          prefix,
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+		PathStyle:       true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage() error = %v", err)
+	}
+	return s
+}
+
+func TestS3Storage_FS(t *testing.T) {
+	_, server := newFakeS3(t, "assets", map[string]string{
+		"images/a.jpg":         "jpeg-a",
+		"images/b.png":         "png-b",
+		"images/c.webp":        "webp-c",
+		"images/sub/":          "",
+		"images/sub/d.jpg":     "jpeg-d",
+		"images/sub/deep/e.jp": "other",
+		"other/f.jpg":          "outside",
+	})
+	s := newTestS3Storage(t, server, "images/")
+
+	// fstest.TestFS で fs.FS としての一覧・読み込み・情報取得の整合性を確認する
+	if err := fstest.TestFS(s, "a.jpg", "b.png", "c.webp", "sub/d.jpg", "sub/deep/e.jp"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("プレフィックスの外は参照しない", func(t *testing.T) {
+		if _, err := s.ReadFile("other/f.jpg"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("ReadFile() error = %v, want %v", err, fs.ErrNotExist)
+		}
+	})
+
+	t.Run("再帰的な一覧", func(t *testing.T) {
+		var names []string
+		err := fs.WalkDir(s, ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				names = append(names, name)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WalkDir() error = %v", err)
+		}
+		want := "a.jpg,b.png,c.webp,sub/d.jpg,sub/deep/e.jp"
+		if got := strings.Join(names, ","); got != want {
+			t.Errorf("WalkDir() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("ファイル情報", func(t *testing.T) {
+		info, err := fs.Stat(s, "b.png")
+		if err != nil {
+			t.Fatalf("Stat() error = %v", err)
+		}
+		if info.Size() != int64(len("png-b")) || info.IsDir() {
+			t.Errorf("Stat() = size %d, dir %v", info.Size(), info.IsDir())
+		}
+		if info, err := fs.Stat(s, "sub"); err != nil || !info.IsDir() {
+			t.Errorf("Stat(sub) = %v, %v, want ディレクトリ", info, err)
+		}
+	})
+}
+
+func TestS3Storage_WriteFile(t *testing.T) {
+	fake, server := newFakeS3(t, "assets", nil)
+	s := newTestS3Storage(t, server, "out")
+
+	tests := []struct {
+		name        string
+		contentType string
+	}{
+		{"photo.jpg", "image/jpeg"},
+		{"nested/icon.PNG", "image/png"},
+		{"banner.webp", "image/webp"},
+		{"data.unknownext", "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.WriteFile(context.Background(), tt.name, 0644, writeString("compressed")); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+			key := "out/" + tt.name
+			if got := string(fake.objects[key]); got != "compressed" {
+				t.Errorf("オブジェクトの内容 = %q, want %q", got, "compressed")
+			}
+			if got := fake.contentTypes[key]; got != tt.contentType {
+				t.Errorf("Content-Type = %v, want %v", got, tt.contentType)
+			}
+			if got := s.Location(tt.name); got != "s3://assets/"+key {
+				t.Errorf("Location() = %v", got)
+			}
+		})
+	}
+
+	t.Run("失敗時は送信しない", func(t *testing.T) {
+		writeErr := errors.New("書き込みエラー")
+		err := s.WriteFile(context.Background(), "failed.jpg", 0644, func(w io.Writer) error {
+			return writeErr
+		})
+		if !errors.Is(err, writeErr) {
+			t.Fatalf("WriteFile() error = %v, want %v", err, writeErr)
+		}
+		if _, ok := fake.objects["out/failed.jpg"]; ok {
+			t.Error("失敗した書き込みが保存されました")
+		}
+	})
+
+	t.Run("マルチパートアップロード", func(t *testing.T) {
+		s.partSize = 4
+		defer func() { s.partSize = s3PartSize }()
+
+		tests := []struct {
+			name    string
+			content string
+			parts   int // 0 の場合は PutObject で送信する
+		}{
+			{"single.jpg", "abcd", 0},
+			{"multi.jpg", "abcdefghij", 3},
+			{"exact.jpg", "abcdefgh", 2},
+		}
+		for _, tt := range tests {
+			fake.requests = map[string]int{}
+			if err := s.WriteFile(context.Background(), tt.name, 0644, func(w io.Writer) error {
+				// 1バイトずつ書き込んでもパートの大きさごとに送信する
+				for i := range len(tt.content) {
+					if _, err := w.Write([]byte{tt.content[i]}); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				t.Fatalf("%s: WriteFile() error = %v", tt.name, err)
+			}
+			key := "out/" + tt.name
+			if got := string(fake.objects[key]); got != tt.content {
+				t.Errorf("%s: オブジェクトの内容 = %q, want %q", tt.name, got, tt.content)
+			}
+			if got := fake.contentTypes[key]; got != "image/jpeg" {
+				t.Errorf("%s: Content-Type = %v", tt.name, got)
+			}
+			if got := fake.requests[http.MethodPut]; got != max(tt.parts, 1) {
+				t.Errorf("%s: PUT リクエスト数 = %d, want %d", tt.name, got, max(tt.parts, 1))
+			}
+		}
+
+		// 途中で失敗した場合はアップロードを中止する
+		writeErr := errors.New("書き込みエラー")
+		err := s.WriteFile(context.Background(), "aborted.jpg", 0644, func(w io.Writer) error {
+			_, _ = io.WriteString(w, "abcdefghij")
+			return writeErr
+		})
+		if !errors.Is(err, writeErr) {
+			t.Fatalf("WriteFile() error = %v, want %v", err, writeErr)
+		}
+		if _, ok := fake.objects["out/aborted.jpg"]; ok {
+			t.Error("失敗した書き込みが保存されました")
+		}
+		if len(fake.uploads) != 0 {
+			t.Errorf("中止していないアップロードが残っています: %v", fake.uploads)
+		}
+	})
+}
+
+func TestS3Storage_Errors(t *testing.T) {
+	_, server := newFakeS3(t, "assets", map[string]string{"a.jpg": "jpeg"})
+
+	t.Run("認証エラー", func(t *testing.T) {
+		s, _ := NewS3Storage(S3Config{Endpoint: server.URL, Bucket: "assets", AccessKeyID: "wrong", PathStyle: true})
+		_, err := s.ReadFile("a.jpg")
+		if !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("ReadFile() error = %v, want %v", err, fs.ErrPermission)
+		}
+		var s3Err *S3Error
+		if !errors.As(err, &s3Err) || s3Err.Code != "AccessDenied" {
+			t.Errorf("S3Error を取得できません: %v", err)
+		}
+	})
+
+	t.Run("存在しないオブジェクト", func(t *testing.T) {
+		s := newTestS3Storage(t, server, "")
+		if _, err := fs.Stat(s, "missing.jpg"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Stat() error = %v, want %v", err, fs.ErrNotExist)
+		}
+	})
+
+	t.Run("バケット名の指定なし", func(t *testing.T) {
+		if _, err := NewS3Storage(S3Config{}); err == nil {
+			t.Error("バケット名がない設定に対してエラーが発生しませんでした")
+		}
+	})
+}
+
+func TestParseS3URL(t *testing.T) {
+	tests := []struct {
+		input  string
+		bucket string
+		prefix string
+		ok     bool
+	}{
+		{"s3://assets", "assets", "", true},
+		{"s3://assets/images/2024/", "assets", "images/2024", true},
+		{"s3://", "", "", false},
+		{"./images", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			bucket, prefix, ok := ParseS3URL(tt.input)
+			if bucket != tt.bucket || prefix != tt.prefix || ok != tt.ok {
+				t.Errorf("ParseS3URL() = (%q, %q, %v), want (%q, %q, %v)", bucket, prefix, ok, tt.bucket, tt.prefix, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+// Package storage は圧縮結果の書き込み先を抽象化します。
+// OSのディレクトリ（DirStorage）・メモリ上（MemoryStorage）・S3 互換のオブジェクトストレージ（S3Storage）の実装を提供します。
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// Storage は圧縮結果の書き込み先を表します。
+// name は fs.FS と同じくスラッシュ区切りの相対パスです（fs.ValidPath を満たす必要があります）。
+type Storage interface {
+	// WriteFile は write が書き込んだ内容を name に保存します。
+	// write がエラーを返した場合、name の既存の内容は変更されません。
+	// perm は新しく作成するファイルの権限で、権限を持たないストレージでは無視されます。
+	WriteFile(ctx context.Context, name string, perm fs.FileMode, write func(w io.Writer) error) error
+
+	// Location は name の保存先を表示用の文字列で返します。
+	Location(name string) string
+}
+
+// validateName は name がストレージ内の有効なファイル名かどうかを検証します。
+func validateName(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	return nil
+}
+
+// errWriteCanceled は書き込みが ctx のキャンセルで中断されたことを表します。
+func errWriteCanceled(ctx context.Context, name string) error {
+	return fmt.Errorf("%s への書き込みを中断しました: %w", name, ctx.Err())
+}
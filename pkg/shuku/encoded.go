@@ -0,0 +1,94 @@
+package shuku
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"io"
+	"sync"
+
+	"github.com/takumines/shuku/internal/compressor"
+)
+
+// EncodedImage は圧縮済みの画像データです。
+// エンコード結果のバイト列を保持し、画像としてのプレビューは Image を呼び出したときにのみデコードされます。
+type EncodedImage struct {
+	Format string // 画像形式
+
+	data  []byte
+	codec Codec
+
+	previewOnce sync.Once
+	preview     image.Image
+	previewErr  error
+}
+
+// Bytes はエンコード済みのデータを返します。
+// 返されたスライスは EncodedImage と共有されるため、変更しないでください。
+func (e *EncodedImage) Bytes() []byte {
+	return e.data
+}
+
+// Len はエンコード済みのデータのバイト数を返します。
+func (e *EncodedImage) Len() int {
+	return len(e.data)
+}
+
+// WriteTo はエンコード済みのデータを w に書き込みます。
+func (e *EncodedImage) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(e.data)
+	return int64(n), err
+}
+
+// Image はエンコード済みのデータをデコードしたプレビュー画像を返します。
+// デコードは最初の呼び出し時に一度だけ行われ、結果は以降の呼び出しで再利用されます。
+func (e *EncodedImage) Image() (image.Image, error) {
+	e.previewOnce.Do(func() {
+		img, err := e.codec.Decode(context.Background(), bytes.NewReader(e.data))
+		if err != nil {
+			e.previewErr = wrapError(StageDecode, e.Format, "", err)
+			return
+		}
+		e.preview = img
+	})
+	return e.preview, e.previewErr
+}
+
+// EncodeImage は画像を指定した形式で圧縮し、エンコード済みのデータを返します。
+// CompressImage と異なり、圧縮結果を画像に再デコードしないため、
+// バイト列が必要な場合の処理時間とメモリ割り当てを削減できます。
+// 対応するコンプレッサーが Codec を実装している必要があります。
+func EncodeImage(img image.Image, format string, options Options) (*EncodedImage, error) {
+	return EncodeImageContext(context.Background(), img, format, options)
+}
+
+// EncodeImageContext は ctx を考慮して EncodeImage を実行します。
+func EncodeImageContext(ctx context.Context, img image.Image, format string, options Options) (*EncodedImage, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	format = normalizeFormat(format)
+	comp, ok := Lookup(format)
+	if !ok {
+		return nil, unsupportedFormatError(format, "")
+	}
+	codec, ok := comp.(Codec)
+	if !ok {
+		// 段階ごとに実行できないコンプレッサーではエンコード結果だけを取り出せない
+		return nil, unsupportedFormatError(format, "")
+	}
+
+	buf := compressor.GetBuffer()
+	defer compressor.PutBuffer(buf)
+	if err := codec.Encode(ctx, buf, img, options); err != nil {
+		return nil, wrapError(StageEncode, format, "", err)
+	}
+
+	// バッファはプールに戻すため、結果は EncodedImage が所有するスライスに複製する
+	return &EncodedImage{
+		Format: comp.SupportedFormat(),
+		data:   bytes.Clone(buf.Bytes()),
+		codec:  codec,
+	}, nil
+}
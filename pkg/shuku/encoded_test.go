@@ -0,0 +1,102 @@
+package shuku
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncodeImage(t *testing.T) {
+	img := createTestImage(64, 48)
+
+	tests := []struct {
+		name    string
+		format  string
+		options Options
+		want    string
+		wantErr error
+	}{
+		{"JPEG", "jpg", Options{Quality: 80}, "jpeg", nil},
+		{"PNG", "png", Options{}, "png", nil},
+		{"WebP", "WEBP", Options{Quality: 80}, "webp", nil},
+		{"未登録の形式", "bmp", Options{}, "", ErrUnsupportedFormat},
+		{"範囲外の品質", "jpeg", Options{Quality: 200}, "", ErrInvalidOptions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := EncodeImage(img, tt.format, tt.options)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("EncodeImage() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EncodeImage() error = %v", err)
+			}
+
+			if encoded.Format != tt.want {
+				t.Errorf("Format = %v, want %v", encoded.Format, tt.want)
+			}
+			if format, err := DetectFormat(encoded.Bytes()); err != nil || format != tt.want {
+				t.Errorf("エンコード結果の形式 = %v (%v), want %v", format, err, tt.want)
+			}
+
+			var buf bytes.Buffer
+			n, err := encoded.WriteTo(&buf)
+			if err != nil || n != int64(encoded.Len()) || !bytes.Equal(buf.Bytes(), encoded.Bytes()) {
+				t.Errorf("WriteTo() = %d, %v; データが一致しません", n, err)
+			}
+
+			preview, err := encoded.Image()
+			if err != nil {
+				t.Fatalf("Image() error = %v", err)
+			}
+			if preview.Bounds() != img.Bounds() {
+				t.Errorf("プレビューの範囲 = %v, want %v", preview.Bounds(), img.Bounds())
+			}
+			if again, _ := encoded.Image(); again != preview {
+				t.Error("Image() の2回目の呼び出しで再デコードされました")
+			}
+		})
+	}
+}
+
+func TestEncodeImage_CustomCompressor(t *testing.T) {
+	Register("encodefmt", &fakeCompressor{format: "encodefmt"})
+	t.Cleanup(func() { Unregister("encodefmt") })
+
+	// Codec を実装しないコンプレッサーではエンコード結果を取り出せない
+	if _, err := EncodeImage(createTestImage(10, 10), "encodefmt", Options{}); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("EncodeImage() error = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+// CompressImage はエンコード後に再デコードし、エンコード結果は破棄される
+func BenchmarkCompressImage(b *testing.B) {
+	img := createTestImage(512, 512)
+	options := Options{Quality: 80}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := CompressImage(img, "jpeg", options); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// EncodeImage でエンコード結果を直接受け取る
+func BenchmarkEncodeImage(b *testing.B) {
+	img := createTestImage(512, 512)
+	options := Options{Quality: 80}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := EncodeImage(img, "jpeg", options); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
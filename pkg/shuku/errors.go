@@ -0,0 +1,129 @@
+package shuku
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/takumines/shuku/internal/compressor"
+)
+
+// errors.Is で判定できるエラーの種類です。
+var (
+	// ErrUnsupportedFormat は画像形式を判定できない、または対応するコンプレッサーが登録されていないことを表します。
+	ErrUnsupportedFormat = errors.New("サポートされていない画像形式です")
+
+	// ErrInvalidImage は入力データが破損している、または判定した形式の画像として読み込めないことを表します。
+	ErrInvalidImage = errors.New("画像データが破損しているか、形式と一致しません")
+
+	// ErrIO はファイルの読み込み・書き込みに失敗したことを表します。
+	ErrIO = errors.New("ファイルの入出力に失敗しました")
+)
+
+// Stage はエラーが発生した処理段階を表します。
+type Stage string
+
+const (
+	StageDetect    Stage = "detect"    // 画像形式の判定
+	StageRead      Stage = "read"      // 入力ファイルの読み込み
+	StageDecode    Stage = "decode"    // 入力データのデコード
+	StageTransform Stage = "transform" // 画像の変換
+	StageEncode    Stage = "encode"    // 圧縮データのエンコード
+	StageWrite     Stage = "write"     // 出力ファイルの書き込み
+)
+
+// stageLabels はエラーメッセージ用の処理段階名です。
+var stageLabels = map[Stage]string{
+	StageDetect:    "形式判定",
+	StageRead:      "読み込み",
+	StageDecode:    "デコード",
+	StageTransform: "変換",
+	StageEncode:    "エンコード",
+	StageWrite:     "書き込み",
+}
+
+// CompressError は圧縮処理で発生したエラーを表します。
+// errors.As で取得すると、失敗した処理段階・画像形式・ファイルパスを参照できます。
+// 処理段階に応じて errors.Is(err, ErrInvalidImage) や errors.Is(err, ErrIO) が真になります。
+type CompressError struct {
+	Stage  Stage  // エラーが発生した処理段階
+	Format string // 画像形式（判定前の場合は空）
+	Path   string // ファイルパス（バイトデータを処理した場合は空）
+	Err    error  // 元のエラー
+}
+
+// Error はエラーメッセージを返します。
+func (e *CompressError) Error() string {
+	msg := "shuku: " + stageLabels[e.Stage] + "に失敗しました"
+	switch {
+	case e.Format != "" && e.Path != "":
+		msg += fmt.Sprintf(" (%s, %s)", e.Format, e.Path)
+	case e.Format != "":
+		msg += fmt.Sprintf(" (%s)", e.Format)
+	case e.Path != "":
+		msg += fmt.Sprintf(" (%s)", e.Path)
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+// Unwrap は元のエラーを返します。
+func (e *CompressError) Unwrap() error {
+	return e.Err
+}
+
+// Is は処理段階に対応するエラーの種類と一致するかどうかを判定します。
+// キャンセルによる中断は ErrInvalidImage・ErrIO のいずれにも該当しません。
+func (e *CompressError) Is(target error) bool {
+	if errors.Is(e.Err, context.Canceled) || errors.Is(e.Err, context.DeadlineExceeded) {
+		return false
+	}
+
+	switch target {
+	case ErrInvalidImage:
+		return e.Stage == StageDecode
+	case ErrIO:
+		return e.Stage == StageRead || e.Stage == StageWrite
+	}
+	return false
+}
+
+// wrapError は err を CompressError で包みます。
+// 内部コンプレッサーのエラーの場合は、そこに記録された処理段階を使用します。
+// 既に CompressError の場合は、不足している形式・パスのみを補った複製を返します。
+// 呼び出し元のエラーは共有されている可能性があるため、変更しません。
+func wrapError(stage Stage, format, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var compressErr *CompressError
+	if errors.As(err, &compressErr) {
+		if (compressErr.Format != "" || format == "") && (compressErr.Path != "" || path == "") {
+			return err
+		}
+		filled := *compressErr
+		if filled.Format == "" {
+			filled.Format = format
+		}
+		if filled.Path == "" {
+			filled.Path = path
+		}
+		return &filled
+	}
+
+	var internalErr *compressor.CompressError
+	if errors.As(err, &internalErr) && internalErr.Stage != "" {
+		stage = Stage(internalErr.Stage)
+	}
+
+	return &CompressError{Stage: stage, Format: format, Path: path, Err: err}
+}
+
+// unsupportedFormatError は対応していない形式のエラーを返します。
+func unsupportedFormatError(format, path string) error {
+	err := ErrUnsupportedFormat
+	if format != "" {
+		err = fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	return &CompressError{Stage: StageDetect, Format: format, Path: path, Err: err}
+}
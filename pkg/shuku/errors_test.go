@@ -0,0 +1,161 @@
+package shuku
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompressErrorKinds(t *testing.T) {
+	tests := []struct {
+		name      string
+		run       func(t *testing.T) error
+		target    error
+		wantStage Stage
+	}{
+		{
+			name: "認識できないデータ",
+			run: func(t *testing.T) error {
+				_, err := Compress([]byte("not an image"), Options{Quality: 80})
+				return err
+			},
+			target:    ErrUnsupportedFormat,
+			wantStage: StageDetect,
+		},
+		{
+			name: "未登録の形式",
+			run: func(t *testing.T) error {
+				_, err := CompressImage(createTestImage(10, 10), "bmp", Options{})
+				return err
+			},
+			target:    ErrUnsupportedFormat,
+			wantStage: StageDetect,
+		},
+		{
+			name: "破損したJPEGデータ",
+			run: func(t *testing.T) error {
+				data := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F'}
+				_, err := Compress(data, Options{Quality: 80})
+				return err
+			},
+			target:    ErrInvalidImage,
+			wantStage: StageDecode,
+		},
+		{
+			name: "破損したファイル",
+			run: func(t *testing.T) error {
+				path := createTempFile(t, []byte{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A, 0x00}, ".png")
+				defer os.Remove(path)
+				return CompressFile(path, path+".out", Options{})
+			},
+			target:    ErrInvalidImage,
+			wantStage: StageDecode,
+		},
+		{
+			name: "存在しない入力ファイル",
+			run: func(t *testing.T) error {
+				return CompressFile(filepath.Join(t.TempDir(), "missing.jpg"), "", Options{})
+			},
+			target:    ErrIO,
+			wantStage: StageRead,
+		},
+		{
+			name: "書き込めない出力先",
+			run: func(t *testing.T) error {
+				path := createTempFile(t, createJPEGData(t, 20, 20), ".jpg")
+				defer os.Remove(path)
+				return CompressFile(path, filepath.Join(t.TempDir(), "missing", "out.jpg"), Options{Quality: 80})
+			},
+			target:    ErrIO,
+			wantStage: StageWrite,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run(t)
+			if err == nil {
+				t.Fatal("エラーが返されませんでした")
+			}
+			if !errors.Is(err, tt.target) {
+				t.Errorf("errors.Is(err, %v) = false, err = %v", tt.target, err)
+			}
+
+			var compressErr *CompressError
+			if !errors.As(err, &compressErr) {
+				t.Fatalf("errors.As で CompressError を取得できません: %T", err)
+			}
+			if compressErr.Stage != tt.wantStage {
+				t.Errorf("Stage = %q, want %q", compressErr.Stage, tt.wantStage)
+			}
+		})
+	}
+}
+
+func TestCompressErrorFields(t *testing.T) {
+	path := createTempFile(t, []byte{0xFF, 0xD8, 0xFF, 0x00}, ".jpg")
+	defer os.Remove(path)
+
+	err := CompressFile(path, path+".out", Options{Quality: 80})
+	var compressErr *CompressError
+	if !errors.As(err, &compressErr) {
+		t.Fatalf("errors.As で CompressError を取得できません: %v", err)
+	}
+	if compressErr.Format != "jpeg" {
+		t.Errorf("Format = %q, want %q", compressErr.Format, "jpeg")
+	}
+	if compressErr.Path != path {
+		t.Errorf("Path = %q, want %q", compressErr.Path, path)
+	}
+	if errors.Is(err, ErrIO) || errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("デコードエラーが別の種類として判定されました: %v", err)
+	}
+}
+
+func TestWrapErrorDoesNotModifyInnerError(t *testing.T) {
+	shared := &CompressError{Stage: StageDecode, Err: ErrInvalidImage}
+	wrapped := fmt.Errorf("独自コンプレッサー: %w", shared)
+
+	err := wrapError(StageEncode, "jpeg", "photo.jpg", wrapped)
+	if shared.Format != "" || shared.Path != "" {
+		t.Errorf("元の CompressError が変更されました: %+v", shared)
+	}
+	var compressErr *CompressError
+	if !errors.As(err, &compressErr) {
+		t.Fatalf("errors.As で CompressError を取得できません: %v", err)
+	}
+	if compressErr == shared {
+		t.Error("元の CompressError がそのまま返されました")
+	}
+	if compressErr.Stage != StageDecode || compressErr.Format != "jpeg" || compressErr.Path != "photo.jpg" {
+		t.Errorf("CompressError = %+v, want stage decode, format jpeg, path photo.jpg", compressErr)
+	}
+	if !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("errors.Is(err, ErrInvalidImage) = false, err = %v", err)
+	}
+
+	// 補う項目がない場合は、包んだエラーをそのまま返す
+	complete := &CompressError{Stage: StageDecode, Format: "png", Path: "icon.png", Err: ErrInvalidImage}
+	wrapped = fmt.Errorf("独自コンプレッサー: %w", complete)
+	if err := wrapError(StageEncode, "jpeg", "photo.jpg", wrapped); err != wrapped {
+		t.Errorf("wrapError() = %v, want %v", err, wrapped)
+	}
+}
+
+func TestCompressErrorCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := CompressContext(ctx, createJPEGData(t, 20, 20), Options{Quality: 80})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("errors.Is(err, context.Canceled) = false, err = %v", err)
+	}
+	for _, target := range []error{ErrUnsupportedFormat, ErrInvalidImage, ErrIO} {
+		if errors.Is(err, target) {
+			t.Errorf("キャンセルが %v として判定されました", target)
+		}
+	}
+}
@@ -1,6 +1,162 @@
 package shuku
 
+import (
+	"errors"
+	"fmt"
+
+	"github.com/takumines/shuku/internal/compressor"
+)
+
+// ErrInvalidOptions は圧縮オプションの値が範囲外であることを表します。
+var ErrInvalidOptions = errors.New("圧縮オプションが不正です")
+
+// 品質とパレットサイズの有効範囲
+const (
+	minQuality     = 0
+	maxQuality     = 100
+	minPaletteSize = 2
+	maxPaletteSize = 256
+)
+
+// Options は圧縮オプションを表します。
+// Quality と PaletteSize は全形式に共通の設定で、形式ごとの設定（JPEG・PNG・WebP）が
+// 指定されている場合はそちらが優先されます。
+// NewOptions を使うと、値を検証したうえでオプションを構築できます。
 type Options struct {
-	Quality     int // JPEGの品質 (0-100)
-	PaletteSize int // PNGのパレットの色数 (8, 16, 32, 64, 128, 256)
+	Quality     int // JPEG・WebPの品質 (0-100)
+	PaletteSize int // PNGのパレットの色数 (8, 16, 32, 64, 128, 256)。0は既定値
+
+	JPEG *JPEGOptions // JPEG固有の設定
+	PNG  *PNGOptions  // PNG固有の設定
+	WebP *WebPOptions // WebP固有の設定
+}
+
+// JPEGOptions はJPEG形式の圧縮オプションです。
+type JPEGOptions struct {
+	Quality int // 品質 (0-100)
+}
+
+// PNGOptions はPNG形式の圧縮オプションです。
+type PNGOptions struct {
+	PaletteSize int // パレットの色数 (2-256)。0は既定値
+}
+
+// WebPOptions はWebP形式の圧縮オプションです。
+type WebPOptions struct {
+	Quality int // 品質 (0-100)
+}
+
+// Option は NewOptions に渡すオプション設定関数です。
+type Option func(*Options)
+
+// NewOptions は opts を順に適用したオプションを構築し、値を検証します。
+// 範囲外の値がある場合は、すべての問題を含む ErrInvalidOptions のエラーを返します。
+func NewOptions(opts ...Option) (Options, error) {
+	var o Options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := o.Validate(); err != nil {
+		return Options{}, err
+	}
+	return o, nil
+}
+
+// WithQuality は全形式に共通の品質を設定します。
+func WithQuality(quality int) Option {
+	return func(o *Options) {
+		o.Quality = quality
+	}
+}
+
+// WithPaletteSize は全形式に共通のパレットの色数を設定します。
+func WithPaletteSize(size int) Option {
+	return func(o *Options) {
+		o.PaletteSize = size
+	}
+}
+
+// WithJPEG はJPEG固有の設定を指定します。
+func WithJPEG(jpeg JPEGOptions) Option {
+	return func(o *Options) {
+		o.JPEG = &jpeg
+	}
+}
+
+// WithPNG はPNG固有の設定を指定します。
+func WithPNG(png PNGOptions) Option {
+	return func(o *Options) {
+		o.PNG = &png
+	}
+}
+
+// WithWebP はWebP固有の設定を指定します。
+func WithWebP(webp WebPOptions) Option {
+	return func(o *Options) {
+		o.WebP = &webp
+	}
+}
+
+// Validate はオプションの値が有効範囲内かどうかを検証します。
+// 範囲外の値がある場合は、すべての問題を含む ErrInvalidOptions のエラーを返します。
+func (o Options) Validate() error {
+	var errs []error
+	errs = append(errs, validateQuality("Quality", o.Quality))
+	errs = append(errs, validatePaletteSize("PaletteSize", o.PaletteSize))
+	if o.JPEG != nil {
+		errs = append(errs, validateQuality("JPEG.Quality", o.JPEG.Quality))
+	}
+	if o.PNG != nil {
+		errs = append(errs, validatePaletteSize("PNG.PaletteSize", o.PNG.PaletteSize))
+	}
+	if o.WebP != nil {
+		errs = append(errs, validateQuality("WebP.Quality", o.WebP.Quality))
+	}
+	return errors.Join(errs...)
+}
+
+// validateQuality は品質が 0-100 の範囲内かどうかを検証します。
+func validateQuality(field string, quality int) error {
+	if quality < minQuality || quality > maxQuality {
+		return fmt.Errorf("%w: %s は%d〜%dの範囲で指定してください: %d", ErrInvalidOptions, field, minQuality, maxQuality, quality)
+	}
+	return nil
+}
+
+// validatePaletteSize はパレットの色数が既定値(0)または 2-256 の範囲内かどうかを検証します。
+func validatePaletteSize(field string, size int) error {
+	if size != 0 && (size < minPaletteSize || size > maxPaletteSize) {
+		return fmt.Errorf("%w: %s は%d〜%dの範囲で指定してください: %d", ErrInvalidOptions, field, minPaletteSize, maxPaletteSize, size)
+	}
+	return nil
+}
+
+// forFormat は指定された形式に適用される設定を解決したオプションを返します。
+// 形式ごとの設定が指定されている場合は、共通の設定より優先されます。
+func (o Options) forFormat(format string) Options {
+	resolved := Options{Quality: o.Quality, PaletteSize: o.PaletteSize}
+	switch normalizeFormat(format) {
+	case "jpeg", "jpg":
+		if o.JPEG != nil {
+			resolved.Quality = o.JPEG.Quality
+		}
+	case "png":
+		if o.PNG != nil {
+			resolved.PaletteSize = o.PNG.PaletteSize
+		}
+	case "webp":
+		if o.WebP != nil {
+			resolved.Quality = o.WebP.Quality
+		}
+	}
+	return resolved
+}
+
+// toInternal は指定された形式の内部コンプレッサー用のオプションに変換します。
+func (o Options) toInternal(format string) compressor.Options {
+	resolved := o.forFormat(format)
+	return compressor.Options{
+		Quality:     resolved.Quality,
+		PaletteSize: resolved.PaletteSize,
+	}
 }
@@ -0,0 +1,115 @@
+package shuku
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		want     Options
+		wantErr  bool
+		errCount int
+	}{
+		{
+			name: "共通の設定",
+			opts: []Option{WithQuality(70), WithPaletteSize(128)},
+			want: Options{Quality: 70, PaletteSize: 128},
+		},
+		{
+			name: "形式ごとの設定",
+			opts: []Option{WithJPEG(JPEGOptions{Quality: 60}), WithPNG(PNGOptions{PaletteSize: 64}), WithWebP(WebPOptions{Quality: 90})},
+			want: Options{JPEG: &JPEGOptions{Quality: 60}, PNG: &PNGOptions{PaletteSize: 64}, WebP: &WebPOptions{Quality: 90}},
+		},
+		{
+			name:     "品質が範囲外",
+			opts:     []Option{WithQuality(150)},
+			wantErr:  true,
+			errCount: 1,
+		},
+		{
+			name:     "複数の範囲外の値",
+			opts:     []Option{WithQuality(-1), WithPaletteSize(1000), WithJPEG(JPEGOptions{Quality: 101})},
+			wantErr:  true,
+			errCount: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewOptions(tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidOptions) {
+					t.Errorf("errors.Is(err, ErrInvalidOptions) = false, err = %v", err)
+				}
+				if n := strings.Count(err.Error(), ErrInvalidOptions.Error()); n != tt.errCount {
+					t.Errorf("報告されたエラー数 = %d, want %d: %v", n, tt.errCount, err)
+				}
+				return
+			}
+
+			if got.Quality != tt.want.Quality || got.PaletteSize != tt.want.PaletteSize {
+				t.Errorf("NewOptions() = %+v, want %+v", got, tt.want)
+			}
+			if (got.JPEG == nil) != (tt.want.JPEG == nil) || (got.JPEG != nil && *got.JPEG != *tt.want.JPEG) {
+				t.Errorf("JPEG = %+v, want %+v", got.JPEG, tt.want.JPEG)
+			}
+			if (got.PNG == nil) != (tt.want.PNG == nil) || (got.PNG != nil && *got.PNG != *tt.want.PNG) {
+				t.Errorf("PNG = %+v, want %+v", got.PNG, tt.want.PNG)
+			}
+			if (got.WebP == nil) != (tt.want.WebP == nil) || (got.WebP != nil && *got.WebP != *tt.want.WebP) {
+				t.Errorf("WebP = %+v, want %+v", got.WebP, tt.want.WebP)
+			}
+		})
+	}
+}
+
+func TestOptionsForFormat(t *testing.T) {
+	options := Options{
+		Quality:     70,
+		PaletteSize: 256,
+		JPEG:        &JPEGOptions{Quality: 50},
+		PNG:         &PNGOptions{PaletteSize: 32},
+	}
+
+	tests := []struct {
+		format      string
+		quality     int
+		paletteSize int
+	}{
+		{"jpeg", 50, 256},
+		{"jpg", 50, 256},
+		{"png", 70, 32},
+		{"webp", 70, 256}, // WebP固有の設定がない場合は共通の設定を使用
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got := options.forFormat(tt.format)
+			if got.Quality != tt.quality || got.PaletteSize != tt.paletteSize {
+				t.Errorf("forFormat(%q) = %+v, want Quality=%d PaletteSize=%d", tt.format, got, tt.quality, tt.paletteSize)
+			}
+		})
+	}
+}
+
+func TestCompressInvalidOptions(t *testing.T) {
+	data := createJPEGData(t, 20, 20)
+
+	// 互換性のある Options でも範囲外の値は調整されずにエラーになる
+	_, err := Compress(data, Options{Quality: 150})
+	if !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("Compress() error = %v, want ErrInvalidOptions", err)
+	}
+
+	_, err = Compress(data, Options{Quality: 80, WebP: &WebPOptions{Quality: -5}})
+	if !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("Compress() error = %v, want ErrInvalidOptions", err)
+	}
+}
@@ -0,0 +1,246 @@
+package shuku
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/takumines/shuku/internal/compressor"
+)
+
+// Compressor は画像形式ごとの圧縮処理を表す公開インターフェースです。
+// Register で登録することで、独自の画像形式やエンコーダーを利用できます。
+type Compressor interface {
+	// Compress は画像を圧縮し、結果の画像を返します。
+	Compress(img image.Image, options Options) (image.Image, error)
+
+	// CompressBytes はバイトスライスとして提供された画像データを圧縮し、
+	// 圧縮されたデータをバイトスライスとして返します。
+	CompressBytes(data []byte, options Options) ([]byte, error)
+
+	// CompressReader は Reader からの画像データを圧縮し、
+	// 圧縮されたデータを Writer に書き込みます。
+	CompressReader(r io.Reader, w io.Writer, options Options) error
+
+	// SupportedFormat はこのコンプレッサーがサポートする画像形式を返します。
+	SupportedFormat() string
+}
+
+// ContextCompressor はキャンセルに対応した Compressor です。
+// 実装すると CompressContext などの呼び出しで ctx が各処理段階に伝播されます。
+// 実装しないコンプレッサーでは、処理の開始前にのみキャンセルが確認されます。
+type ContextCompressor interface {
+	Compressor
+
+	// CompressContext は ctx を確認しながら画像を圧縮し、結果の画像を返します。
+	CompressContext(ctx context.Context, img image.Image, options Options) (image.Image, error)
+
+	// CompressBytesContext は ctx を確認しながらバイトスライスの画像データを圧縮します。
+	CompressBytesContext(ctx context.Context, data []byte, options Options) ([]byte, error)
+
+	// CompressReaderContext は ctx を確認しながら Reader の画像データを圧縮し、Writer に書き込みます。
+	CompressReaderContext(ctx context.Context, r io.Reader, w io.Writer, options Options) error
+}
+
+// Codec はデコードとエンコードを個別の段階として実行できる Compressor です。
+// 実装すると CompressFileWithReport でデコード・エンコードの所要時間や画像サイズが報告されます。
+type Codec interface {
+	Compressor
+
+	// Decode は ctx を確認しながら Reader から画像をデコードします。
+	Decode(ctx context.Context, r io.Reader) (image.Image, error)
+
+	// Encode は ctx を確認しながら画像をエンコードし、Writer に書き込みます。
+	Encode(ctx context.Context, w io.Writer, img image.Image, options Options) error
+}
+
+// Sniffer は画像データの先頭バイトから、その形式のデータかどうかを判定します。
+type Sniffer func(header []byte) bool
+
+// formatSniffer は形式名と判定関数の組です。
+type formatSniffer struct {
+	format string
+	sniff  Sniffer
+}
+
+var (
+	registryMu sync.RWMutex
+	// 画像形式に対応するコンプレッサーのマップ
+	compressors = map[string]Compressor{}
+	// 形式判定関数（登録順に評価される）
+	sniffers []formatSniffer
+)
+
+// コンプレッサーの登録
+func init() {
+	// JPEGコンプレッサーを登録
+	jpegCompressor := builtinCompressor{compressor.NewJPEGCompressor()}
+	Register(jpegCompressor.SupportedFormat(), jpegCompressor)
+	Register("jpg", jpegCompressor) // jpgも同じコンプレッサーで対応
+	RegisterSniffer("jpeg", func(data []byte) bool {
+		return bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF})
+	})
+
+	// PNGコンプレッサーを登録
+	pngCompressor := builtinCompressor{compressor.NewPNGCompressor()}
+	Register(pngCompressor.SupportedFormat(), pngCompressor)
+	RegisterSniffer("png", func(data []byte) bool {
+		return bytes.HasPrefix(data, []byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A})
+	})
+
+	// WebPコンプレッサーを登録
+	webpCompressor := builtinCompressor{compressor.NewWebPCompressor()}
+	Register(webpCompressor.SupportedFormat(), webpCompressor)
+	RegisterSniffer("webp", func(data []byte) bool {
+		// RIFFヘッダーとWEBPシグネチャを確認
+		return len(data) >= 12 && bytes.Equal(data[0:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP"))
+	})
+}
+
+// Register は画像形式に対応するコンプレッサーを登録します。
+// 形式名は大文字小文字を区別せず、拡張子（先頭の "." は不要）としても使用されます。
+// 既に登録済みの形式を指定した場合は、コンプレッサーが置き換えられます。
+func Register(format string, c Compressor) {
+	format = normalizeFormat(format)
+	if format == "" {
+		panic("shuku: Register の形式名が空です")
+	}
+	if c == nil {
+		panic("shuku: Register のコンプレッサーが nil です: " + format)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	compressors[format] = c
+}
+
+// RegisterSniffer は画像データから形式を判定する関数を登録します。
+// Compress や CompressFile での形式判定時に、登録順に評価されます。
+func RegisterSniffer(format string, sniff Sniffer) {
+	format = normalizeFormat(format)
+	if format == "" {
+		panic("shuku: RegisterSniffer の形式名が空です")
+	}
+	if sniff == nil {
+		panic("shuku: RegisterSniffer の判定関数が nil です: " + format)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	sniffers = append(sniffers, formatSniffer{format: format, sniff: sniff})
+}
+
+// Unregister は形式の登録を取り消し、コンプレッサーと判定関数を削除します。
+// 登録されていない形式を指定した場合は何もしません。別名（"jpg" など）は個別に取り消す必要があります。
+func Unregister(format string) {
+	format = normalizeFormat(format)
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(compressors, format)
+	kept := sniffers[:0:0]
+	for _, s := range sniffers {
+		if s.format != format {
+			kept = append(kept, s)
+		}
+	}
+	sniffers = kept
+}
+
+// Lookup は指定された形式に対応する登録済みコンプレッサーを返します。
+func Lookup(format string) (Compressor, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	c, ok := compressors[normalizeFormat(format)]
+	return c, ok
+}
+
+// Formats は登録済みの形式名をソートして返します。
+// "jpg" のような別名も含まれます。
+func Formats() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	formats := make([]string, 0, len(compressors))
+	for format := range compressors {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// sniffFormat は登録済みの判定関数を使ってデータの形式を判定します。
+func sniffFormat(data []byte) (string, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	for _, s := range sniffers {
+		if s.sniff(data) {
+			return s.format, true
+		}
+	}
+	return "", false
+}
+
+// normalizeFormat は形式名を小文字に揃え、先頭の "." を取り除きます。
+func normalizeFormat(format string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(format), "."))
+}
+
+// builtinCompressor は内部コンプレッサーを公開インターフェースに適合させます。
+type builtinCompressor struct {
+	c interface {
+		compressor.Compressor
+		compressor.Codec
+		EffectiveOptions(options compressor.Options) compressor.Options
+	}
+}
+
+func (b builtinCompressor) Compress(img image.Image, options Options) (image.Image, error) {
+	return b.c.Compress(img, options.toInternal(b.c.SupportedFormat()))
+}
+
+func (b builtinCompressor) CompressBytes(data []byte, options Options) ([]byte, error) {
+	return b.c.CompressBytes(data, options.toInternal(b.c.SupportedFormat()))
+}
+
+func (b builtinCompressor) CompressReader(r io.Reader, w io.Writer, options Options) error {
+	return b.c.CompressReader(r, w, options.toInternal(b.c.SupportedFormat()))
+}
+
+func (b builtinCompressor) SupportedFormat() string {
+	return b.c.SupportedFormat()
+}
+
+func (b builtinCompressor) CompressContext(ctx context.Context, img image.Image, options Options) (image.Image, error) {
+	return compressor.CompressContext(ctx, b.c, img, options.toInternal(b.c.SupportedFormat()))
+}
+
+func (b builtinCompressor) CompressBytesContext(ctx context.Context, data []byte, options Options) ([]byte, error) {
+	return compressor.CompressBytesContext(ctx, b.c, data, options.toInternal(b.c.SupportedFormat()))
+}
+
+func (b builtinCompressor) CompressReaderContext(ctx context.Context, r io.Reader, w io.Writer, options Options) error {
+	return compressor.CompressReaderContext(ctx, b.c, r, w, options.toInternal(b.c.SupportedFormat()))
+}
+
+func (b builtinCompressor) Decode(ctx context.Context, r io.Reader) (image.Image, error) {
+	return compressor.DecodeContext(ctx, b.c, r)
+}
+
+func (b builtinCompressor) Encode(ctx context.Context, w io.Writer, img image.Image, options Options) error {
+	return compressor.EncodeContext(ctx, b.c, w, img, options.toInternal(b.c.SupportedFormat()))
+}
+
+// effectiveOptions はエンコード時に実際に適用されるオプションを返します。
+func (b builtinCompressor) effectiveOptions(options Options) Options {
+	effective := b.c.EffectiveOptions(options.toInternal(b.c.SupportedFormat()))
+	return Options{
+		Quality:     effective.Quality,
+		PaletteSize: effective.PaletteSize,
+	}
+}
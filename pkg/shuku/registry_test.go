@@ -0,0 +1,149 @@
+package shuku
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"os"
+	"testing"
+)
+
+// テスト用の独自コンプレッサー
+type fakeCompressor struct {
+	format string
+	calls  int
+}
+
+func (f *fakeCompressor) Compress(img image.Image, options Options) (image.Image, error) {
+	f.calls++
+	return img, nil
+}
+
+func (f *fakeCompressor) CompressBytes(data []byte, options Options) ([]byte, error) {
+	f.calls++
+	return append([]byte("compressed:"), data...), nil
+}
+
+func (f *fakeCompressor) CompressReader(r io.Reader, w io.Writer, options Options) error {
+	f.calls++
+	_, err := io.Copy(w, r)
+	return err
+}
+
+func (f *fakeCompressor) SupportedFormat() string {
+	return f.format
+}
+
+func TestRegister(t *testing.T) {
+	fake := &fakeCompressor{format: "fakefmt"}
+	Register(".FakeFmt", fake)
+	RegisterSniffer("fakefmt", func(header []byte) bool {
+		return bytes.HasPrefix(header, []byte("FAKE"))
+	})
+	t.Cleanup(func() { Unregister("fakefmt") })
+
+	t.Run("Lookupで取得できる", func(t *testing.T) {
+		comp, ok := Lookup("FAKEFMT")
+		if !ok {
+			t.Fatal("登録したコンプレッサーが見つかりません")
+		}
+		if comp != fake {
+			t.Error("登録したコンプレッサーと異なるインスタンスが返されました")
+		}
+	})
+
+	t.Run("Formatsに含まれる", func(t *testing.T) {
+		found := false
+		for _, format := range Formats() {
+			if format == "fakefmt" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Formats() = %v, fakefmt が含まれていません", Formats())
+		}
+	})
+
+	t.Run("Compressが判定関数で形式を検出する", func(t *testing.T) {
+		result, err := Compress([]byte("FAKEDATA"), Options{})
+		if err != nil {
+			t.Fatalf("Compress() error = %v", err)
+		}
+		if string(result) != "compressed:FAKEDATA" {
+			t.Errorf("Compress() = %q, 独自コンプレッサーが使用されていません", result)
+		}
+	})
+
+	t.Run("CompressFileが拡張子から形式を解決する", func(t *testing.T) {
+		inputPath := createTempFile(t, []byte("FAKEDATA"), ".fakefmt")
+		defer os.Remove(inputPath)
+		outputPath := inputPath + ".out"
+		defer os.Remove(outputPath)
+
+		before := fake.calls
+		if err := CompressFile(inputPath, outputPath, Options{}); err != nil {
+			t.Fatalf("CompressFile() error = %v", err)
+		}
+		if fake.calls != before+1 {
+			t.Error("CompressFile() で独自コンプレッサーが呼び出されませんでした")
+		}
+	})
+}
+
+func TestUnregister(t *testing.T) {
+	Register("tmpfmt", &fakeCompressor{format: "tmpfmt"})
+	RegisterSniffer("tmpfmt", func(header []byte) bool {
+		return bytes.HasPrefix(header, []byte("TMP"))
+	})
+	Unregister(".TmpFmt")
+
+	if _, ok := Lookup("tmpfmt"); ok {
+		t.Error("登録を取り消した形式が Lookup で見つかりました")
+	}
+	for _, format := range Formats() {
+		if format == "tmpfmt" {
+			t.Errorf("Formats() = %v, 登録を取り消した形式が含まれています", Formats())
+		}
+	}
+	if _, err := Compress([]byte("TMPDATA"), Options{}); err == nil {
+		t.Error("登録を取り消した形式の判定関数が使用されました")
+	}
+	if _, ok := Lookup("jpeg"); !ok {
+		t.Error("他の形式の登録が取り消されました")
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"空の形式名", func() { Register("", &fakeCompressor{}) }},
+		{"nilのコンプレッサー", func() { Register("nilfmt", nil) }},
+		{"空の形式名の判定関数", func() { RegisterSniffer("", func([]byte) bool { return false }) }},
+		{"nilの判定関数", func() { RegisterSniffer("nilfmt", nil) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("panicが発生しませんでした")
+				}
+			}()
+			tt.fn()
+		})
+	}
+}
+
+func TestLookupBuiltinFormats(t *testing.T) {
+	for _, format := range []string{"jpeg", "jpg", "JPG", ".png", "webp"} {
+		if _, ok := Lookup(format); !ok {
+			t.Errorf("Lookup(%q) 組み込み形式が見つかりません", format)
+		}
+	}
+
+	if _, ok := Lookup("bmp"); ok {
+		t.Error("Lookup(\"bmp\") 未登録の形式が見つかりました")
+	}
+}
@@ -0,0 +1,161 @@
+package shuku
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"io"
+	"time"
+
+	"github.com/takumines/shuku/internal/compressor"
+)
+
+// Report は画像ファイル1件の圧縮結果を表します。
+type Report struct {
+	InputPath  string // 入力ファイルパス
+	OutputPath string // 出力ファイルパス
+	InputSize  int64  // 入力ファイルのバイト数
+	OutputSize int64  // 出力ファイルのバイト数
+	Width      int    // 画像の幅（ピクセル）
+	Height     int    // 画像の高さ（ピクセル）
+	Format     string // ファイルの内容から判定した画像形式
+
+	// Quality と PaletteSize は組み込み形式ではエンコード時に実際に適用された値です。
+	// 適用されない設定は 0 になります。独自のコンプレッサーでは指定された値をそのまま記録します。
+	Quality     int
+	PaletteSize int
+
+	MetadataRemoved bool          // 入力に含まれていたEXIFなどのメタデータが削除されたかどうか
+	DecodeDuration  time.Duration // デコードに要した時間
+	EncodeDuration  time.Duration // エンコードに要した時間（Codec を実装しないコンプレッサーでは処理全体の時間）
+}
+
+// CompressionRatio は入力サイズに対する削減率（%）を返します。
+func (r *Report) CompressionRatio() float64 {
+	if r.InputSize <= 0 {
+		return 0
+	}
+	return 100.0 - (float64(r.OutputSize) / float64(r.InputSize) * 100.0)
+}
+
+// CompressWithReport は画像データを圧縮して w に書き込み、処理内容を Report として返します。
+// 画像形式は data の内容から判定し、判定できない場合は name の拡張子を使用します。
+// name は Report.InputPath とエラーメッセージに使用され、ファイルとして読み込まれることはありません。
+// 返される Report の OutputPath は空のため、必要に応じて呼び出し側で設定してください。
+func CompressWithReport(name string, data []byte, w io.Writer, options Options) (*Report, error) {
+	return CompressWithReportContext(context.Background(), name, data, w, options)
+}
+
+// CompressWithReportContext は ctx を考慮して CompressWithReport を実行します。
+func CompressWithReportContext(ctx context.Context, name string, data []byte, w io.Writer, options Options) (*Report, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	// 画像形式をデータの内容から判定（判定できない場合は拡張子を使用）
+	format, err := resolveFileFormat(name, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// 対応するコンプレッサーを取得
+	comp, ok := Lookup(format)
+	if !ok {
+		return nil, unsupportedFormatError(format, name)
+	}
+
+	report := &Report{
+		InputPath: name,
+		InputSize: int64(len(data)),
+		Format:    format,
+	}
+	output := &countingWriter{w: w}
+	if err := compressWithReport(ctx, comp, data, output, options, report); err != nil {
+		return nil, err
+	}
+	report.OutputSize = output.n
+	return report, nil
+}
+
+// compressWithReport は data を圧縮して w に書き込み、処理内容を report に記録します。
+func compressWithReport(ctx context.Context, comp Compressor, data []byte, w io.Writer, options Options, report *Report) error {
+	resolved := options.forFormat(report.Format)
+	report.Quality = resolved.Quality
+	report.PaletteSize = resolved.PaletteSize
+
+	codec, ok := comp.(Codec)
+	if !ok {
+		// 段階ごとに計測できない場合は処理全体をエンコード時間とする
+		start := time.Now()
+		err := compressReader(ctx, comp, bytes.NewReader(data), w, options)
+		report.EncodeDuration = time.Since(start)
+		return wrapError(StageEncode, report.Format, report.InputPath, err)
+	}
+
+	start := time.Now()
+	img, err := codec.Decode(ctx, bytes.NewReader(data))
+	report.DecodeDuration = time.Since(start)
+	if err != nil {
+		return wrapError(StageDecode, report.Format, report.InputPath, err)
+	}
+
+	if err := encodeWithReport(ctx, codec, img, w, options, report); err != nil {
+		return wrapError(StageEncode, report.Format, report.InputPath, err)
+	}
+
+	// 組み込みのエンコーダーはメタデータを出力しない
+	if _, ok := comp.(builtinCompressor); ok {
+		report.MetadataRemoved = compressor.HasMetadata(report.Format, data)
+	}
+
+	return nil
+}
+
+// encodeWithReport はデコード済みの画像を codec でエンコードして w に書き込み、
+// 画像サイズ・適用されたオプション・エンコード時間を report に記録します。
+func encodeWithReport(ctx context.Context, codec Codec, img image.Image, w io.Writer, options Options, report *Report) error {
+	bounds := img.Bounds()
+	report.Width = bounds.Dx()
+	report.Height = bounds.Dy()
+
+	// 組み込み形式では実際に適用される値を記録
+	if b, ok := codec.(builtinCompressor); ok {
+		effective := b.effectiveOptions(options)
+		report.Quality = effective.Quality
+		report.PaletteSize = effective.PaletteSize
+		if paletted, ok := img.(*image.Paletted); ok {
+			report.PaletteSize = len(paletted.Palette)
+		}
+	}
+
+	start := time.Now()
+	err := codec.Encode(ctx, w, img, options)
+	report.EncodeDuration = time.Since(start)
+	return err
+}
+
+// compressReader は ctx を考慮してコンプレッサーの CompressReader を呼び出します。
+func compressReader(ctx context.Context, comp Compressor, r io.Reader, w io.Writer, options Options) error {
+	if cc, ok := comp.(ContextCompressor); ok {
+		return cc.CompressReaderContext(ctx, r, w, options)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return comp.CompressReader(r, w, options)
+}
+
+// countingWriter は書き込まれたバイト数を数えるライターです。
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,189 @@
+package shuku
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// JPEGデータのSOI直後にEXIFセグメントを挿入
+func addEXIF(data []byte) []byte {
+	payload := []byte("Exif\x00\x00")
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	result := append([]byte{}, data[:2]...)
+	result = append(result, segment...)
+	return append(result, data[2:]...)
+}
+
+func TestCompressFileWithReport(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	tests := []struct {
+		name            string
+		data            []byte
+		suffix          string
+		options         Options
+		format          string
+		quality         int
+		metadataRemoved bool
+	}{
+		{
+			name:            "JPEG（EXIFあり）",
+			data:            addEXIF(createJPEGData(t, 120, 80)),
+			suffix:          ".jpg",
+			options:         Options{Quality: 70},
+			format:          "jpeg",
+			quality:         70,
+			metadataRemoved: true,
+		},
+		{
+			name:    "PNG",
+			data:    createPNGData(t, 120, 80),
+			suffix:  ".png",
+			options: Options{Quality: 70, PaletteSize: 256},
+			format:  "png",
+			quality: 0, // PNGには品質設定が適用されない
+		},
+		{
+			name:    "WebP（形式ごとの設定を優先）",
+			data:    createWebPData(t, 120, 80),
+			suffix:  ".webp",
+			options: Options{Quality: 70, WebP: &WebPOptions{Quality: 90}},
+			format:  "webp",
+			quality: 90,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputPath := createTempFile(t, tt.data, tt.suffix)
+			defer os.Remove(inputPath)
+			outputPath := filepath.Join(tmpDir, "output"+tt.suffix)
+
+			report, err := CompressFileWithReport(inputPath, outputPath, tt.options)
+			if err != nil {
+				t.Fatalf("CompressFileWithReport() error = %v", err)
+			}
+
+			outputInfo, err := os.Stat(outputPath)
+			if err != nil {
+				t.Fatalf("出力ファイルが作成されませんでした: %v", err)
+			}
+
+			if report.InputSize != int64(len(tt.data)) {
+				t.Errorf("InputSize = %v, want %v", report.InputSize, len(tt.data))
+			}
+			if report.OutputSize != outputInfo.Size() {
+				t.Errorf("OutputSize = %v, want %v", report.OutputSize, outputInfo.Size())
+			}
+			if report.Width != 120 || report.Height != 80 {
+				t.Errorf("画像サイズ = %dx%d, want 120x80", report.Width, report.Height)
+			}
+			if report.Format != tt.format {
+				t.Errorf("Format = %v, want %v", report.Format, tt.format)
+			}
+			if report.Quality != tt.quality {
+				t.Errorf("Quality = %v, want %v", report.Quality, tt.quality)
+			}
+			if report.MetadataRemoved != tt.metadataRemoved {
+				t.Errorf("MetadataRemoved = %v, want %v", report.MetadataRemoved, tt.metadataRemoved)
+			}
+			if report.DecodeDuration <= 0 || report.EncodeDuration <= 0 {
+				t.Errorf("処理時間が記録されていません: decode=%v, encode=%v", report.DecodeDuration, report.EncodeDuration)
+			}
+		})
+	}
+}
+
+func TestCompressFileWithReport_CustomCompressor(t *testing.T) {
+	fake := &fakeCompressor{format: "reportfmt"}
+	Register("reportfmt", fake)
+	t.Cleanup(func() { Unregister("reportfmt") })
+
+	inputPath := createTempFile(t, []byte("REPORTDATA"), ".reportfmt")
+	defer os.Remove(inputPath)
+	outputPath := filepath.Join(t.TempDir(), "output.reportfmt")
+
+	report, err := CompressFileWithReport(inputPath, outputPath, Options{Quality: 42})
+	if err != nil {
+		t.Fatalf("CompressFileWithReport() error = %v", err)
+	}
+
+	// 段階に分けられないコンプレッサーでは指定値と全体時間のみ記録される
+	if report.Quality != 42 {
+		t.Errorf("Quality = %v, want 42", report.Quality)
+	}
+	if report.Width != 0 || report.DecodeDuration != 0 {
+		t.Errorf("Codec を実装しないコンプレッサーで画像サイズ・デコード時間が記録されました: %+v", report)
+	}
+	if report.OutputSize != int64(len("REPORTDATA")) {
+		t.Errorf("OutputSize = %v, want %v", report.OutputSize, len("REPORTDATA"))
+	}
+}
+
+func TestCompressWithReport(t *testing.T) {
+	tests := []struct {
+		name    string
+		fname   string
+		data    []byte
+		format  string
+		wantErr error
+	}{
+		{"内容から形式を判定", "photo.png", createJPEGData(t, 60, 40), "jpeg", nil},
+		{"判定できない場合は拡張子を使用", "data.reportfmt", []byte("REPORTDATA"), "reportfmt", nil},
+		{"未対応の形式", "notes.txt", []byte("text"), "", ErrUnsupportedFormat},
+		{"不正な画像", "broken.jpg", []byte{0xFF, 0xD8, 0xFF, 0x00}, "", ErrInvalidImage},
+	}
+	Register("reportfmt", &fakeCompressor{format: "reportfmt"})
+	t.Cleanup(func() { Unregister("reportfmt") })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			report, err := CompressWithReport(tt.fname, tt.data, &buf, Options{Quality: 70})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("CompressWithReport() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CompressWithReport() error = %v", err)
+			}
+			if report.InputPath != tt.fname || report.OutputPath != "" {
+				t.Errorf("InputPath = %q, OutputPath = %q", report.InputPath, report.OutputPath)
+			}
+			if report.Format != tt.format {
+				t.Errorf("Format = %v, want %v", report.Format, tt.format)
+			}
+			if report.OutputSize != int64(buf.Len()) {
+				t.Errorf("OutputSize = %v, want %v", report.OutputSize, buf.Len())
+			}
+		})
+	}
+}
+
+func TestReport_CompressionRatio(t *testing.T) {
+	tests := []struct {
+		name     string
+		report   Report
+		expected float64
+	}{
+		{"半分に圧縮", Report{InputSize: 1000, OutputSize: 500}, 50},
+		{"入力サイズ0", Report{InputSize: 0, OutputSize: 10}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.report.CompressionRatio(); got != tt.expected {
+				t.Errorf("CompressionRatio() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
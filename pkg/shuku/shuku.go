@@ -2,37 +2,35 @@
 package shuku
 
 import (
-	"errors"
+	"bytes"
+	"context"
 	"image"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/takumines/shuku/internal/compressor"
+	"github.com/takumines/shuku/internal/fileutil"
 )
 
-// 画像形式に対応するコンプレッサーのマップ
-var compressors = map[string]compressor.Compressor{}
-
-// コンプレッサーの登録
-func init() {
-	// JPEGコンプレッサーを登録
-	jpegCompressor := compressor.NewJPEGCompressor()
-	compressors[jpegCompressor.SupportedFormat()] = jpegCompressor
-	compressors["jpg"] = jpegCompressor // jpgも同じコンプレッサーで対応
-
-	// PNGコンプレッサーを登録
-	pngCompressor := compressor.NewPNGCompressor()
-	compressors[pngCompressor.SupportedFormat()] = pngCompressor
-
-	// WebPコンプレッサーを登録
-	webpCompressor := compressor.NewWebPCompressor()
-	compressors[webpCompressor.SupportedFormat()] = webpCompressor
-}
+// 形式判定に使用する先頭バイト数
+const sniffLen = 512
 
 // Compress はバイトスライスとして提供された画像データを圧縮します。
 // 画像形式は入力データから自動検出されます。
 func Compress(data []byte, options Options) ([]byte, error) {
+	return CompressContext(context.Background(), data, options)
+}
+
+// CompressContext は ctx を考慮して Compress を実行します。
+// オプションに範囲外の値がある場合は、圧縮を行わずに ErrInvalidOptions のエラーを返します。
+// ctx がキャンセルされると、デコード・エンコードの途中でも処理を中断して ctx.Err() を返します。
+func CompressContext(ctx context.Context, data []byte, options Options) ([]byte, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
 	// 画像形式の検出
 	format, err := detectImageFormat(data)
 	if err != nil {
@@ -40,52 +38,99 @@ func Compress(data []byte, options Options) ([]byte, error) {
 	}
 
 	// 対応するコンプレッサーを取得
-	comp, ok := compressors[format]
+	comp, ok := Lookup(format)
 	if !ok {
-		return nil, errors.New("サポートされていない画像形式です: " + format)
-	}
-
-	// 内部オプションに変換
-	internalOpts := compressor.Options{
-		Quality:     options.Quality,
-		PaletteSize: options.PaletteSize,
+		return nil, unsupportedFormatError(format, "")
 	}
 
 	// 圧縮を実行
-	return comp.CompressBytes(data, internalOpts)
+	var result []byte
+	if cc, ok := comp.(ContextCompressor); ok {
+		result, err = cc.CompressBytesContext(ctx, data, options)
+	} else {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result, err = comp.CompressBytes(data, options)
+	}
+	if err != nil {
+		return nil, wrapError(StageEncode, format, "", err)
+	}
+	return result, nil
 }
 
 // CompressImage は画像インターフェースを圧縮します。
-// 画像形式はSupportedFormat()から取得されます。
+// 画像形式は登録済みの形式名（Formats()）から指定します。
 func CompressImage(img image.Image, format string, options Options) (image.Image, error) {
-	// 形式を小文字に変換
-	format = strings.ToLower(format)
+	return CompressImageContext(context.Background(), img, format, options)
+}
 
-	// 対応するコンプレッサーを取得
-	comp, ok := compressors[format]
-	if !ok {
-		return nil, errors.New("サポートされていない画像形式です: " + format)
+// CompressImageContext は ctx を考慮して CompressImage を実行します。
+func CompressImageContext(ctx context.Context, img image.Image, format string, options Options) (image.Image, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
 	}
 
-	// 内部オプションに変換
-	internalOpts := compressor.Options{
-		Quality:     options.Quality,
-		PaletteSize: options.PaletteSize,
+	// 対応するコンプレッサーを取得
+	comp, ok := Lookup(format)
+	if !ok {
+		return nil, unsupportedFormatError(normalizeFormat(format), "")
 	}
 
 	// 圧縮を実行
-	return comp.Compress(img, internalOpts)
+	var result image.Image
+	var err error
+	if cc, ok := comp.(ContextCompressor); ok {
+		result, err = cc.CompressContext(ctx, img, options)
+	} else {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result, err = comp.Compress(img, options)
+	}
+	if err != nil {
+		return nil, wrapError(StageEncode, normalizeFormat(format), "", err)
+	}
+	return result, nil
 }
 
 // CompressFile はファイルパスを指定して画像ファイルを圧縮します。
 // 出力ファイルが指定されていない場合は、入力ファイルの名前に "_compressed" を追加します。
+// 画像形式はファイルの内容から判定され、判定できない場合のみ拡張子が使用されます。
+// 出力は同じディレクトリの一時ファイルに書き込まれ、圧縮が成功した場合のみ置き換えられます。
+// 出力パスに入力パスを指定すると、入力ファイルを圧縮結果で安全に上書きします。
 func CompressFile(inputPath, outputPath string, options Options) error {
-	// 入力ファイルを開く
-	inputFile, err := os.Open(inputPath)
+	return CompressFileContext(context.Background(), inputPath, outputPath, options)
+}
+
+// CompressFileContext は ctx を考慮して CompressFile を実行します。
+func CompressFileContext(ctx context.Context, inputPath, outputPath string, options Options) error {
+	_, err := CompressFileWithReportContext(ctx, inputPath, outputPath, options)
+	return err
+}
+
+// CompressFileWithReport は CompressFile と同様に画像ファイルを圧縮し、
+// 入出力サイズや画像サイズ、所要時間などをまとめた Report を返します。
+func CompressFileWithReport(inputPath, outputPath string, options Options) (*Report, error) {
+	return CompressFileWithReportContext(context.Background(), inputPath, outputPath, options)
+}
+
+// CompressFileWithReportContext は ctx を考慮して CompressFileWithReport を実行します。
+func CompressFileWithReportContext(ctx context.Context, inputPath, outputPath string, options Options) (*Report, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	// 入力ファイルを読み込む
+	// 入力全体をメモリに読み込んでから出力を書き込むため、出力先が入力と同じでも安全に置き換えられる
+	inputInfo, data, release, err := readInputFile(inputPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer inputFile.Close()
+	defer release()
 
 	// 出力パスが指定されていない場合は、デフォルトのパスを生成
 	if outputPath == "" {
@@ -94,53 +139,133 @@ func CompressFile(inputPath, outputPath string, options Options) error {
 		outputPath = baseName + "_compressed" + ext
 	}
 
-	// 出力ファイルを作成
-	outputFile, err := os.Create(outputPath)
-	if err != nil {
+	// 出力先が入力と同じファイル（シンボリックリンク経由を含む）の場合は、
+	// リンク自体を通常ファイルで置き換えないよう実体のパスに書き込む
+	if outputInfo, err := os.Stat(outputPath); err == nil && os.SameFile(inputInfo, outputInfo) {
+		if resolved, err := filepath.EvalSymlinks(outputPath); err == nil {
+			outputPath = resolved
+		}
+	}
+
+	// 一時ファイルに圧縮結果を書き込み、成功した場合のみ出力先に置き換える
+	var report *Report
+	err = fileutil.WriteFileAtomic(outputPath, fileutil.OutputMode(inputInfo, outputPath), func(w io.Writer) error {
+		var err error
+		report, err = CompressWithReportContext(ctx, inputPath, data, w, options)
 		return err
+	})
+	if err != nil {
+		format := ""
+		if report != nil {
+			format = report.Format
+		}
+		return nil, wrapError(StageWrite, format, outputPath, err)
 	}
-	defer outputFile.Close()
+	report.OutputPath = outputPath
 
-	// 画像形式を拡張子から取得
-	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(inputPath), "."))
-	if format == "" {
-		return errors.New("ファイル拡張子から画像形式を判別できません")
+	return report, nil
+}
+
+// readInputFile は入力ファイルの情報と内容を読み込みます。
+// 内容はプールのバッファに読み込まれ、release を呼び出すまで有効です。
+func readInputFile(path string) (os.FileInfo, []byte, func(), error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, nil, wrapError(StageRead, "", path, err)
 	}
+	defer file.Close()
 
-	// 対応するコンプレッサーを取得
-	comp, ok := compressors[format]
-	if !ok {
-		return errors.New("サポートされていない画像形式です: " + format)
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, nil, wrapError(StageRead, "", path, err)
 	}
 
-	// 内部オプションに変換
-	internalOpts := compressor.Options{
-		Quality:     options.Quality,
-		PaletteSize: options.PaletteSize,
+	buf := compressor.GetBuffer()
+	buf.Grow(int(info.Size()) + bytes.MinRead)
+	if _, err := buf.ReadFrom(file); err != nil {
+		compressor.PutBuffer(buf)
+		return nil, nil, nil, wrapError(StageRead, "", path, err)
 	}
+	return info, buf.Bytes(), func() { compressor.PutBuffer(buf) }, nil
+}
 
-	// 圧縮を実行
-	return comp.CompressReader(inputFile, outputFile, internalOpts)
+// DetectFormat は画像データの内容から形式を判定します。
+// 登録済みの判定関数（RegisterSniffer）で判定できない場合はエラーを返します。
+func DetectFormat(data []byte) (string, error) {
+	return detectImageFormat(data)
 }
 
-// 画像データからフォーマットを検出する関数
-func detectImageFormat(data []byte) (string, error) {
-	// JPEGのシグネチャを確認
-	if len(data) >= 3 && data[0] == 0xFF && data[1] == 0xD8 && data[2] == 0xFF {
-		return "jpeg", nil
+// DetectFileFormat は画像ファイルの先頭バイトから形式を判定します。
+// 拡張子は使用しません。
+func DetectFileFormat(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", wrapError(StageRead, "", path, err)
 	}
+	defer file.Close()
 
-	// PNGのシグネチャを確認
-	if len(data) >= 8 && data[0] == 0x89 && data[1] == 0x50 && data[2] == 0x4E && data[3] == 0x47 &&
-		data[4] == 0x0D && data[5] == 0x0A && data[6] == 0x1A && data[7] == 0x0A {
-		return "png", nil
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", wrapError(StageRead, "", path, err)
 	}
 
-	// WebPのシグネチャを確認（RIFFとWEBP）
-	if len(data) >= 12 && data[0] == 0x52 && data[1] == 0x49 && data[2] == 0x46 && data[3] == 0x46 &&
-		data[8] == 0x57 && data[9] == 0x45 && data[10] == 0x42 && data[11] == 0x50 {
-		return "webp", nil
+	format, err := detectImageFormat(header[:n])
+	if err != nil {
+		return "", wrapError(StageDetect, "", path, err)
+	}
+	return format, nil
+}
+
+// ExtensionMatchesFormat はファイルの拡張子が指定された形式と一致するかどうかを判定します。
+// "jpg" と "jpeg" のように同じコンプレッサーに登録された別名は一致するものとみなします。
+func ExtensionMatchesFormat(path, format string) bool {
+	ext := normalizeFormat(filepath.Ext(path))
+	format = normalizeFormat(format)
+	if ext == format {
+		return true
+	}
+
+	extComp, ok := Lookup(ext)
+	if !ok {
+		return false
+	}
+	formatComp, ok := Lookup(format)
+	if !ok {
+		return false
+	}
+	return strings.EqualFold(extComp.SupportedFormat(), formatComp.SupportedFormat())
+}
+
+// CorrectExtension はファイルパスの拡張子を指定された形式に合わせたパスを返します。
+// 拡張子が既に形式と一致している場合は、パスをそのまま返します。
+func CorrectExtension(path, format string) string {
+	if ExtensionMatchesFormat(path, format) {
+		return path
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "." + normalizeFormat(format)
+}
+
+// resolveFileFormat はファイルの内容から形式を判定します。
+// 内容から判定できない場合は、拡張子から形式を判定します。
+func resolveFileFormat(path string, header []byte) (string, error) {
+	if format, err := detectImageFormat(header); err == nil {
+		return format, nil
+	}
+
+	format := normalizeFormat(filepath.Ext(path))
+	if format == "" {
+		return "", unsupportedFormatError("", path)
+	}
+	return format, nil
+}
+
+// 画像データからフォーマットを検出する関数
+// 登録済みの判定関数（RegisterSniffer）を順に評価します。
+func detectImageFormat(data []byte) (string, error) {
+	if format, ok := sniffFormat(data); ok {
+		return format, nil
 	}
 
-	return "", errors.New("サポートされていない、または認識できない画像形式です")
+	return "", unsupportedFormatError("", "")
 }
@@ -2,6 +2,8 @@ package shuku
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"image"
 	"image/color"
 	"image/jpeg"
@@ -435,3 +437,243 @@ func TestDetectImageFormat(t *testing.T) {
 		}
 	})
 }
+
+func TestCompressContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	t.Run("CompressContext", func(t *testing.T) {
+		_, err := CompressContext(ctx, createJPEGData(t, 50, 50), Options{Quality: 80})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("CompressContext() error = %v, want %v", err, context.Canceled)
+		}
+	})
+
+	t.Run("CompressImageContext", func(t *testing.T) {
+		_, err := CompressImageContext(ctx, createTestImage(50, 50), "png", Options{})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("CompressImageContext() error = %v, want %v", err, context.Canceled)
+		}
+	})
+
+	t.Run("CompressFileContext", func(t *testing.T) {
+		inputPath := createTempFile(t, createWebPData(t, 50, 50), ".webp")
+		defer os.Remove(inputPath)
+		outputPath := filepath.Join(t.TempDir(), "output.webp")
+
+		err := CompressFileContext(ctx, inputPath, outputPath, Options{Quality: 80})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("CompressFileContext() error = %v, want %v", err, context.Canceled)
+		}
+		if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+			t.Error("キャンセル後に出力ファイルが作成されました")
+		}
+	})
+}
+
+func TestCompressFileDetectsContent(t *testing.T) {
+	// PNGデータを .jpg 拡張子で保存
+	inputPath := createTempFile(t, createPNGData(t, 80, 80), ".jpg")
+	defer os.Remove(inputPath)
+	outputPath := filepath.Join(t.TempDir(), "output.png")
+
+	if err := CompressFile(inputPath, outputPath, Options{PaletteSize: 256}); err != nil {
+		t.Fatalf("CompressFile() error = %v", err)
+	}
+
+	format, err := DetectFileFormat(outputPath)
+	if err != nil {
+		t.Fatalf("DetectFileFormat() error = %v", err)
+	}
+	if format != "png" {
+		t.Errorf("出力形式 = %v, want png", format)
+	}
+}
+
+func TestDetectFileFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		dataFunc func(*testing.T, int, int) []byte
+		suffix   string
+		expected string
+	}{
+		{"JPEG", createJPEGData, ".jpg", "jpeg"},
+		{"PNG（拡張子不一致）", createPNGData, ".jpg", "png"},
+		{"WebP（拡張子なし）", createWebPData, "", "webp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := createTempFile(t, tt.dataFunc(t, 30, 30), tt.suffix)
+			defer os.Remove(path)
+
+			format, err := DetectFileFormat(path)
+			if err != nil {
+				t.Fatalf("DetectFileFormat() error = %v", err)
+			}
+			if format != tt.expected {
+				t.Errorf("DetectFileFormat() = %v, want %v", format, tt.expected)
+			}
+		})
+	}
+
+	t.Run("存在しないファイル", func(t *testing.T) {
+		if _, err := DetectFileFormat("/path/to/nonexistent/file.jpg"); err == nil {
+			t.Error("存在しないファイルに対してエラーが発生しませんでした")
+		}
+	})
+}
+
+func TestExtensionMatchesFormat(t *testing.T) {
+	tests := []struct {
+		path     string
+		format   string
+		expected bool
+	}{
+		{"photo.jpg", "jpeg", true},
+		{"photo.JPEG", "jpeg", true},
+		{"photo.jpg", "png", false},
+		{"image.webp", "webp", true},
+		{"image", "png", false},
+		{"image.bmp", "png", false},
+	}
+
+	for _, tt := range tests {
+		if got := ExtensionMatchesFormat(tt.path, tt.format); got != tt.expected {
+			t.Errorf("ExtensionMatchesFormat(%q, %q) = %v, want %v", tt.path, tt.format, got, tt.expected)
+		}
+	}
+}
+
+func TestCorrectExtension(t *testing.T) {
+	tests := []struct {
+		path     string
+		format   string
+		expected string
+	}{
+		{filepath.Join("out", "photo.jpg"), "png", filepath.Join("out", "photo.png")},
+		{filepath.Join("out", "photo.jpg"), "jpeg", filepath.Join("out", "photo.jpg")},
+		{"image", "webp", "image.webp"},
+	}
+
+	for _, tt := range tests {
+		if got := CorrectExtension(tt.path, tt.format); got != tt.expected {
+			t.Errorf("CorrectExtension(%q, %q) = %v, want %v", tt.path, tt.format, got, tt.expected)
+		}
+	}
+}
+
+func TestCompressFileAtomicWrite(t *testing.T) {
+	t.Run("デコード失敗時に出力ファイルを残さない", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		inputPath := filepath.Join(tmpDir, "broken.jpg")
+		// JPEGのシグネチャのみを持つ壊れたデータ
+		if err := os.WriteFile(inputPath, []byte{0xFF, 0xD8, 0xFF, 0x00, 0x01}, 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+		}
+		outputPath := filepath.Join(tmpDir, "output.jpg")
+
+		if err := CompressFile(inputPath, outputPath, Options{Quality: 80}); err == nil {
+			t.Fatal("壊れた画像に対してエラーが発生しませんでした")
+		}
+		if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+			t.Error("失敗時に出力ファイルが作成されました")
+		}
+
+		entries, _ := os.ReadDir(tmpDir)
+		if len(entries) != 1 {
+			t.Errorf("一時ファイルが残っています: %v", entries)
+		}
+	})
+
+	t.Run("失敗時に既存の出力ファイルを変更しない", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		inputPath := filepath.Join(tmpDir, "input.bmp")
+		if err := os.WriteFile(inputPath, []byte("not an image"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+		}
+		outputPath := filepath.Join(tmpDir, "output.jpg")
+		if err := os.WriteFile(outputPath, []byte("existing"), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+		}
+
+		if err := CompressFile(inputPath, outputPath, Options{Quality: 80}); err == nil {
+			t.Fatal("サポートされていない形式に対してエラーが発生しませんでした")
+		}
+		data, _ := os.ReadFile(outputPath)
+		if string(data) != "existing" {
+			t.Errorf("既存の出力ファイルが変更されました: %q", data)
+		}
+	})
+
+	t.Run("入力と同じパスに出力", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		inputPath := filepath.Join(tmpDir, "image.png")
+		original := createPNGData(t, 60, 60)
+		if err := os.WriteFile(inputPath, original, 0640); err != nil {
+			t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+		}
+		if err := os.Chmod(inputPath, 0640); err != nil {
+			t.Fatalf("パーミッションの設定に失敗しました: %v", err)
+		}
+
+		if err := CompressFile(inputPath, inputPath, Options{PaletteSize: 256}); err != nil {
+			t.Fatalf("CompressFile() error = %v", err)
+		}
+
+		// 圧縮結果が有効な画像であること
+		if format, err := DetectFileFormat(inputPath); err != nil || format != "png" {
+			t.Errorf("上書き後のファイルが有効なPNGではありません: format=%v, err=%v", format, err)
+		}
+
+		// パーミッションが維持されていること
+		info, _ := os.Stat(inputPath)
+		if info.Mode().Perm() != 0640 {
+			t.Errorf("パーミッション = %v, want %v", info.Mode().Perm(), os.FileMode(0640))
+		}
+	})
+
+	t.Run("入力へのシンボリックリンクに出力", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		inputPath := filepath.Join(tmpDir, "image.jpg")
+		if err := os.WriteFile(inputPath, createJPEGData(t, 60, 60), 0644); err != nil {
+			t.Fatalf("テストファイルの作成に失敗しました: %v", err)
+		}
+		linkPath := filepath.Join(tmpDir, "link.jpg")
+		if err := os.Symlink(inputPath, linkPath); err != nil {
+			t.Skipf("シンボリックリンクを作成できません: %v", err)
+		}
+
+		if err := CompressFile(inputPath, linkPath, Options{Quality: 50}); err != nil {
+			t.Fatalf("CompressFile() error = %v", err)
+		}
+
+		info, err := os.Lstat(linkPath)
+		if err != nil {
+			t.Fatalf("シンボリックリンクの確認に失敗しました: %v", err)
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			t.Error("シンボリックリンクが通常ファイルで置き換えられました")
+		}
+	})
+}
+
+func BenchmarkCompress(b *testing.B) {
+	img := createTestImage(1024, 768)
+	for _, format := range []string{"jpeg", "png", "webp"} {
+		encoded, err := EncodeImage(img, format, Options{Quality: 90})
+		if err != nil {
+			b.Fatal(err)
+		}
+		data := encoded.Bytes()
+
+		b.Run(format, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Compress(data, Options{Quality: 80}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
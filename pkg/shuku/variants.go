@@ -0,0 +1,212 @@
+package shuku
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/takumines/shuku/internal/compressor"
+	"github.com/takumines/shuku/internal/fileutil"
+)
+
+// OutputSpec は CompressFileVariants で生成する出力1件の指定です。
+type OutputSpec struct {
+	Path      string  // 出力ファイルパス（必須）
+	Format    string  // 出力形式。空の場合は Path の拡張子から判定します
+	MaxWidth  int     // 出力の最大幅。0 の場合は制限しません
+	MaxHeight int     // 出力の最大高さ。0 の場合は制限しません
+	Options   Options // 圧縮オプション
+}
+
+// CompressFileVariants は入力ファイルを一度だけデコードし、specs の指定ごとに
+// 形式・サイズ・品質の異なる出力ファイルを生成します。
+// 各出力のエンコードはデコード済みの画像を共有して並行に実行され、Report は specs と同じ順序で返されます。
+// 一部の出力が失敗した場合も他の出力は生成され、失敗した出力の Report は nil になります。
+// 返されるエラーは失敗したすべての出力のエラーをまとめたものです。
+func CompressFileVariants(inputPath string, specs []OutputSpec) ([]*Report, error) {
+	return CompressFileVariantsContext(context.Background(), inputPath, specs)
+}
+
+// CompressFileVariantsContext は ctx を考慮して CompressFileVariants を実行します。
+func CompressFileVariantsContext(ctx context.Context, inputPath string, specs []OutputSpec) ([]*Report, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// デコードの前にすべての出力指定を検証する
+	codecs, err := resolveOutputSpecs(specs)
+	if err != nil {
+		return nil, err
+	}
+
+	// 入力ファイルを読み込む
+	inputInfo, data, release, err := readInputFile(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// 入力形式を判定してデコード
+	inputFormat, err := resolveFileFormat(inputPath, data)
+	if err != nil {
+		return nil, err
+	}
+	comp, ok := Lookup(inputFormat)
+	if !ok {
+		return nil, unsupportedFormatError(inputFormat, inputPath)
+	}
+	decoder, ok := comp.(Codec)
+	if !ok {
+		// 段階ごとに実行できないコンプレッサーではデコード結果を共有できない
+		return nil, unsupportedFormatError(inputFormat, inputPath)
+	}
+
+	start := time.Now()
+	img, err := decoder.Decode(ctx, bytes.NewReader(data))
+	decodeDuration := time.Since(start)
+	if err != nil {
+		return nil, wrapError(StageDecode, inputFormat, inputPath, err)
+	}
+
+	// 組み込みのエンコーダーはメタデータを出力しない
+	hasMetadata := false
+	if _, ok := comp.(builtinCompressor); ok {
+		hasMetadata = compressor.HasMetadata(inputFormat, data)
+	}
+
+	// 各出力を並行にエンコード
+	reports := make([]*Report, len(specs))
+	errs := make([]error, len(specs))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, spec := range specs {
+		wg.Add(1)
+		go func(i int, spec OutputSpec) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			report := &Report{
+				InputPath:      inputPath,
+				OutputPath:     spec.Path,
+				InputSize:      int64(len(data)),
+				Format:         codecs[i].SupportedFormat(),
+				DecodeDuration: decodeDuration,
+			}
+			if err := encodeVariant(ctx, codecs[i], img, inputInfo, spec, report); err != nil {
+				errs[i] = err
+				return
+			}
+			if _, ok := codecs[i].(builtinCompressor); ok {
+				report.MetadataRemoved = hasMetadata
+			}
+			reports[i] = report
+		}(i, spec)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return reports, err
+	}
+	return reports, errors.Join(errs...)
+}
+
+// resolveOutputSpecs は出力指定を検証し、それぞれの出力形式のコンプレッサーを返します。
+func resolveOutputSpecs(specs []OutputSpec) ([]Codec, error) {
+	if len(specs) == 0 {
+		return nil, errors.New("出力が指定されていません")
+	}
+
+	codecs := make([]Codec, len(specs))
+	paths := make(map[string]bool, len(specs))
+	for i, spec := range specs {
+		if spec.Path == "" {
+			return nil, fmt.Errorf("%d番目の出力のパスが指定されていません", i+1)
+		}
+		cleaned := filepath.Clean(spec.Path)
+		if paths[cleaned] {
+			return nil, fmt.Errorf("出力パスが重複しています: %s", spec.Path)
+		}
+		paths[cleaned] = true
+
+		if spec.MaxWidth < 0 || spec.MaxHeight < 0 {
+			return nil, fmt.Errorf("%w: 出力サイズが不正です: %dx%d (%s)", ErrInvalidOptions, spec.MaxWidth, spec.MaxHeight, spec.Path)
+		}
+		if err := spec.Options.Validate(); err != nil {
+			return nil, err
+		}
+
+		format := spec.Format
+		if format == "" {
+			format = filepath.Ext(spec.Path)
+		}
+		format = normalizeFormat(format)
+		comp, ok := Lookup(format)
+		if !ok {
+			return nil, unsupportedFormatError(format, spec.Path)
+		}
+		codec, ok := comp.(Codec)
+		if !ok {
+			return nil, unsupportedFormatError(format, spec.Path)
+		}
+		codecs[i] = codec
+	}
+	return codecs, nil
+}
+
+// encodeVariant はデコード済みの画像を出力指定に合わせて縮小・エンコードし、出力ファイルに書き込みます。
+// img は他の出力と共有されるため変更しません。
+func encodeVariant(ctx context.Context, codec Codec, img image.Image, inputInfo os.FileInfo, spec OutputSpec, report *Report) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// 透過を扱えない形式では、透明部分が黒く潰れないよう白背景に合成する
+	transforms := []compressor.Transform{compressor.Resize(spec.MaxWidth, spec.MaxHeight)}
+	if !supportsAlpha(report.Format) {
+		transforms = append([]compressor.Transform{compressor.FlattenAlpha(color.White)}, transforms...)
+	}
+
+	// 縮小した画像の画素バッファはエンコード後に再利用する
+	resized, release, err := compressor.TransformPooled(img, compressor.Options{}, transforms...)
+	if err != nil {
+		return wrapError(StageTransform, report.Format, spec.Path, err)
+	}
+	defer release()
+
+	resolved := spec.Options.forFormat(report.Format)
+	report.Quality = resolved.Quality
+	report.PaletteSize = resolved.PaletteSize
+
+	err = fileutil.WriteFileAtomic(spec.Path, fileutil.OutputMode(inputInfo, spec.Path), func(w io.Writer) error {
+		output := &countingWriter{w: w}
+		if err := encodeWithReport(ctx, codec, resized, output, spec.Options, report); err != nil {
+			return wrapError(StageEncode, report.Format, spec.Path, err)
+		}
+		report.OutputSize = output.n
+		return nil
+	})
+	if err != nil {
+		return wrapError(StageWrite, report.Format, spec.Path, err)
+	}
+	return nil
+}
+
+// supportsAlpha は format の出力が透過を保持できるかを返します。
+func supportsAlpha(format string) bool {
+	switch format {
+	case "jpeg", "jpg":
+		return false
+	default:
+		return true
+	}
+}
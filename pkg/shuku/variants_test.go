@@ -0,0 +1,203 @@
+package shuku
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompressFileVariants(t *testing.T) {
+	inputPath := createTempFile(t, addEXIF(createJPEGData(t, 400, 200)), ".jpg")
+	defer os.Remove(inputPath)
+	outDir := t.TempDir()
+
+	specs := []OutputSpec{
+		{Path: filepath.Join(outDir, "original.webp"), Options: Options{Quality: 90}},
+		{Path: filepath.Join(outDir, "fallback.jpg"), Options: Options{Quality: 70}},
+		{Path: filepath.Join(outDir, "thumb_large"), Format: "jpeg", MaxWidth: 200, Options: Options{Quality: 60}},
+		{Path: filepath.Join(outDir, "thumb_medium.png"), MaxWidth: 100, MaxHeight: 100},
+		{Path: filepath.Join(outDir, "thumb_small.webp"), MaxHeight: 20, Options: Options{Quality: 50}},
+	}
+
+	reports, err := CompressFileVariants(inputPath, specs)
+	if err != nil {
+		t.Fatalf("CompressFileVariants() error = %v", err)
+	}
+	if len(reports) != len(specs) {
+		t.Fatalf("Report数 = %d, want %d", len(reports), len(specs))
+	}
+
+	tests := []struct {
+		format          string
+		width, height   int
+		quality         int
+		metadataRemoved bool
+	}{
+		{"webp", 400, 200, 90, true},
+		{"jpeg", 400, 200, 70, true},
+		{"jpeg", 200, 100, 60, true},
+		{"png", 100, 50, 0, true},
+		{"webp", 40, 20, 50, true},
+	}
+
+	for i, tt := range tests {
+		report := reports[i]
+		spec := specs[i]
+		t.Run(filepath.Base(spec.Path), func(t *testing.T) {
+			if report == nil {
+				t.Fatal("Report が nil です")
+			}
+			if report.OutputPath != spec.Path {
+				t.Errorf("OutputPath = %v, want %v", report.OutputPath, spec.Path)
+			}
+			if report.Format != tt.format {
+				t.Errorf("Format = %v, want %v", report.Format, tt.format)
+			}
+			if report.Width != tt.width || report.Height != tt.height {
+				t.Errorf("画像サイズ = %dx%d, want %dx%d", report.Width, report.Height, tt.width, tt.height)
+			}
+			if report.Quality != tt.quality {
+				t.Errorf("Quality = %v, want %v", report.Quality, tt.quality)
+			}
+			if report.MetadataRemoved != tt.metadataRemoved {
+				t.Errorf("MetadataRemoved = %v, want %v", report.MetadataRemoved, tt.metadataRemoved)
+			}
+
+			// 出力ファイルの内容が指定した形式であること
+			format, err := DetectFileFormat(spec.Path)
+			if err != nil {
+				t.Fatalf("DetectFileFormat() error = %v", err)
+			}
+			if format != tt.format {
+				t.Errorf("出力ファイルの形式 = %v, want %v", format, tt.format)
+			}
+			info, err := os.Stat(spec.Path)
+			if err != nil || info.Size() != report.OutputSize {
+				t.Errorf("OutputSize = %v, 出力ファイルのサイズと一致しません (%v)", report.OutputSize, err)
+			}
+		})
+	}
+
+	// すべての出力でデコード時間は共有される
+	for _, report := range reports[1:] {
+		if report.DecodeDuration != reports[0].DecodeDuration {
+			t.Errorf("DecodeDuration が出力ごとに異なります: %v, %v", report.DecodeDuration, reports[0].DecodeDuration)
+		}
+	}
+}
+
+func TestCompressFileVariantsErrors(t *testing.T) {
+	inputPath := createTempFile(t, createPNGData(t, 40, 40), ".png")
+	defer os.Remove(inputPath)
+	outDir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		specs   []OutputSpec
+		wantErr error
+	}{
+		{"出力の指定なし", nil, nil},
+		{"パスの指定なし", []OutputSpec{{Format: "png"}}, nil},
+		{"パスの重複", []OutputSpec{{Path: filepath.Join(outDir, "a.png")}, {Path: filepath.Join(outDir, ".", "a.png")}}, nil},
+		{"未登録の形式", []OutputSpec{{Path: filepath.Join(outDir, "a.bmp")}}, ErrUnsupportedFormat},
+		{"範囲外の品質", []OutputSpec{{Path: filepath.Join(outDir, "a.jpg"), Options: Options{Quality: 101}}}, ErrInvalidOptions},
+		{"負のサイズ", []OutputSpec{{Path: filepath.Join(outDir, "a.jpg"), MaxWidth: -1}}, ErrInvalidOptions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CompressFileVariants(inputPath, tt.specs)
+			if err == nil {
+				t.Fatal("エラーが返されませんでした")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("CompressFileVariants() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// 検証エラーの場合は出力が作成されない
+	entries, _ := os.ReadDir(outDir)
+	if len(entries) != 0 {
+		t.Errorf("検証エラーで出力が作成されました: %v", entries)
+	}
+}
+
+func TestCompressFileVariantsPartialFailure(t *testing.T) {
+	inputPath := createTempFile(t, createPNGData(t, 40, 40), ".png")
+	defer os.Remove(inputPath)
+	outDir := t.TempDir()
+
+	specs := []OutputSpec{
+		{Path: filepath.Join(outDir, "ok.png")},
+		{Path: filepath.Join(outDir, "missing", "ng.png")},
+	}
+	reports, err := CompressFileVariants(inputPath, specs)
+	if !errors.Is(err, ErrIO) {
+		t.Fatalf("CompressFileVariants() error = %v, want ErrIO", err)
+	}
+	if reports[0] == nil || reports[1] != nil {
+		t.Errorf("Report = %v, 成功した出力のみ Report が返される必要があります", reports)
+	}
+	if _, err := os.Stat(specs[0].Path); err != nil {
+		t.Errorf("成功した出力が作成されていません: %v", err)
+	}
+}
+
+func TestCompressFileVariantsTransparent(t *testing.T) {
+	// 全面が透明なPNGを作成
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("PNGの作成に失敗しました: %v", err)
+	}
+	inputPath := createTempFile(t, buf.Bytes(), ".png")
+	defer os.Remove(inputPath)
+	outDir := t.TempDir()
+
+	specs := []OutputSpec{
+		{Path: filepath.Join(outDir, "flattened.jpg"), Options: Options{Quality: 90}},
+		{Path: filepath.Join(outDir, "transparent.png")},
+	}
+	if _, err := CompressFileVariants(inputPath, specs); err != nil {
+		t.Fatalf("CompressFileVariants() error = %v", err)
+	}
+
+	t.Run("JPEG", func(t *testing.T) {
+		f, err := os.Open(specs[0].Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		decoded, err := jpeg.Decode(f)
+		if err != nil {
+			t.Fatalf("出力のデコードに失敗しました: %v", err)
+		}
+		// 透明部分は黒ではなく白背景になる
+		r, g, b, _ := decoded.At(20, 20).RGBA()
+		if r>>8 < 250 || g>>8 < 250 || b>>8 < 250 {
+			t.Errorf("透明部分の色 = (%d, %d, %d), want 白", r>>8, g>>8, b>>8)
+		}
+	})
+
+	t.Run("PNG", func(t *testing.T) {
+		f, err := os.Open(specs[1].Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		decoded, err := png.Decode(f)
+		if err != nil {
+			t.Fatalf("出力のデコードに失敗しました: %v", err)
+		}
+		// 透過を扱える形式では透明のまま出力する
+		if _, _, _, a := decoded.At(20, 20).RGBA(); a != 0 {
+			t.Errorf("透明部分のアルファ = %d, want 0", a)
+		}
+	})
+}